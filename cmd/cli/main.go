@@ -7,6 +7,7 @@ import (
 
 	clihandler "github.com/RockX-SG/frost-dkg-demo/internal/cli"
 	"github.com/RockX-SG/frost-dkg-demo/internal/logger"
+	"github.com/RockX-SG/frost-dkg-demo/internal/network"
 	"github.com/urfave/cli/v2"
 )
 
@@ -22,6 +23,19 @@ func main() {
 	app := &cli.App{
 		Name:  "rockx-dkg-cli",
 		Usage: "A cli tool to run DKG for keygen and resharing and generate deposit data",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:    "networks-file",
+				Usage:   "YAML file defining custom networks that can be passed to --network",
+				EnvVars: []string{network.NetworksFileEnv},
+			},
+		},
+		Before: func(c *cli.Context) error {
+			if path := c.String("networks-file"); path != "" {
+				return network.LoadFile(path)
+			}
+			return nil
+		},
 		Commands: []*cli.Command{
 			{
 				Name:    "keygen",
@@ -48,11 +62,15 @@ func main() {
 						Required: true,
 					},
 					&cli.StringFlag{
-						Name:     "fork-version",
-						Aliases:  []string{"f"},
-						Usage:    "fork version",
+						Name:     "network",
+						Aliases:  []string{"n", "fork-version", "f"},
+						Usage:    "network: mainnet, holesky, hoodi, a network of the networks file or custom",
 						Required: true,
 					},
+					&cli.StringFlag{
+						Name:  "custom-fork-version",
+						Usage: "genesis fork version in hex, for the custom network",
+					},
 				},
 			},
 			{
@@ -134,11 +152,15 @@ func main() {
 						Required: true,
 					},
 					&cli.StringFlag{
-						Name:     "fork-version",
-						Aliases:  []string{"f"},
-						Usage:    "fork version",
+						Name:     "network",
+						Aliases:  []string{"n", "fork-version", "f"},
+						Usage:    "network: mainnet, holesky, hoodi, a network of the networks file or custom",
 						Required: true,
 					},
+					&cli.StringFlag{
+						Name:  "custom-fork-version",
+						Usage: "genesis fork version in hex, for the custom network",
+					},
 				},
 			},
 		},
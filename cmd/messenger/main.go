@@ -22,10 +22,12 @@ func main() {
 				Subscribers: make(map[string]*messenger.Subscriber),
 			},
 		},
-		Incoming: make(chan *messenger.Message, 50),
-		Data:     make(map[string]*messenger.DataStore),
+		Incoming:    make(chan *messenger.Message, 50),
+		Data:        make(map[string]*messenger.DataStore),
+		DeadLetters: messenger.NewDeadLetterQueue(),
 	}
 	m.WithLogger(log)
+	m.RegisterMetrics()
 
 	runner := workers.NewRunner(log)
 	go runner.Run()
@@ -61,9 +63,22 @@ func setRoutes(r *gin.Engine, m *messenger.Messenger, runner *workers.Runner) {
 	// Register a node
 	r.POST("/register_node", m.HandleNodeRegistration(runner))
 
+	// Pull based delivery for nodes that can't expose a public endpoint
+	r.GET("/subscribers/:name/messages", m.HandlePullMessages())
+	r.POST("/subscribers/:name/ack", m.HandleAckMessages())
+
+	// Delivery stats and dead letters of outgoing messages
+	r.GET("/subscribers/stats", m.HandleGetSubscriberStats())
+	r.GET("/subscribers/:name/stats", m.HandleGetSubscriberStats())
+	r.GET("/dead_letters", m.HandleGetDeadLetters())
+	r.POST("/dead_letters/:id/replay", m.HandleReplayDeadLetter())
+	r.DELETE("/dead_letters/:id", m.HandleDeleteDeadLetter())
+
 	// DKG network implementation
 	r.POST("/publish", m.HandlePublish())
 	r.POST("/stream/dkgoutput", m.HandleStreamDKGOutput())
 	r.POST("/stream/dkgblame", m.HandleStreamDKGBlame())
+	r.POST("/stream/dkgtimeout", m.HandleStreamDKGTimeout())
+	r.POST("/stream/dkgrejection", m.HandleStreamDKGRejection())
 	r.GET("/data/:request_id", m.HandleGetData())
 }
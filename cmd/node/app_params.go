@@ -2,64 +2,431 @@ package main
 
 import (
 	"crypto/ecdsa"
+	"crypto/rsa"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
+	"net/url"
 	"os"
-	"strconv"
+	"path/filepath"
+	"strings"
+	"time"
 
+	"github.com/RockX-SG/frost-dkg-demo/internal/keymanager"
+	"github.com/RockX-SG/frost-dkg-demo/internal/messenger"
+	"github.com/RockX-SG/frost-dkg-demo/internal/network"
+	"github.com/RockX-SG/frost-dkg-demo/internal/node"
+	"github.com/RockX-SG/frost-dkg-demo/internal/secrets"
+	store "github.com/RockX-SG/frost-dkg-demo/internal/storage"
 	"github.com/bloxapp/ssv-spec/types"
 	"github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/sirupsen/logrus"
+	"github.com/urfave/cli/v2"
+	"gopkg.in/yaml.v3"
 )
 
+const (
+	TransportMessenger = "messenger"
+	TransportMesh      = "mesh"
+
+	SignerLocal  = "local"
+	SignerRemote = "remote"
+)
+
+// AppParams is the node configuration. It is built from the defaults, then the config
+// file, then environment variables and finally command line flags, each overriding the last.
 type AppParams struct {
-	OperatorID       types.OperatorID
-	HttpAddress      string
-	KeystoreFilePath string
-	keystorePassword string
+	OperatorID       types.OperatorID `yaml:"operator_id"`
+	DataDir          string           `yaml:"data_dir"`
+	DBBackend        string           `yaml:"db_backend"`
+	HttpAddress      string           `yaml:"listen_addr"`
+	AdminSocket      string           `yaml:"admin_socket"`
+	BroadcastAddress string           `yaml:"broadcast_addr"`
+	TLSCertFile      string           `yaml:"tls_cert_file"`
+	TLSKeyFile       string           `yaml:"tls_key_file"`
+	Network          string           `yaml:"network"`
+	NetworksFile     string           `yaml:"networks_file"`
+	CustomFork       string           `yaml:"custom_fork_version"`
+	CustomDomain     string           `yaml:"custom_domain_type"`
+	RegistryURL      string           `yaml:"registry_url"`
+	OperatorCacheTTL time.Duration    `yaml:"operator_cache_ttl"`
+	PolicyFile       string           `yaml:"policy_file"`
+	RoundTimeout     time.Duration    `yaml:"round_timeout"`
+	// MaxCeremonies bounds the ceremonies the node runs at once, CeremonyQueueSize the messages
+	// of a ceremony waiting to be processed
+	MaxCeremonies     int    `yaml:"max_ceremonies"`
+	CeremonyQueueSize int    `yaml:"ceremony_queue_size"`
+	MessengerAddress  string `yaml:"messenger_addr"`
+	DeliveryMode      string `yaml:"delivery_mode"`
+	Transport         string `yaml:"transport"`
+	MeshAddressBook   string `yaml:"mesh_address_book"`
+	MeshSink          string `yaml:"mesh_sink"`
+	Signer            string `yaml:"signer"`
+	KeystoreFilePath  string `yaml:"keystore_file"`
+	// KeystorePassword and OperatorKeyPassword name the sources the passwords are read from,
+	// see secrets.Parse, so the passwords themselves never end up in the config
+	KeystorePassword    string             `yaml:"keystore_password"`
+	RemoteSigner        remoteSignerParams `yaml:"remote_signer"`
+	OperatorKeyFile     string             `yaml:"operator_key_file"`
+	OperatorKeyPassword string             `yaml:"operator_key_password"`
+	// StoragePassword is the secret source of the password shares are encrypted at rest with,
+	// when empty the encryption key is derived from the keystore key
+	StoragePassword string `yaml:"storage_password"`
+	LogFile         string `yaml:"log_file"`
+	LogLevel        string `yaml:"log_level"`
+
+	keystorePassword    secrets.Source
+	operatorKeyPassword secrets.Source
+	storagePassword     secrets.Source
+	network             *network.Network
+}
+
+func defaultAppParams() *AppParams {
+	return &AppParams{
+		DataDir:             "/frost-dkg-data",
+		DBBackend:           store.BackendBadger,
+		HttpAddress:         "0.0.0.0:8080",
+		Network:             network.Hoodi,
+		OperatorCacheTTL:    store.DefaultOperatorCacheTTL,
+		MaxCeremonies:       node.DefaultMaxCeremonies,
+		CeremonyQueueSize:   node.DefaultCeremonyQueueSize,
+		RoundTimeout:        node.DefaultRoundTimeout,
+		MessengerAddress:    messenger.DefaultSrvAddr,
+		DeliveryMode:        messenger.DeliveryModePush,
+		Transport:           TransportMessenger,
+		Signer:              SignerLocal,
+		KeystoreFilePath:    "keystore.json",
+		KeystorePassword:    "env:KEYSTORE_PASSWORD",
+		OperatorKeyPassword: "env:OPERATOR_KEY_PASSWORD",
+		LogFile:             "/var/log/dkg_node.log",
+		LogLevel:            "debug",
+	}
+}
+
+// loadAppParams builds the node configuration from the config file and the flags of the cli
+// context, whose values may come from the environment, and validates it
+func loadAppParams(c *cli.Context) (*AppParams, error) {
+	params := defaultAppParams()
+	if path := c.String(flagConfig); path != "" {
+		if err := params.loadFromFile(path); err != nil {
+			return nil, err
+		}
+	}
+	params.loadFromFlags(c)
+
+	if err := params.validate(); err != nil {
+		return nil, fmt.Errorf("invalid node configuration: %v", err)
+	}
+	return params, nil
+}
+
+func (params *AppParams) loadFromFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to read config file %s: %v", path, err)
+	}
+	defer f.Close()
+
+	decoder := yaml.NewDecoder(f)
+	decoder.KnownFields(true)
+	if err := decoder.Decode(params); err != nil {
+		return fmt.Errorf("failed to parse config file %s: %v", path, err)
+	}
+	return nil
+}
+
+func (params *AppParams) loadFromFlags(c *cli.Context) {
+	if c.IsSet(flagOperatorID) {
+		params.OperatorID = types.OperatorID(c.Uint64(flagOperatorID))
+	}
+	setString(c, flagDataDir, &params.DataDir)
+	setString(c, flagDBBackend, &params.DBBackend)
+	setString(c, flagListenAddr, &params.HttpAddress)
+	setString(c, flagAdminSocket, &params.AdminSocket)
+	setString(c, flagBroadcastAddr, &params.BroadcastAddress)
+	setString(c, flagTLSCert, &params.TLSCertFile)
+	setString(c, flagTLSKey, &params.TLSKeyFile)
+	setString(c, flagNetwork, &params.Network)
+	setString(c, flagNetworksFile, &params.NetworksFile)
+	setString(c, flagCustomFork, &params.CustomFork)
+	setString(c, flagCustomDomain, &params.CustomDomain)
+	setString(c, flagRegistryURL, &params.RegistryURL)
+	if c.IsSet(flagOperatorCacheTTL) {
+		params.OperatorCacheTTL = c.Duration(flagOperatorCacheTTL)
+	}
+	setString(c, flagPolicyFile, &params.PolicyFile)
+	if c.IsSet(flagMaxCeremonies) {
+		params.MaxCeremonies = c.Int(flagMaxCeremonies)
+	}
+	if c.IsSet(flagCeremonyQueueSize) {
+		params.CeremonyQueueSize = c.Int(flagCeremonyQueueSize)
+	}
+	if c.IsSet(flagRoundTimeout) {
+		params.RoundTimeout = c.Duration(flagRoundTimeout)
+	}
+	setString(c, flagMessengerAddr, &params.MessengerAddress)
+	setString(c, flagDeliveryMode, &params.DeliveryMode)
+	setString(c, flagTransport, &params.Transport)
+	setString(c, flagMeshAddressBook, &params.MeshAddressBook)
+	setString(c, flagMeshSink, &params.MeshSink)
+	setString(c, flagSigner, &params.Signer)
+	setString(c, flagKeystoreFile, &params.KeystoreFilePath)
+	setString(c, flagRemoteSignerURL, &params.RemoteSigner.URL)
+	setString(c, flagRemoteSignerAddress, &params.RemoteSigner.Address)
+	setString(c, flagRemoteSignerCA, &params.RemoteSigner.CACertFile)
+	setString(c, flagRemoteSignerCert, &params.RemoteSigner.ClientCertFile)
+	setString(c, flagRemoteSignerKey, &params.RemoteSigner.ClientKeyFile)
+	setString(c, flagOperatorKeyFile, &params.OperatorKeyFile)
+	setString(c, flagKeystorePassword, &params.KeystorePassword)
+	setString(c, flagOperatorKeyPassword, &params.OperatorKeyPassword)
+	setString(c, flagStoragePassword, &params.StoragePassword)
+	setString(c, flagLogFile, &params.LogFile)
+	setString(c, flagLogLevel, &params.LogLevel)
+}
+
+func setString(c *cli.Context, name string, field *string) {
+	if c.IsSet(name) {
+		*field = c.String(name)
+	}
+}
+
+func (params *AppParams) validate() error {
+	if params.DataDir == "" {
+		return errors.New("data dir is required")
+	}
+	switch params.DBBackend {
+	case store.BackendBadger, store.BackendSQLite, store.BackendMemory:
+	default:
+		return fmt.Errorf("invalid db backend %s: has to be badger, sqlite or memory", params.DBBackend)
+	}
+	if params.HttpAddress == "" {
+		return errors.New("listen address is required")
+	}
+	if (params.TLSCertFile == "") != (params.TLSKeyFile == "") {
+		return errors.New("TLS needs both a certificate and a key file")
+	}
+	for _, path := range []string{params.TLSCertFile, params.TLSKeyFile} {
+		if path == "" {
+			continue
+		}
+		if _, err := os.Stat(path); err != nil {
+			return fmt.Errorf("TLS file: %v", err)
+		}
+	}
+	if err := params.loadNetwork(); err != nil {
+		return err
+	}
+	if err := validateURL(params.RegistryURL); err != nil {
+		return fmt.Errorf("registry URL: %v", err)
+	}
+	if params.OperatorCacheTTL <= 0 {
+		return errors.New("operator cache TTL has to be positive")
+	}
+	if params.MaxCeremonies <= 0 || params.CeremonyQueueSize <= 0 {
+		return errors.New("max ceremonies and ceremony queue size have to be positive")
+	}
+	if params.RoundTimeout <= 0 || params.RoundTimeout > node.CeremonyTimeout {
+		return fmt.Errorf("round timeout has to be positive and at most %s", node.CeremonyTimeout)
+	}
+	if _, err := params.logLevel(); err != nil {
+		return err
+	}
+
+	var err error
+	if params.operatorKeyPassword, err = secrets.Parse(params.OperatorKeyPassword, "Operator key password: "); err != nil {
+		return fmt.Errorf("operator key password: %v", err)
+	}
+
+	if params.StoragePassword != "" {
+		if params.storagePassword, err = secrets.Parse(params.StoragePassword, "Storage password: "); err != nil {
+			return fmt.Errorf("storage password: %v", err)
+		}
+	}
+
+	switch params.Signer {
+	case SignerLocal:
+		if params.KeystoreFilePath == "" {
+			return errors.New("keystore file is required for the local signer")
+		}
+		if params.keystorePassword, err = secrets.Parse(params.KeystorePassword, "Keystore password: "); err != nil {
+			return fmt.Errorf("keystore password: %v", err)
+		}
+	case SignerRemote:
+		if err := params.RemoteSigner.validate(); err != nil {
+			return fmt.Errorf("remote signer: %v", err)
+		}
+		if params.StoragePassword == "" {
+			return errors.New("storage password is required with a remote signer, shares can't be encrypted with the keystore key")
+		}
+	default:
+		return fmt.Errorf("invalid signer %s: has to be local or remote", params.Signer)
+	}
+
+	switch params.Transport {
+	case TransportMessenger:
+		if err := validateURL(params.MessengerAddress); err != nil {
+			return fmt.Errorf("messenger address: %v", err)
+		}
+		switch params.DeliveryMode {
+		case messenger.DeliveryModePush:
+			if params.BroadcastAddress == "" {
+				return errors.New("broadcast address is required for push delivery")
+			}
+		case messenger.DeliveryModePull:
+		default:
+			return fmt.Errorf("invalid delivery mode %s: has to be push or pull", params.DeliveryMode)
+		}
+	case TransportMesh:
+		if params.MeshAddressBook == "" {
+			return errors.New("mesh address book is required for the mesh transport")
+		}
+		if params.DeliveryMode == messenger.DeliveryModePull {
+			return errors.New("pull delivery needs the messenger transport, the mesh transport pushes messages to the nodes")
+		}
+	default:
+		return fmt.Errorf("invalid transport %s: has to be messenger or mesh", params.Transport)
+	}
+	return nil
+}
+
+func validateURL(rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("%s is not an http(s) URL", rawURL)
+	}
+	return nil
+}
+
+// loadNetwork resolves the network profile, and takes the registry URL from it unless one is configured
+func (params *AppParams) loadNetwork() error {
+	if params.NetworksFile != "" {
+		if err := network.LoadFile(params.NetworksFile); err != nil {
+			return err
+		}
+	}
+
+	var err error
+	if params.Network == network.Custom {
+		params.network, err = network.NewCustom(params.CustomFork, params.CustomDomain, params.RegistryURL)
+	} else {
+		params.network, err = network.FromName(params.Network)
+	}
+	if err != nil {
+		return err
+	}
+
+	if params.RegistryURL == "" {
+		params.RegistryURL = params.network.RegistryURL
+	}
+	if len(params.network.DomainType) == 0 || params.RegistryURL == "" {
+		return fmt.Errorf("network %s needs a domain type and a registry URL", params.network.Name)
+	}
+	return nil
+}
+
+func (params *AppParams) logLevel() (logrus.Level, error) {
+	// release is the value DKG_LOG_LEVEL used to take before log levels could be configured
+	if params.LogLevel == "release" {
+		return logrus.InfoLevel, nil
+	}
+	level, err := logrus.ParseLevel(params.LogLevel)
+	if err != nil {
+		return level, fmt.Errorf("invalid log level %s", params.LogLevel)
+	}
+	return level, nil
+}
+
+// adminSocketPath returns the path of the admin socket
+func (params *AppParams) adminSocketPath() string {
+	if params.AdminSocket != "" {
+		return params.AdminSocket
+	}
+	return filepath.Join(params.DataDir, "admin.sock")
 }
 
-func (params *AppParams) loadFromEnv() {
-	params.loadOperatorID()
-	params.loadHttpAddress()
-	params.loadKeystoreFilePath()
-	params.loadKeystorePassword()
+func (params *AppParams) usesTLS() bool {
+	return params.TLSCertFile != ""
 }
 
+// print returns the effective configuration, which names the password sources but holds no secrets
 func (params *AppParams) print() string {
 	return fmt.Sprintf(
-		"operatorID=%d http_addr=%s keystore_filepath=%s",
+		"operatorID=%d data_dir=%s db_backend=%s listen_addr=%s admin_socket=%s broadcast_addr=%s tls_cert_file=%s tls_key_file=%s network=%s networks_file=%s registry_url=%s operator_cache_ttl=%s policy_file=%s max_ceremonies=%d ceremony_queue_size=%d round_timeout=%s messenger_addr=%s delivery_mode=%s transport=%s mesh_address_book=%s mesh_sink=%s signer=%s keystore_filepath=%s remote_signer_url=%s remote_signer_address=%s keystore_password=%s operator_key_file=%s operator_key_password=%s storage_password=%s log_file=%s log_level=%s",
 		params.OperatorID,
+		params.DataDir,
+		params.DBBackend,
 		params.HttpAddress,
+		params.adminSocketPath(),
+		params.BroadcastAddress,
+		params.TLSCertFile,
+		params.TLSKeyFile,
+		params.network,
+		params.NetworksFile,
+		params.RegistryURL,
+		params.OperatorCacheTTL,
+		params.PolicyFile,
+		params.MaxCeremonies,
+		params.CeremonyQueueSize,
+		params.RoundTimeout,
+		params.MessengerAddress,
+		params.DeliveryMode,
+		params.Transport,
+		params.MeshAddressBook,
+		params.MeshSink,
+		params.Signer,
 		params.KeystoreFilePath,
+		params.RemoteSigner.URL,
+		params.RemoteSigner.Address,
+		params.KeystorePassword,
+		params.OperatorKeyFile,
+		params.OperatorKeyPassword,
+		params.StoragePassword,
+		params.LogFile,
+		params.LogLevel,
 	)
 }
 
-func (params *AppParams) loadOperatorID() {
-	operatorID, err := strconv.ParseUint(os.Getenv("NODE_OPERATOR_ID"), 10, 32)
-	if err != nil {
-		panic(err)
+// loadETHSigner sets up signing with the operator's ethereum key, from the keystore file or
+// through the remote signer. The decrypted keystore key is returned too, nil with a remote signer.
+func (params *AppParams) loadETHSigner() (keymanager.ETHSigner, *ecdsa.PrivateKey, error) {
+	if params.Signer == SignerRemote {
+		remote, err := keymanager.NewRemoteSigner(params.RemoteSigner.config())
+		if err != nil {
+			return nil, nil, err
+		}
+		if err := remote.Check(); err != nil {
+			return nil, nil, err
+		}
+		return remote, nil, nil
 	}
-	params.OperatorID = types.OperatorID(operatorID)
-}
 
-func (params *AppParams) loadHttpAddress() {
-	nodeAddr := os.Getenv("NODE_ADDR")
-	if nodeAddr == "" {
-		nodeAddr = "0.0.0.0:8080"
+	key, err := params.loadDecryptedPrivateKey()
+	if err != nil {
+		return nil, nil, err
 	}
-	params.HttpAddress = nodeAddr
+	return keymanager.NewLocalSigner(key), key, nil
 }
 
-func (params *AppParams) loadKeystoreFilePath() {
-	keystoreFilePath := os.Getenv("KEYSTORE_FILE_PATH")
-	if keystoreFilePath == "" {
-		keystoreFilePath = "keystore.json"
+// loadShareKEK returns the secret shares are encrypted at rest with, the storage password or
+// otherwise the keystore key
+func (params *AppParams) loadShareKEK(key *ecdsa.PrivateKey) (*store.KEK, error) {
+	if params.storagePassword == nil {
+		if key == nil {
+			return nil, errors.New("a storage password or the keystore key is required to encrypt shares")
+		}
+		return store.KeystoreKEK(key), nil
 	}
-	params.KeystoreFilePath = keystoreFilePath
-}
-
-func (params *AppParams) loadKeystorePassword() {
-	params.keystorePassword = os.Getenv("KEYSTORE_PASSWORD")
+	password, err := params.storagePassword.Secret()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read storage password: %v", err)
+	}
+	defer secrets.Zero(password)
+	return store.PasswordKEK(password), nil
 }
 
 func (params *AppParams) loadDecryptedPrivateKey() (*ecdsa.PrivateKey, error) {
@@ -67,9 +434,87 @@ func (params *AppParams) loadDecryptedPrivateKey() (*ecdsa.PrivateKey, error) {
 	if err != nil {
 		return nil, err
 	}
-	key, err := keystore.DecryptKey(keyJSON, params.keystorePassword)
+	password, err := params.keystorePassword.Secret()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read keystore password: %v", err)
+	}
+	defer secrets.Zero(password)
+
+	// DecryptKey only takes the password as a string, a copy Zero can't clear, which stays in
+	// memory until the garbage collector reuses it
+	key, err := keystore.DecryptKey(keyJSON, string(password))
 	if err != nil {
 		return nil, err
 	}
 	return key.PrivateKey, nil
 }
+
+// loadOperatorKey loads the operator's RSA key from the configured key file. Without one, nodes
+// running the hardcoded test operators use the test key of their operator.
+func (params *AppParams) loadOperatorKey() (*rsa.PrivateKey, error) {
+	if params.OperatorKeyFile != "" {
+		return keymanager.LoadOperatorKey(params.OperatorKeyFile, params.operatorKeyPassword)
+	}
+	if key, ok := store.HardcodedEncryptionKey(params.OperatorID); ok {
+		return key, nil
+	}
+	return nil, nil
+}
+
+// checkKeystore verifies that the keystore file is still readable and belongs to the loaded key
+func (params *AppParams) checkKeystore(address common.Address) error {
+	keyJSON, err := ioutil.ReadFile(params.KeystoreFilePath)
+	if err != nil {
+		return err
+	}
+	ks := struct {
+		Address string `json:"address"`
+	}{}
+	if err := json.Unmarshal(keyJSON, &ks); err != nil {
+		return err
+	}
+	if !strings.EqualFold(strings.TrimPrefix(ks.Address, "0x"), strings.TrimPrefix(address.Hex(), "0x")) {
+		return fmt.Errorf("keystore file at %s doesn't match the loaded key", params.KeystoreFilePath)
+	}
+	return nil
+}
+
+// remoteSignerParams configures the remote signer holding the operator's ethereum key
+type remoteSignerParams struct {
+	URL            string `yaml:"url"`
+	Address        string `yaml:"address"`
+	CACertFile     string `yaml:"ca_file"`
+	ClientCertFile string `yaml:"cert_file"`
+	ClientKeyFile  string `yaml:"key_file"`
+}
+
+func (p *remoteSignerParams) validate() error {
+	if err := validateURL(p.URL); err != nil {
+		return fmt.Errorf("URL: %v", err)
+	}
+	if !common.IsHexAddress(p.Address) {
+		return fmt.Errorf("invalid address %s", p.Address)
+	}
+	if (p.ClientCertFile == "") != (p.ClientKeyFile == "") {
+		return errors.New("client auth needs both a certificate and a key file")
+	}
+	for _, path := range []string{p.CACertFile, p.ClientCertFile, p.ClientKeyFile} {
+		if path == "" {
+			continue
+		}
+		if _, err := os.Stat(path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *remoteSignerParams) config() keymanager.RemoteSignerConfig {
+	return keymanager.RemoteSignerConfig{
+		URL:            p.URL,
+		Address:        common.HexToAddress(p.Address),
+		CACertFile:     p.CACertFile,
+		ClientCertFile: p.ClientCertFile,
+		ClientKeyFile:  p.ClientKeyFile,
+	}
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/urfave/cli/v2"
+)
+
+// loadTestParams loads the node configuration from the command line args, as the node does
+func loadTestParams(t *testing.T, args ...string) (*AppParams, error) {
+	t.Helper()
+	var params *AppParams
+	var loadErr error
+	app := &cli.App{
+		Flags: appFlags(),
+		Action: func(c *cli.Context) error {
+			params, loadErr = loadAppParams(c)
+			return nil
+		},
+	}
+	require.NoError(t, app.Run(append([]string{"node"}, args...)))
+	return params, loadErr
+}
+
+func writeTestConfig(t *testing.T, config string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "node.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(config), 0600))
+	return path
+}
+
+func TestLoadAppParamsPrecedence(t *testing.T) {
+	config := writeTestConfig(t, `
+data_dir: /from-file
+max_ceremonies: 5
+round_timeout: 2m
+log_level: info
+broadcast_addr: http://from-file:8080
+`)
+
+	// the config file overrides the defaults
+	params, err := loadTestParams(t, "--config", config)
+	require.NoError(t, err)
+	require.Equal(t, "/from-file", params.DataDir)
+	require.Equal(t, 5, params.MaxCeremonies)
+	require.Equal(t, 2*time.Minute, params.RoundTimeout)
+	require.Equal(t, "info", params.LogLevel)
+	require.Equal(t, "0.0.0.0:8080", params.HttpAddress)
+
+	// the environment overrides the config file
+	t.Setenv("NODE_DATA_DIR", "/from-env")
+	t.Setenv("NODE_MAX_CEREMONIES", "6")
+	params, err = loadTestParams(t, "--config", config)
+	require.NoError(t, err)
+	require.Equal(t, "/from-env", params.DataDir)
+	require.Equal(t, 6, params.MaxCeremonies)
+	require.Equal(t, "info", params.LogLevel)
+
+	// and flags override the environment
+	params, err = loadTestParams(t, "--config", config, "--data-dir", "/from-flag", "--log-level", "warn")
+	require.NoError(t, err)
+	require.Equal(t, "/from-flag", params.DataDir)
+	require.Equal(t, 6, params.MaxCeremonies)
+	require.Equal(t, "warn", params.LogLevel)
+	require.Equal(t, "http://from-file:8080", params.BroadcastAddress)
+
+	// unknown config keys are refused
+	_, err = loadTestParams(t, "--config", writeTestConfig(t, "data_dir: /from-file\nlisten_address: 0.0.0.0:8080\n"))
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "field listen_address not found")
+}
+
+func TestValidateAppParams(t *testing.T) {
+	valid := func() *AppParams {
+		params := defaultAppParams()
+		params.BroadcastAddress = "http://node-1:8080"
+		return params
+	}
+	require.NoError(t, valid().validate())
+
+	for _, test := range []struct {
+		name   string
+		modify func(*AppParams)
+		err    string
+	}{
+		{"pull delivery with the mesh transport", func(p *AppParams) {
+			p.Transport = TransportMesh
+			p.MeshAddressBook = "/config/address_book.json"
+			p.DeliveryMode = "pull"
+		}, "pull delivery needs the messenger transport"},
+		{"mesh transport without an address book", func(p *AppParams) { p.Transport = TransportMesh }, "mesh address book is required"},
+		{"push delivery without a broadcast address", func(p *AppParams) { p.BroadcastAddress = "" }, "broadcast address is required"},
+		{"remote signer without a storage password", func(p *AppParams) {
+			p.Signer = SignerRemote
+			p.RemoteSigner = remoteSignerParams{URL: "http://localhost:8550", Address: "0x535953b5A6040074948cf185EAa7d2aBBD66808f"}
+		}, "storage password is required with a remote signer"},
+		{"remote signer without an address", func(p *AppParams) {
+			p.Signer = SignerRemote
+			p.RemoteSigner = remoteSignerParams{URL: "http://localhost:8550"}
+			p.StoragePassword = "env:STORAGE_PASSWORD"
+		}, "invalid address"},
+		{"TLS certificate without a key", func(p *AppParams) { p.TLSCertFile = "/certs/node.crt" }, "TLS needs both a certificate and a key file"},
+		{"unknown network", func(p *AppParams) { p.Network = "prater" }, "unknown network"},
+		{"round timeout over the ceremony timeout", func(p *AppParams) { p.RoundTimeout = 2 * time.Hour }, "round timeout"},
+		{"invalid password source", func(p *AppParams) { p.KeystorePassword = "^mvrtOw$787X" }, "keystore password"},
+		{"unknown signer", func(p *AppParams) { p.Signer = "hsm" }, "invalid signer hsm"},
+	} {
+		params := valid()
+		test.modify(params)
+		err := params.validate()
+		require.Error(t, err, test.name)
+		require.Contains(t, err.Error(), test.err, test.name)
+	}
+
+	// with a storage password the remote signer is valid
+	params := valid()
+	params.Signer = SignerRemote
+	params.RemoteSigner = remoteSignerParams{URL: "http://localhost:8550", Address: "0x535953b5A6040074948cf185EAa7d2aBBD66808f"}
+	params.StoragePassword = "env:STORAGE_PASSWORD"
+	require.NoError(t, params.validate())
+}
+
+func TestPrintAppParams(t *testing.T) {
+	secretValues := map[string]string{
+		"KEYSTORE_PASSWORD":     "keystore secret",
+		"OPERATOR_KEY_PASSWORD": "operator key secret",
+		"STORAGE_PASSWORD":      "storage secret",
+	}
+	for name, value := range secretValues {
+		t.Setenv(name, value)
+	}
+	passwordFile := filepath.Join(t.TempDir(), "password")
+	require.NoError(t, os.WriteFile(passwordFile, []byte("file secret"), 0600))
+
+	params, err := loadTestParams(t,
+		"--broadcast-addr", "http://node-1:8080",
+		"--storage-password", "env:STORAGE_PASSWORD",
+		"--keystore-password", "file:"+passwordFile,
+	)
+	require.NoError(t, err)
+	printed := params.print()
+
+	// the password sources are named, never the passwords
+	require.Contains(t, printed, "keystore_password=file:"+passwordFile)
+	require.Contains(t, printed, "operator_key_password=env:OPERATOR_KEY_PASSWORD")
+	require.Contains(t, printed, "storage_password=env:STORAGE_PASSWORD")
+	secretValues["keystore password file"] = "file secret"
+	for _, secret := range secretValues {
+		require.False(t, strings.Contains(printed, secret), "printed the secret %q", secret)
+	}
+}
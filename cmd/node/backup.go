@@ -0,0 +1,295 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/RockX-SG/frost-dkg-demo/internal/backup"
+	"github.com/RockX-SG/frost-dkg-demo/internal/secrets"
+	store "github.com/RockX-SG/frost-dkg-demo/internal/storage"
+	"github.com/urfave/cli/v2"
+)
+
+const (
+	flagBackupPassword = "backup-password"
+	flagOutput         = "output"
+	flagInput          = "input"
+)
+
+func backupCommand() *cli.Command {
+	return &cli.Command{
+		Name:  "backup",
+		Usage: "write an encrypted backup of the database; while the node is running the backup is taken through its api",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:     flagOutput,
+				Usage:    "path of the backup file to create",
+				Required: true,
+			},
+			backupPasswordFlag(),
+		},
+		Action: backupDatabase,
+	}
+}
+
+func restoreCommand() *cli.Command {
+	return &cli.Command{
+		Name:  "restore",
+		Usage: "check a backup and restore it into an empty data dir, the node must be stopped",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:     flagInput,
+				Usage:    "path of the backup file",
+				Required: true,
+			},
+			backupPasswordFlag(),
+		},
+		Action: restoreDatabase,
+	}
+}
+
+func backupPasswordFlag() cli.Flag {
+	return &cli.StringFlag{
+		Name:  flagBackupPassword,
+		Usage: "secret source of the password the backup is encrypted with",
+		Value: "prompt",
+	}
+}
+
+func backupDatabase(c *cli.Context) error {
+	params, err := loadAppParams(c)
+	if err != nil {
+		return err
+	}
+	password, err := readSecret(c.String(flagBackupPassword), "Backup password: ")
+	if err != nil {
+		return fmt.Errorf("backup password: %v", err)
+	}
+	defer secrets.Zero(password)
+
+	file, err := takeBackup(params, password)
+	if err != nil {
+		return err
+	}
+
+	// load the backup to make sure it can be restored and holds what its manifest lists
+	manifest, data, err := backup.Read(file, password)
+	if err != nil {
+		return fmt.Errorf("failed to read the backup: %v", err)
+	}
+	storage, closeDB, err := loadBackup(manifest.Version, data)
+	if err != nil {
+		return fmt.Errorf("failed to load the backup: %v", err)
+	}
+	defer closeDB()
+	counts, err := storage.Stats()
+	if err != nil {
+		return err
+	}
+	if *counts != manifest.Counts {
+		return fmt.Errorf("backup holds %+v, its manifest lists %+v", *counts, manifest.Counts)
+	}
+
+	f, err := os.OpenFile(c.String(flagOutput), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(file); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return fmt.Errorf("failed to write the backup: %v", err)
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	fmt.Printf("backed up %d shares, %d operators and %d ceremonies (%d records) to %s\n",
+		counts.KeyGenOutputs, counts.Operators, counts.Ceremonies, counts.Total, f.Name())
+	return nil
+}
+
+// takeBackup returns a backup of the database encrypted with the password, taken by the running
+// node when it holds the database lock
+func takeBackup(params *AppParams, password []byte) ([]byte, error) {
+	if params.DBBackend == store.BackendMemory {
+		return nil, fmt.Errorf("the %s backend keeps nothing on disk to back up", store.BackendMemory)
+	}
+	if _, err := os.Stat(params.DataDir); err != nil {
+		return nil, fmt.Errorf("data dir: %v", err)
+	}
+	db, err := setupDB(params)
+	if err != nil {
+		if !errors.Is(err, store.ErrLocked) {
+			return nil, fmt.Errorf("failed to open the database: %v", err)
+		}
+		fmt.Println("the database is in use, taking the backup from the running node")
+		return fetchBackup(params, password)
+	}
+	defer db.Close()
+
+	storage := store.NewStorage(db)
+	data := &bytes.Buffer{}
+	counts, err := storage.Backup(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to back up the database: %v", err)
+	}
+	schemaVersion, err := storage.SchemaVersion()
+	if err != nil {
+		return nil, err
+	}
+	file := &bytes.Buffer{}
+	if err := backup.Write(file, backup.NewManifest(data.Bytes(), schemaVersion, counts), data.Bytes(), password); err != nil {
+		return nil, fmt.Errorf("failed to encrypt the backup: %v", err)
+	}
+	return file.Bytes(), nil
+}
+
+// fetchBackup gets a backup encrypted with the password from the admin socket of the node
+// running on this host
+func fetchBackup(params *AppParams, password []byte) ([]byte, error) {
+	socket := params.adminSocketPath()
+	client := &http.Client{
+		Timeout: time.Minute,
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				return (&net.Dialer{}).DialContext(ctx, "unix", socket)
+			},
+		},
+	}
+	// the host is ignored, requests go to the socket
+	resp, err := client.Post("http://node/backup", "application/octet-stream", bytes.NewReader(password))
+	if err != nil {
+		return nil, fmt.Errorf("failed to reach the node on its admin socket %s: %v", socket, err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the backup from the node: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("node failed to back up the database: %s: %s", resp.Status, data)
+	}
+	return data, nil
+}
+
+func restoreDatabase(c *cli.Context) error {
+	params, err := loadAppParams(c)
+	if err != nil {
+		return err
+	}
+	password, err := readSecret(c.String(flagBackupPassword), "Backup password: ")
+	if err != nil {
+		return fmt.Errorf("backup password: %v", err)
+	}
+	defer secrets.Zero(password)
+
+	file, err := os.ReadFile(c.String(flagInput))
+	if err != nil {
+		return err
+	}
+	manifest, data, err := backup.Read(file, password)
+	if err != nil {
+		return err
+	}
+
+	if err := checkBackup(params, manifest, data); err != nil {
+		return fmt.Errorf("backup check failed: %v", err)
+	}
+
+	db, err := setupDB(params)
+	if err != nil {
+		return fmt.Errorf("failed to open the database, is the node stopped? %v", err)
+	}
+	defer db.Close()
+	storage := store.NewStorage(db)
+	stats, err := storage.Stats()
+	if err != nil {
+		return err
+	}
+	if stats.Total > 0 {
+		return fmt.Errorf("data dir %s already holds %d records, restore into an empty data dir", params.DataDir, stats.Total)
+	}
+	if err := restoreBackup(storage, manifest.Version, data); err != nil {
+		return fmt.Errorf("failed to restore the backup: %v", err)
+	}
+	fmt.Printf("restored the backup of %s with %d shares into %s\n", manifest.CreatedAt.Format(time.RFC3339), manifest.Counts.KeyGenOutputs, params.DataDir)
+	return nil
+}
+
+// checkBackup loads a backup in memory, checks it holds what its manifest lists and that every
+// share decrypts with the configured keys and matches its share public key
+func checkBackup(params *AppParams, manifest *backup.Manifest, data []byte) error {
+	storage, closeDB, err := loadBackup(manifest.Version, data)
+	if err != nil {
+		return err
+	}
+	defer closeDB()
+
+	counts, err := storage.Stats()
+	if err != nil {
+		return err
+	}
+	if *counts != manifest.Counts {
+		return fmt.Errorf("backup holds %+v, its manifest lists %+v", *counts, manifest.Counts)
+	}
+
+	var key *ecdsa.PrivateKey
+	if params.storagePassword == nil {
+		if key, err = params.loadDecryptedPrivateKey(); err != nil {
+			return fmt.Errorf("failed to load the keystore key: %v", err)
+		}
+	}
+	kek, err := params.loadShareKEK(key)
+	if err != nil {
+		return err
+	}
+	defer kek.Zero()
+	if _, err := storage.EnableShareEncryption(kek); err != nil {
+		return err
+	}
+	checked, err := storage.VerifyShares(params.OperatorID)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("checked %d shares\n", checked)
+	return nil
+}
+
+// loadBackup loads a database backup into an in-memory database at the current schema version
+func loadBackup(version int, data []byte) (*store.Storage, func(), error) {
+	db := store.NewMemoryBackend()
+	storage := store.NewStorage(db)
+	if err := restoreBackup(storage, version, data); err != nil {
+		db.Close()
+		return nil, nil, err
+	}
+	if _, err := storage.Migrate(false); err != nil {
+		db.Close()
+		return nil, nil, err
+	}
+	return storage, func() { db.Close() }, nil
+}
+
+// restoreBackup loads the database backup of a backup of the given version into storage
+func restoreBackup(storage *store.Storage, version int, data []byte) error {
+	if version == backup.VersionBadger {
+		return storage.RestoreBadgerBackup(bytes.NewReader(data))
+	}
+	return storage.Restore(bytes.NewReader(data))
+}
+
+func readSecret(spec, prompt string) ([]byte, error) {
+	source, err := secrets.Parse(spec, prompt)
+	if err != nil {
+		return nil, err
+	}
+	return source.Secret()
+}
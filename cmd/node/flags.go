@@ -0,0 +1,236 @@
+package main
+
+import "github.com/urfave/cli/v2"
+
+const (
+	flagConfig              = "config"
+	flagOperatorID          = "operator-id"
+	flagDataDir             = "data-dir"
+	flagDBBackend           = "db-backend"
+	flagListenAddr          = "listen-addr"
+	flagAdminSocket         = "admin-socket"
+	flagBroadcastAddr       = "broadcast-addr"
+	flagTLSCert             = "tls-cert"
+	flagTLSKey              = "tls-key"
+	flagNetwork             = "network"
+	flagNetworksFile        = "networks-file"
+	flagCustomFork          = "custom-fork-version"
+	flagCustomDomain        = "custom-domain-type"
+	flagRegistryURL         = "registry-url"
+	flagOperatorCacheTTL    = "operator-cache-ttl"
+	flagPolicyFile          = "policy-file"
+	flagMaxCeremonies       = "max-ceremonies"
+	flagCeremonyQueueSize   = "ceremony-queue-size"
+	flagRoundTimeout        = "round-timeout"
+	flagMessengerAddr       = "messenger-addr"
+	flagDeliveryMode        = "delivery-mode"
+	flagTransport           = "transport"
+	flagMeshAddressBook     = "mesh-address-book"
+	flagMeshSink            = "mesh-sink"
+	flagSigner              = "signer"
+	flagKeystoreFile        = "keystore-file"
+	flagRemoteSignerURL     = "remote-signer-url"
+	flagRemoteSignerAddress = "remote-signer-address"
+	flagRemoteSignerCA      = "remote-signer-ca"
+	flagRemoteSignerCert    = "remote-signer-cert"
+	flagRemoteSignerKey     = "remote-signer-key"
+	flagOperatorKeyFile     = "operator-key-file"
+	flagKeystorePassword    = "keystore-password"
+	flagOperatorKeyPassword = "operator-key-password"
+	flagStoragePassword     = "storage-password"
+	flagLogFile             = "log-file"
+	flagLogLevel            = "log-level"
+)
+
+// appFlags are the node flags. Defaults live in defaultAppParams so that a flag that isn't
+// set doesn't override the config file.
+func appFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.StringFlag{
+			Name:    flagConfig,
+			Aliases: []string{"c"},
+			Usage:   "path to a YAML config file",
+			EnvVars: []string{"NODE_CONFIG"},
+		},
+		&cli.Uint64Flag{
+			Name:    flagOperatorID,
+			Usage:   "operator ID of this node in the operator registry, looked up by the keystore address when not set",
+			EnvVars: []string{"NODE_OPERATOR_ID"},
+		},
+		&cli.StringFlag{
+			Name:    flagDataDir,
+			Usage:   "directory of the node database (default /frost-dkg-data)",
+			EnvVars: []string{"NODE_DATA_DIR"},
+		},
+		&cli.StringFlag{
+			Name:    flagDBBackend,
+			Usage:   "database backend, badger, sqlite or memory, which keeps nothing across restarts (default badger)",
+			EnvVars: []string{"NODE_DB_BACKEND"},
+		},
+		&cli.StringFlag{
+			Name:    flagListenAddr,
+			Usage:   "address the node api listens on (default 0.0.0.0:8080)",
+			EnvVars: []string{"NODE_ADDR"},
+		},
+		&cli.StringFlag{
+			Name:    flagAdminSocket,
+			Usage:   "unix socket the admin and backup endpoints are served on (default admin.sock in the data dir)",
+			EnvVars: []string{"NODE_ADMIN_SOCKET"},
+		},
+		&cli.StringFlag{
+			Name:    flagBroadcastAddr,
+			Usage:   "address the messenger pushes messages to",
+			EnvVars: []string{"NODE_BROADCAST_ADDR"},
+		},
+		&cli.StringFlag{
+			Name:    flagTLSCert,
+			Usage:   "TLS certificate file, serves the node api over https together with --tls-key",
+			EnvVars: []string{"NODE_TLS_CERT"},
+		},
+		&cli.StringFlag{
+			Name:    flagTLSKey,
+			Usage:   "TLS private key file",
+			EnvVars: []string{"NODE_TLS_KEY"},
+		},
+		&cli.StringFlag{
+			Name:    flagNetwork,
+			Usage:   "network the node runs ceremonies for, mainnet, holesky, hoodi, a network of the networks file or custom (default hoodi)",
+			EnvVars: []string{"DKG_NETWORK"},
+		},
+		&cli.StringFlag{
+			Name:    flagNetworksFile,
+			Usage:   "YAML file defining custom networks that can be selected with --network",
+			EnvVars: []string{"DKG_NETWORKS_FILE"},
+		},
+		&cli.StringFlag{
+			Name:    flagCustomFork,
+			Usage:   "genesis fork version in hex, for the custom network",
+			EnvVars: []string{"DKG_CUSTOM_FORK_VERSION"},
+		},
+		&cli.StringFlag{
+			Name:    flagCustomDomain,
+			Usage:   "SSV domain type in hex, for the custom network",
+			EnvVars: []string{"DKG_CUSTOM_DOMAIN_TYPE"},
+		},
+		&cli.StringFlag{
+			Name:    flagRegistryURL,
+			Usage:   "base URL of the operator registry api (default the registry of the network)",
+			EnvVars: []string{"OPERATOR_REGISTRY_URL"},
+		},
+		&cli.DurationFlag{
+			Name:    flagOperatorCacheTTL,
+			Usage:   "how long operators fetched from the registry are cached before they're fetched again (default 1h)",
+			EnvVars: []string{"OPERATOR_CACHE_TTL"},
+		},
+		&cli.StringFlag{
+			Name:    flagPolicyFile,
+			Usage:   "YAML file with the policy deciding which keygen and reshare ceremonies the node joins",
+			EnvVars: []string{"NODE_POLICY_FILE"},
+		},
+		&cli.IntFlag{
+			Name:    flagMaxCeremonies,
+			Usage:   "how many ceremonies the node runs at once, messages of more ceremonies are answered with 429 (default 32)",
+			EnvVars: []string{"NODE_MAX_CEREMONIES"},
+		},
+		&cli.IntFlag{
+			Name:    flagCeremonyQueueSize,
+			Usage:   "how many messages of a ceremony wait to be processed, more are answered with 429 (default 64)",
+			EnvVars: []string{"NODE_CEREMONY_QUEUE_SIZE"},
+		},
+		&cli.DurationFlag{
+			Name:    flagRoundTimeout,
+			Usage:   "how long a ceremony waits on the messages of a round before it times out and streams the operators it waited on (default 5m)",
+			EnvVars: []string{"NODE_ROUND_TIMEOUT"},
+		},
+		&cli.StringFlag{
+			Name:    flagMessengerAddr,
+			Usage:   "address of the messenger",
+			EnvVars: []string{"MESSENGER_SRV_ADDR"},
+		},
+		&cli.StringFlag{
+			Name:    flagDeliveryMode,
+			Usage:   "how the messenger delivers messages, push or pull (default push)",
+			EnvVars: []string{"NODE_DELIVERY_MODE"},
+		},
+		&cli.StringFlag{
+			Name:    flagTransport,
+			Usage:   "dkg transport, messenger or mesh (default messenger)",
+			EnvVars: []string{"DKG_TRANSPORT"},
+		},
+		&cli.StringFlag{
+			Name:    flagMeshAddressBook,
+			Usage:   "JSON file mapping operator IDs to node addresses, for the mesh transport",
+			EnvVars: []string{"MESH_ADDRESS_BOOK"},
+		},
+		&cli.StringFlag{
+			Name:    flagMeshSink,
+			Usage:   "where the mesh transport sends ceremony results, messenger or file:<dir>",
+			EnvVars: []string{"MESH_SINK"},
+		},
+		&cli.StringFlag{
+			Name:    flagSigner,
+			Usage:   "where the operator's ethereum key is held, local for the keystore file or remote for a remote signer (default local)",
+			EnvVars: []string{"NODE_SIGNER"},
+		},
+		&cli.StringFlag{
+			Name:    flagKeystoreFile,
+			Usage:   "path to the operator's ethereum keystore file (default keystore.json)",
+			EnvVars: []string{"KEYSTORE_FILE_PATH"},
+		},
+		&cli.StringFlag{
+			Name:    flagRemoteSignerURL,
+			Usage:   "URL of the clef external api holding the operator key",
+			EnvVars: []string{"REMOTE_SIGNER_URL"},
+		},
+		&cli.StringFlag{
+			Name:    flagRemoteSignerAddress,
+			Usage:   "ethereum address of the operator key held by the remote signer",
+			EnvVars: []string{"REMOTE_SIGNER_ADDRESS"},
+		},
+		&cli.StringFlag{
+			Name:    flagRemoteSignerCA,
+			Usage:   "CA certificate file the remote signer's certificate is verified with",
+			EnvVars: []string{"REMOTE_SIGNER_CA"},
+		},
+		&cli.StringFlag{
+			Name:    flagRemoteSignerCert,
+			Usage:   "client certificate file the node authenticates to the remote signer with",
+			EnvVars: []string{"REMOTE_SIGNER_CERT"},
+		},
+		&cli.StringFlag{
+			Name:    flagRemoteSignerKey,
+			Usage:   "client key file of the remote signer client certificate",
+			EnvVars: []string{"REMOTE_SIGNER_KEY"},
+		},
+		&cli.StringFlag{
+			Name:    flagOperatorKeyFile,
+			Usage:   "operator RSA key, an SSV encrypted operator key file or a PEM file",
+			EnvVars: []string{"OPERATOR_KEY_FILE"},
+		},
+		&cli.StringFlag{
+			Name:    flagKeystorePassword,
+			Usage:   "where the keystore password is read from: env:NAME, file:PATH, prompt or vault:PATH#FIELD (default env:KEYSTORE_PASSWORD)",
+			EnvVars: []string{"KEYSTORE_PASSWORD_SOURCE"},
+		},
+		&cli.StringFlag{
+			Name:    flagOperatorKeyPassword,
+			Usage:   "where the operator key password is read from, like --keystore-password (default env:OPERATOR_KEY_PASSWORD)",
+			EnvVars: []string{"OPERATOR_KEY_PASSWORD_SOURCE"},
+		},
+		&cli.StringFlag{
+			Name:    flagStoragePassword,
+			Usage:   "where the password that encrypts shares at rest is read from, like --keystore-password (default a key derived from the keystore)",
+			EnvVars: []string{"STORAGE_PASSWORD_SOURCE"},
+		},
+		&cli.StringFlag{
+			Name:    flagLogFile,
+			Usage:   "log file (default /var/log/dkg_node.log)",
+			EnvVars: []string{"DKG_LOG_FILE"},
+		},
+		&cli.StringFlag{
+			Name:    flagLogLevel,
+			Usage:   "log level, debug, info, warn or error (default debug)",
+			EnvVars: []string{"DKG_LOG_LEVEL"},
+		},
+	}
+}
@@ -1,22 +1,32 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	stdlog "log"
+	"net"
+	"net/http"
 	"os"
 	"strconv"
+	"time"
 
 	"github.com/RockX-SG/frost-dkg-demo/internal/keymanager"
 	"github.com/RockX-SG/frost-dkg-demo/internal/logger"
+	"github.com/RockX-SG/frost-dkg-demo/internal/mesh"
 	"github.com/RockX-SG/frost-dkg-demo/internal/messenger"
+	"github.com/RockX-SG/frost-dkg-demo/internal/network"
 	"github.com/RockX-SG/frost-dkg-demo/internal/node"
 	"github.com/RockX-SG/frost-dkg-demo/internal/ping"
+	"github.com/RockX-SG/frost-dkg-demo/internal/policy"
 	store "github.com/RockX-SG/frost-dkg-demo/internal/storage"
 
 	"github.com/bloxapp/ssv-spec/dkg"
 	"github.com/bloxapp/ssv-spec/dkg/frost"
 	"github.com/bloxapp/ssv-spec/types"
-	"github.com/dgraph-io/badger/v3"
 	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/urfave/cli/v2"
 )
 
 func init() {
@@ -24,72 +34,301 @@ func init() {
 }
 
 func main() {
-	log := logger.New("/var/log/dkg_node.log")
+	app := &cli.App{
+		Name:   "rockx-dkg-node",
+		Usage:  "A DKG operator node that takes part in keygen and resharing ceremonies",
+		Flags:  appFlags(),
+		Action: run,
+		Commands: []*cli.Command{
+			rotateShareKeyCommand(),
+			migrateCommand(),
+			backupCommand(),
+			restoreCommand(),
+		},
+	}
+
+	if err := app.Run(os.Args); err != nil {
+		stdlog.Fatal(err)
+	}
+}
+
+func run(c *cli.Context) error {
+	params, err := loadAppParams(c)
+	if err != nil {
+		return err
+	}
+
+	log := logger.New(params.LogFile)
+	level, _ := params.logLevel()
+	log.SetLevel(level)
+	log.Infof("node config: %s", params.print())
 
-	params := &AppParams{}
-	params.loadFromEnv()
-	log.Debugf("app env: %s messenger: %s", params.print(), messenger.MessengerAddrFromEnv())
+	store.RegistryURL = params.RegistryURL
 
 	// set up db for storage
-	db, err := setupDB()
+	db, err := setupDB(params)
 	if err != nil {
-		log.Errorf("Main: failed to setup DB: %w", err)
+		log.Errorf("Main: failed to setup DB: %v", err)
 		panic(err)
 	}
 	defer db.Close()
+	if params.DBBackend == store.BackendMemory {
+		log.Warn("Main: the database is kept in memory, shares are lost when the node stops")
+	}
 	storage := store.NewStorage(db)
+	report, err := storage.Migrate(false)
+	if err != nil {
+		log.Errorf("Main: failed to migrate the database: %v", err)
+		return err
+	}
+	log.Infof("Main: %s", report)
+	storage.RegisterMetrics()
+	storage.SetOperatorCacheTTL(params.OperatorCacheTTL)
+	storage.OnOperatorKeyChange(func(operatorID types.OperatorID) {
+		log.Warnf("Main: the registry returned another RSA key for operator %d than the pinned one, its ceremonies are refused until the key is accepted on /admin/operators/%d/refresh?accept_key_change=true", operatorID, operatorID)
+	})
 
-	// TODO: add a check to verify the node operator is a valid node operator
-	operatorPrivateKey, err := params.loadDecryptedPrivateKey()
+	ethSigner, operatorPrivateKey, err := params.loadETHSigner()
 	if err != nil {
-		log.Errorf("Main: failed to load decrypted private key: %w", err)
-		panic(err)
+		log.Errorf("Main: failed to load the operator ethereum key: %v", err)
+		return err
+	}
+	operatorAddress := ethSigner.Address()
+
+	kek, err := params.loadShareKEK(operatorPrivateKey)
+	if err != nil {
+		log.Errorf("Main: %v", err)
+		return err
+	}
+	migrated, err := storage.EnableShareEncryption(kek)
+	kek.Zero()
+	if err != nil {
+		log.Errorf("Main: failed to enable share encryption: %v", err)
+		return err
+	}
+	if migrated > 0 {
+		log.Infof("Main: encrypted %d shares that were stored in plaintext", migrated)
 	}
-	signer := keymanager.NewKeyManager(types.PrimusTestnet, operatorPrivateKey)
 
-	network := messenger.NewMessengerClient(messenger.MessengerAddrFromEnv())
+	if params.OperatorID == 0 {
+		ids, err := store.FindOperatorIDsByOwner(operatorAddress)
+		if err != nil {
+			log.Errorf("Main: failed to look up the operators owned by %s: %v", operatorAddress.Hex(), err)
+			return err
+		}
+		if params.OperatorID, err = node.SelectOperatorID(operatorAddress, ids); err != nil {
+			log.Errorf("Main: %v", err)
+			return err
+		}
+		log.Infof("Main: running as operator %d owned by %s", params.OperatorID, operatorAddress.Hex())
+	}
+
+	operatorKey, err := params.loadOperatorKey()
+	if err != nil {
+		log.Errorf("Main: failed to load operator RSA key: %v", err)
+		return err
+	}
+	if operatorKey == nil {
+		log.Warnf("Main: no operator RSA key configured, the node can't check its identity or its encrypted shares")
+	}
+	signer := keymanager.NewKeyManager(params.network, ethSigner, operatorKey)
+	loaded, err := node.LoadShares(storage, signer)
+	if err != nil {
+		log.Errorf("Main: failed to load the stored shares: %v", err)
+		return err
+	}
+	log.Infof("Main: loaded %d stored shares into the signer", loaded)
+
+	h := node.New(log, params.network)
+	var engine *policy.Engine
+	if params.PolicyFile != "" {
+		ceremonyPolicy, err := policy.Load(params.PolicyFile)
+		if err != nil {
+			log.Errorf("Main: %v", err)
+			return err
+		}
+		engine, err = policy.NewEngine(ceremonyPolicy, storage)
+		if err != nil {
+			log.Errorf("Main: invalid policy file %s: %v", params.PolicyFile, err)
+			return err
+		}
+		log.Infof("Main: evaluating ceremonies against the policy of %s", params.PolicyFile)
+	}
+
+	messengerClient := messenger.NewMessengerClient(params.MessengerAddress)
+	var dkgNetwork dkg.Network = messengerClient
+	if params.Transport == TransportMesh {
+		transport, err := setupMeshTransport(params, log)
+		if err != nil {
+			log.Errorf("Main: failed to set up mesh transport: %v", err)
+			panic(err)
+		}
+		h.WithMessageObserver(transport)
+		dkgNetwork = transport
+	}
+
+	timedOut, err := storage.TimeoutCeremonies(time.Now())
+	if err != nil {
+		log.Errorf("Main: failed to time out the ceremonies interrupted by the last shutdown: %v", err)
+		return err
+	}
+	if timedOut > 0 {
+		log.Warnf("Main: %d ceremonies were interrupted by the last shutdown", timedOut)
+	}
+	audit := node.NewAuditLog(dkgNetwork, storage, log)
+	h.WithAuditLog(audit)
+	ceremonies := node.NewCeremonyRecorder(
+		node.NewInstrumentedNetwork(node.NewShareCheckingNetwork(node.NewShareOriginNetwork(audit, storage, log), params.OperatorID, signer, log)),
+		storage,
+		log,
+	)
+	h.WithCeremonyRecorder(ceremonies)
+	if engine != nil {
+		h.WithPolicy(engine, params.OperatorID, ceremonies)
+	}
+	go ceremonies.RunTimeouts(time.Minute)
 
 	config := &dkg.Config{
 		KeygenProtocol:      frost.New,
 		ReshareProtocol:     frost.NewResharing,
-		Network:             network,
+		Network:             ceremonies,
 		Signer:              signer,
 		Storage:             storage,
-		SignatureDomainType: types.PrimusTestnet,
+		SignatureDomainType: network.DKGSignatureDomain,
 	}
 
 	thisOperator, err := thisOperator(uint32(params.OperatorID), storage)
 	if err != nil {
-		log.Errorf("Main: failed to get operator %d from operator registry: %w", err)
+		log.Errorf("Main: failed to get operator %d from operator registry: %v", params.OperatorID, err)
 		panic(err)
 	}
+
+	if err := node.CheckIdentity(thisOperator, operatorAddress, signer); err != nil {
+		log.Errorf("Main: identity check failed: %v", err)
+		return err
+	}
+
 	dkgnode := dkg.NewNode(thisOperator, config)
+	dispatcher := node.NewDispatcher(thisOperator, config, params.MaxCeremonies, params.CeremonyQueueSize, params.RoundTimeout, log)
 
-	// register dkg operator node with the messenger
-	if err := network.RegisterOperatorNode(strconv.Itoa(int(params.OperatorID)), os.Getenv("NODE_BROADCAST_ADDR")); err != nil {
-		log.Errorf("Main: %w", err)
-		panic(err)
+	if params.Transport == TransportMessenger {
+		// register dkg operator node with the messenger
+		if err := messengerClient.RegisterOperatorNode(strconv.Itoa(int(params.OperatorID)), params.BroadcastAddress, params.DeliveryMode); err != nil {
+			log.Errorf("Main: %v", err)
+			panic(err)
+		}
+
+		if params.DeliveryMode == messenger.DeliveryModePull {
+			// stop pulling once the api stops serving
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			go h.RunPullLoop(ctx, dispatcher, messengerClient, strconv.Itoa(int(params.OperatorID)))
+		}
 	}
 
-	h := node.New(log)
+	health := newHealthChecker(params, nodeChecks{
+		database:     storage.Ping,
+		remoteSigner: ethSigner.Check,
+		keystore: func() error {
+			return params.checkKeystore(operatorAddress)
+		},
+		registry: func() error {
+			return store.CheckRegistryReachable(params.OperatorID)
+		},
+		messenger: func() error {
+			return messengerClient.IsRegistered(strconv.Itoa(int(params.OperatorID)))
+		},
+	})
 
 	// register api routes
 	r := gin.Default()
 	r.Use(logger.GinLogger(log))
 
 	r.GET("/ping", ping.HandlePing)
+	r.GET("/healthz", health.HandleHealthz())
+	r.GET("/readyz", health.HandleReadyz())
+	r.GET("/metrics", gin.WrapH(promhttp.Handler()))
 
 	// handle incoming message
-	r.POST("/consume", h.HandleConsume(dkgnode))
+	r.POST("/consume", h.HandleConsume(dispatcher))
 
 	// get dkg results
 	r.GET("/dkg_results/:vk", h.HandleGetDKGResults(dkgnode))
 
-	panic(r.Run(params.HttpAddress))
+	// list the validator shares held by the node
+	r.GET("/shares", h.HandleListShares(storage))
+
+	// ceremonies the node took part in
+	r.GET("/ceremonies", h.HandleListCeremonies(storage))
+	r.GET("/ceremonies/:request_id", h.HandleGetCeremony(storage))
+	r.GET("/ceremonies/:request_id/audit", h.HandleExportAudit(storage))
+
+	// database backup for the backup command and operator cache administration, served on the
+	// admin socket only
+	adminListener, err := node.ListenAdmin(params.adminSocketPath())
+	if err != nil {
+		log.Errorf("Main: failed to listen on the admin socket: %v", err)
+		return err
+	}
+	defer adminListener.Close()
+	adminRouter := gin.New()
+	adminRouter.Use(gin.Recovery(), logger.GinLogger(log))
+	adminRouter.POST("/backup", h.HandleBackup(storage))
+	admin := adminRouter.Group("/admin")
+	admin.DELETE("/operators", h.HandleInvalidateOperators(storage))
+	admin.DELETE("/operators/:operator_id", h.HandleInvalidateOperator(storage))
+	admin.POST("/operators/:operator_id/refresh", h.HandleRefreshOperator(storage))
+	go func() {
+		if err := http.Serve(adminListener, adminRouter); err != nil && !errors.Is(err, net.ErrClosed) {
+			log.Errorf("Main: admin socket stopped serving: %v", err)
+		}
+	}()
+
+	if params.usesTLS() {
+		return r.RunTLS(params.HttpAddress, params.TLSCertFile, params.TLSKeyFile)
+	}
+	return r.Run(params.HttpAddress)
 }
 
-func setupDB() (*badger.DB, error) {
-	return badger.Open(badger.DefaultOptions("/frost-dkg-data"))
+// nodeChecks are the checks of what the node depends on
+type nodeChecks struct {
+	database     func() error
+	keystore     func() error
+	remoteSigner func() error
+	registry     func() error
+	messenger    func() error
+}
+
+// newHealthChecker sets up the checks of /healthz and /readyz that apply to the configuration
+func newHealthChecker(params *AppParams, checks nodeChecks) *node.HealthChecker {
+	health := node.NewHealthChecker()
+	health.AddLivenessCheck("database", checks.database)
+	if params.Signer == SignerRemote {
+		health.AddReadinessCheck("remote_signer", checks.remoteSigner)
+	} else {
+		health.AddLivenessCheck("keystore", checks.keystore)
+	}
+	health.AddReadinessCheck("registry", checks.registry)
+	if params.Transport == TransportMessenger {
+		health.AddReadinessCheck("messenger", checks.messenger)
+	}
+	return health
+}
+
+func setupDB(params *AppParams) (store.Backend, error) {
+	return store.OpenBackend(params.DBBackend, params.DataDir)
+}
+
+func setupMeshTransport(params *AppParams, log *logger.Logger) (*mesh.Transport, error) {
+	peers, err := mesh.LoadAddressBook(params.MeshAddressBook)
+	if err != nil {
+		return nil, err
+	}
+	sink, err := mesh.NewSink(params.MeshSink, params.MessengerAddress)
+	if err != nil {
+		return nil, err
+	}
+	return mesh.NewTransport(params.OperatorID, peers, sink, log), nil
 }
 
 func thisOperator(operatorID uint32, storage dkg.Storage) (*dkg.Operator, error) {
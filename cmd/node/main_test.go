@@ -0,0 +1,54 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewHealthChecker(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	failing := errors.New("unreachable")
+	ok := func() error { return nil }
+	checks := nodeChecks{
+		database:     ok,
+		keystore:     ok,
+		remoteSigner: func() error { return failing },
+		registry:     ok,
+		messenger:    func() error { return failing },
+	}
+	readyz := func(params *AppParams) (int, map[string]string) {
+		r := gin.New()
+		r.GET("/readyz", newHealthChecker(params, checks).HandleReadyz())
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+		resp := struct {
+			Checks map[string]string `json:"checks"`
+		}{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		return w.Code, resp.Checks
+	}
+
+	// with the messenger transport the node isn't ready while the messenger is unreachable
+	params := defaultAppParams()
+	code, results := readyz(params)
+	require.Equal(t, http.StatusServiceUnavailable, code)
+	require.Equal(t, map[string]string{"database": "ok", "keystore": "ok", "registry": "ok", "messenger": "unreachable"}, results)
+
+	// the mesh transport doesn't use the messenger
+	params.Transport = TransportMesh
+	code, results = readyz(params)
+	require.Equal(t, http.StatusOK, code)
+	require.Equal(t, map[string]string{"database": "ok", "keystore": "ok", "registry": "ok"}, results)
+
+	// a remote signer is checked instead of the keystore
+	params.Signer = SignerRemote
+	code, results = readyz(params)
+	require.Equal(t, http.StatusServiceUnavailable, code)
+	require.Equal(t, map[string]string{"database": "ok", "remote_signer": "unreachable", "registry": "ok"}, results)
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"fmt"
+
+	store "github.com/RockX-SG/frost-dkg-demo/internal/storage"
+	"github.com/urfave/cli/v2"
+)
+
+const flagDryRun = "dry-run"
+
+func migrateCommand() *cli.Command {
+	return &cli.Command{
+		Name:  "migrate",
+		Usage: "upgrade the database to the storage schema of this node, which the node also does on startup; the node must be stopped",
+		Flags: []cli.Flag{
+			&cli.BoolFlag{
+				Name:  flagDryRun,
+				Usage: "report the migrations that would run without changing the database",
+			},
+		},
+		Action: migrate,
+	}
+}
+
+func migrate(c *cli.Context) error {
+	params, err := loadAppParams(c)
+	if err != nil {
+		return err
+	}
+
+	db, err := setupDB(params)
+	if err != nil {
+		return fmt.Errorf("failed to open the database: %v", err)
+	}
+	defer db.Close()
+
+	report, err := store.NewStorage(db).Migrate(c.Bool(flagDryRun))
+	if err != nil {
+		return err
+	}
+	fmt.Println(report)
+	return nil
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"errors"
+	"fmt"
+
+	"github.com/RockX-SG/frost-dkg-demo/internal/secrets"
+	store "github.com/RockX-SG/frost-dkg-demo/internal/storage"
+	"github.com/urfave/cli/v2"
+)
+
+const (
+	flagNewStoragePassword = "new-storage-password"
+	flagDataKey            = "data-key"
+)
+
+func rotateShareKeyCommand() *cli.Command {
+	return &cli.Command{
+		Name:  "rotate-share-key",
+		Usage: "rotate the keys shares are encrypted at rest with, the node must be stopped",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:  flagNewStoragePassword,
+				Usage: "secret source of the new storage password, or keystore to derive the key from the keystore key",
+			},
+			&cli.BoolFlag{
+				Name:  flagDataKey,
+				Usage: "also re-encrypt every share with a new data key",
+			},
+		},
+		Action: rotateShareKey,
+	}
+}
+
+// rotateShareKey rewraps the share data key with a new storage password or the keystore key,
+// and with --data-key re-encrypts the shares with a new data key
+func rotateShareKey(c *cli.Context) error {
+	params, err := loadAppParams(c)
+	if err != nil {
+		return err
+	}
+	newPassword := c.String(flagNewStoragePassword)
+	if newPassword == "" && !c.Bool(flagDataKey) {
+		return fmt.Errorf("nothing to rotate, set --%s and/or --%s", flagNewStoragePassword, flagDataKey)
+	}
+
+	db, err := setupDB(params)
+	if err != nil {
+		return fmt.Errorf("failed to open the database: %v", err)
+	}
+	defer db.Close()
+	storage := store.NewStorage(db)
+	if _, err := storage.Migrate(false); err != nil {
+		return err
+	}
+
+	var key *ecdsa.PrivateKey
+	if params.storagePassword == nil || newPassword == store.KEKKeystore {
+		if params.Signer == SignerRemote {
+			return errors.New("the keystore key isn't available with a remote signer")
+		}
+		if key, err = params.loadDecryptedPrivateKey(); err != nil {
+			return fmt.Errorf("failed to load the keystore key: %v", err)
+		}
+	}
+
+	kek, err := params.loadShareKEK(key)
+	if err != nil {
+		return err
+	}
+	defer kek.Zero()
+	if _, err := storage.EnableShareEncryption(kek); err != nil {
+		return err
+	}
+
+	newKEK := kek
+	switch newPassword {
+	case "":
+	case store.KEKKeystore:
+		newKEK = store.KeystoreKEK(key)
+	default:
+		source, err := secrets.Parse(newPassword, "New storage password: ")
+		if err != nil {
+			return err
+		}
+		password, err := source.Secret()
+		if err != nil {
+			return fmt.Errorf("failed to read the new storage password: %v", err)
+		}
+		newKEK = store.PasswordKEK(password)
+		secrets.Zero(password)
+	}
+	defer newKEK.Zero()
+
+	if c.Bool(flagDataKey) {
+		rotated, err := storage.RotateShareDataKey(newKEK)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("re-encrypted %d shares with a new data key\n", rotated)
+	} else {
+		if err := storage.RotateShareKEK(newKEK); err != nil {
+			return err
+		}
+		fmt.Println("wrapped the share data key with the new key")
+	}
+	if newPassword != "" {
+		fmt.Printf("configure the node with the new storage password (storage_password or --%s) before starting it\n", flagStoragePassword)
+	}
+	return nil
+}
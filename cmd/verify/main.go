@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"os"
 
+	"github.com/RockX-SG/frost-dkg-demo/internal/network"
 	"github.com/bloxapp/ssv-spec/types"
 	"github.com/herumi/bls-eth-go-binary/bls"
 )
@@ -20,12 +21,18 @@ func init() {
 }
 
 func main() {
-	fork := types.NetworkFromString(os.Args[1])
+	// the network is either a known network name, one from the networks file or the hex fork version of a custom one
+	checkErr(network.LoadFileFromEnv())
+	net, err := network.FromName(os.Args[1])
+	if err != nil {
+		net, err = network.NewCustom(os.Args[1], "", "")
+	}
+	checkErr(err)
 	pkbytes, _ := hex.DecodeString(os.Args[2])
 	depositSig := os.Args[3]
 	withdrawalCredentials, _ := hex.DecodeString(os.Args[4])
 
-	signingRoot, _, err := types.GenerateETHDepositData(pkbytes, withdrawalCredentials, fork.ForkVersion(), types.DomainDeposit)
+	signingRoot, _, err := types.GenerateETHDepositData(pkbytes, withdrawalCredentials, net.ForkVersion, types.DomainDeposit)
 	checkErr(err)
 
 	var (
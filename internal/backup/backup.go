@@ -0,0 +1,177 @@
+package backup
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	store "github.com/RockX-SG/frost-dkg-demo/internal/storage"
+	"golang.org/x/crypto/scrypt"
+)
+
+// A backup file is the magic line, the manifest as a JSON line and the database backup,
+// encrypted with AES-256-GCM under a key derived from the backup password. The magic and the
+// manifest are authenticated with the database backup.
+const (
+	magic   = "frost-dkg-node-backup\n"
+	Version = 2
+	// VersionBadger backups hold the database in badger's backup format, as written by nodes
+	// before the storage backends. They can still be restored.
+	VersionBadger = 1
+
+	keyLen  = 32
+	scryptN = 1 << 15
+	scryptR = 8
+	scryptP = 1
+)
+
+// Manifest describes the content of a backup
+type Manifest struct {
+	Version       int         `json:"version"`
+	CreatedAt     time.Time   `json:"created_at"`
+	SchemaVersion int         `json:"schema_version"`
+	Counts        store.Stats `json:"counts"`
+	// Size and SHA256 are the size and checksum of the database backup before encryption
+	Size   int          `json:"size"`
+	SHA256 string       `json:"sha256"`
+	KDF    scryptParams `json:"kdf"`
+}
+
+type scryptParams struct {
+	Salt string `json:"salt"`
+	N    int    `json:"n"`
+	R    int    `json:"r"`
+	P    int    `json:"p"`
+}
+
+// NewManifest describes a database backup with the counts of the records it holds
+func NewManifest(data []byte, schemaVersion int, counts *store.Stats) *Manifest {
+	checksum := sha256.Sum256(data)
+	return &Manifest{
+		Version:       Version,
+		CreatedAt:     time.Now().UTC(),
+		SchemaVersion: schemaVersion,
+		Counts:        *counts,
+		Size:          len(data),
+		SHA256:        hex.EncodeToString(checksum[:]),
+	}
+}
+
+// Write encrypts the database backup with the password and writes it with its manifest to w
+func Write(w io.Writer, manifest *Manifest, data, password []byte) error {
+	salt := make([]byte, keyLen)
+	if _, err := rand.Read(salt); err != nil {
+		return err
+	}
+	manifest.KDF = scryptParams{Salt: hex.EncodeToString(salt), N: scryptN, R: scryptR, P: scryptP}
+	header, err := encodeHeader(manifest)
+	if err != nil {
+		return err
+	}
+
+	aead, err := manifest.KDF.aead(password)
+	if err != nil {
+		return err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+
+	if _, err := w.Write(header); err != nil {
+		return err
+	}
+	if _, err := w.Write(nonce); err != nil {
+		return err
+	}
+	_, err = w.Write(aead.Seal(nil, nonce, data, header))
+	return err
+}
+
+// Read decrypts a backup written by Write and checks the database backup against its manifest
+func Read(backup, password []byte) (*Manifest, []byte, error) {
+	manifest, header, err := readHeader(backup)
+	if err != nil {
+		return nil, nil, err
+	}
+	sealed := backup[len(header):]
+
+	aead, err := manifest.KDF.aead(password)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(sealed) < aead.NonceSize() {
+		return nil, nil, errors.New("backup is truncated")
+	}
+	data, err := aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], header)
+	if err != nil {
+		return nil, nil, errors.New("failed to decrypt the backup, the password is wrong or the backup is corrupted")
+	}
+
+	checksum := sha256.Sum256(data)
+	if len(data) != manifest.Size || hex.EncodeToString(checksum[:]) != manifest.SHA256 {
+		return nil, nil, errors.New("backup doesn't match the checksum of its manifest")
+	}
+	return manifest, data, nil
+}
+
+// readHeader parses the manifest of a backup and returns it with the header it was read from
+func readHeader(backup []byte) (*Manifest, []byte, error) {
+	if !bytes.HasPrefix(backup, []byte(magic)) {
+		return nil, nil, errors.New("not a node backup")
+	}
+	end := bytes.IndexByte(backup[len(magic):], '\n')
+	if end < 0 {
+		return nil, nil, errors.New("backup manifest is truncated")
+	}
+	header := backup[:len(magic)+end+1]
+
+	manifest := &Manifest{}
+	if err := json.Unmarshal(header[len(magic):], manifest); err != nil {
+		return nil, nil, fmt.Errorf("failed to parse the backup manifest: %v", err)
+	}
+	if manifest.Version != Version && manifest.Version != VersionBadger {
+		return nil, nil, fmt.Errorf("unsupported backup version %d", manifest.Version)
+	}
+	if manifest.KDF.N != scryptN || manifest.KDF.R != scryptR || manifest.KDF.P != scryptP {
+		return nil, nil, errors.New("unsupported backup key derivation parameters")
+	}
+	return manifest, header, nil
+}
+
+func encodeHeader(manifest *Manifest) ([]byte, error) {
+	manifestJSON, err := json.Marshal(manifest)
+	if err != nil {
+		return nil, err
+	}
+	return append(append([]byte(magic), manifestJSON...), '\n'), nil
+}
+
+func (p scryptParams) aead(password []byte) (cipher.AEAD, error) {
+	salt, err := hex.DecodeString(p.Salt)
+	if err != nil {
+		return nil, err
+	}
+	key, err := scrypt.Key(password, salt, p.N, p.R, p.P, keyLen)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		for i := range key {
+			key[i] = 0
+		}
+	}()
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
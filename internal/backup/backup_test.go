@@ -0,0 +1,55 @@
+package backup
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	store "github.com/RockX-SG/frost-dkg-demo/internal/storage"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteRead(t *testing.T) {
+	data := []byte("database backup")
+	counts := &store.Stats{KeyGenOutputs: 2, Total: 3}
+	file := &bytes.Buffer{}
+	require.NoError(t, Write(file, NewManifest(data, 1, counts), data, []byte("backup password")))
+	require.NotContains(t, file.String(), string(data))
+
+	manifest, restored, err := Read(file.Bytes(), []byte("backup password"))
+	require.NoError(t, err)
+	require.Equal(t, data, restored)
+	require.Equal(t, *counts, manifest.Counts)
+	require.Equal(t, 1, manifest.SchemaVersion)
+
+	_, _, err = Read(file.Bytes(), []byte("wrong password"))
+	require.Error(t, err)
+
+	// the manifest is authenticated with the backup
+	tampered := bytes.Replace(file.Bytes(), []byte(`"keygen_outputs":2`), []byte(`"keygen_outputs":5`), 1)
+	_, _, err = Read(tampered, []byte("backup password"))
+	require.Error(t, err)
+
+	_, _, err = Read(file.Bytes()[:file.Len()-1], []byte("backup password"))
+	require.Error(t, err)
+	_, _, err = Read(data, []byte("backup password"))
+	require.Error(t, err)
+}
+
+func TestReadVersions(t *testing.T) {
+	data := []byte("badger backup")
+	manifest := NewManifest(data, 1, &store.Stats{})
+	manifest.Version = VersionBadger
+	file := &bytes.Buffer{}
+	require.NoError(t, Write(file, manifest, data, []byte("backup password")))
+	read, restored, err := Read(file.Bytes(), []byte("backup password"))
+	require.NoError(t, err)
+	require.Equal(t, VersionBadger, read.Version)
+	require.Equal(t, data, restored)
+
+	manifest.Version = Version + 1
+	file.Reset()
+	require.NoError(t, Write(file, manifest, data, []byte("backup password")))
+	_, _, err = Read(file.Bytes(), []byte("backup password"))
+	require.EqualError(t, err, fmt.Sprintf("unsupported backup version %d", Version+1))
+}
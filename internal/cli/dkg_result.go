@@ -2,6 +2,7 @@ package cli
 
 import (
 	"encoding/hex"
+	"sort"
 	"strconv"
 
 	"github.com/RockX-SG/frost-dkg-demo/internal/messenger"
@@ -12,6 +13,18 @@ import (
 type DKGResult struct {
 	Output map[types.OperatorID]SignedOutput `json:"output"`
 	Blame  *dkg.BlameOutput                  `json:"blame"`
+	// Timeout holds the timeouts the operators of a ceremony that stalled reported, and Stalled
+	// the operators whose messages they were missing
+	Timeout map[types.OperatorID]Timeout `json:"timeout,omitempty"`
+	Stalled []types.OperatorID           `json:"stalled,omitempty"`
+	// Rejected holds the reasons of the operators whose policy refused the ceremony
+	Rejected map[types.OperatorID][]string `json:"rejected,omitempty"`
+}
+
+type Timeout struct {
+	Round    string
+	Missing  []types.OperatorID
+	Deadline string
 }
 
 type Output struct {
@@ -32,6 +45,11 @@ func formatResults(data *messenger.DataStore) *DKGResult {
 	if data.BlameOutput != nil {
 		return formatBlameResults(data.BlameOutput)
 	}
+	if len(data.DKGOutputs) == 0 && (len(data.TimeoutOutputs) > 0 || len(data.Rejections) > 0) {
+		result := formatTimeoutResults(data.TimeoutOutputs)
+		result.Rejected = formatRejections(data.Rejections)
+		return result
+	}
 
 	output := make(map[types.OperatorID]SignedOutput)
 	for operatorID, signedOutput := range data.DKGOutputs {
@@ -56,3 +74,39 @@ func formatResults(data *messenger.DataStore) *DKGResult {
 func formatBlameResults(blameOutput *dkg.BlameOutput) *DKGResult {
 	return &DKGResult{Blame: blameOutput}
 }
+
+// formatTimeoutResults returns the timeouts of a ceremony, with nil Timeout and Stalled when
+// there are none
+func formatTimeoutResults(timeouts map[types.OperatorID]*messenger.TimeoutOutput) *DKGResult {
+	if len(timeouts) == 0 {
+		return &DKGResult{}
+	}
+	result := &DKGResult{Timeout: make(map[types.OperatorID]Timeout), Stalled: make([]types.OperatorID, 0)}
+	stalled := make(map[types.OperatorID]bool)
+	for operatorID, timeout := range timeouts {
+		result.Timeout[operatorID] = Timeout{
+			Round:    timeout.Round,
+			Missing:  timeout.Missing,
+			Deadline: timeout.Deadline,
+		}
+		for _, missing := range timeout.Missing {
+			if !stalled[missing] {
+				stalled[missing] = true
+				result.Stalled = append(result.Stalled, missing)
+			}
+		}
+	}
+	sort.Slice(result.Stalled, func(i, j int) bool { return result.Stalled[i] < result.Stalled[j] })
+	return result
+}
+
+func formatRejections(rejections map[types.OperatorID]*messenger.RejectionOutput) map[types.OperatorID][]string {
+	if len(rejections) == 0 {
+		return nil
+	}
+	rejected := make(map[types.OperatorID][]string)
+	for operatorID, rejection := range rejections {
+		rejected[operatorID] = rejection.Reasons
+	}
+	return rejected
+}
@@ -2,6 +2,7 @@ package cli
 
 import (
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/RockX-SG/frost-dkg-demo/internal/utils"
@@ -14,6 +15,15 @@ func (h *CliHandler) HandleGetData(c *cli.Context) error {
 	if err != nil {
 		return fmt.Errorf("HandleGetData: failed to get dkg result for requestID %s: %w", requestID, err)
 	}
+	for operatorID, reasons := range results.Rejected {
+		fmt.Printf("operator %d refused the ceremony: %s\n", operatorID, strings.Join(reasons, "; "))
+	}
+	if results.Timeout != nil {
+		fmt.Printf("ceremony timed out, stalled operators: %v\n", results.Stalled)
+		for operatorID, timeout := range results.Timeout {
+			fmt.Printf("operator %d timed out on its %s deadline in round %s waiting on operators %v\n", operatorID, timeout.Deadline, timeout.Round, timeout.Missing)
+		}
+	}
 	filepath := fmt.Sprintf("dkg_results_%s_%d.json", requestID, time.Now().Unix())
 	fmt.Printf("writing results to file: %s\n", filepath)
 	return utils.WriteJSON(filepath, results)
@@ -30,6 +30,9 @@ func (h *CliHandler) HandleGetDepositData(c *cli.Context) error {
 	if err != nil {
 		return fmt.Errorf("HandleGetDepositData: failed to get dkg result for requestID %s: %w", requestID, err)
 	}
+	if results.Timeout != nil {
+		return fmt.Errorf("HandleGetDepositData: ceremony timed out waiting on operators %v", results.Stalled)
+	}
 
 	// all operators will have same validatorPK in their result
 	var firstOperator types.OperatorID
@@ -40,7 +43,11 @@ func (h *CliHandler) HandleGetDepositData(c *cli.Context) error {
 
 	validatorPK, _ := hex.DecodeString(results.Output[firstOperator].Data.ValidatorPubKey)
 	withdrawalCredentials, _ := hex.DecodeString(c.String("withdrawal-credentials"))
-	fork := types.NetworkFromString(c.String("fork-version")).ForkVersion()
+	net, err := networkFromContext(c)
+	if err != nil {
+		return fmt.Errorf("HandleGetDepositData: %w", err)
+	}
+	fork := net.ForkVersion
 	amount := phase0.Gwei(types.MaxEffectiveBalanceInGwei)
 
 	_, depositData, err := types.GenerateETHDepositData(validatorPK, withdrawalCredentials, fork, types.DomainDeposit)
@@ -70,10 +77,15 @@ func (h *CliHandler) HandleGetDepositData(c *cli.Context) error {
 		DepositMessageRoot:    hex.EncodeToString(depositMsgRoot[:]),
 		DepositDataRoot:       hex.EncodeToString(depositDataRoot[:]),
 		ForkVersion:           hex.EncodeToString(fork[:]),
-		NetworkName:           c.String("fork-version"),
+		NetworkName:           net.Name,
 		DepositCliVersion:     "2.3.0",
 	}
 
+	fmt.Printf("deposit amount: %d gwei (32 %s)\n", amount, net.AmountUnit)
+	if net.DepositContract != "" {
+		fmt.Printf("deposit contract of %s: %s\n", net.Name, net.DepositContract)
+	}
+
 	filepath := fmt.Sprintf("deposit-data_%d.json", time.Now().UTC().Unix())
 	fmt.Printf("writing deposit data json to file %s\n", filepath)
 	return utils.WriteJSON(filepath, []DepositDataJson{depositDataJson})
@@ -9,6 +9,7 @@ import (
 	"strings"
 
 	"github.com/RockX-SG/frost-dkg-demo/internal/messenger"
+	"github.com/RockX-SG/frost-dkg-demo/internal/network"
 	"github.com/bloxapp/ssv-spec/dkg"
 	"github.com/bloxapp/ssv-spec/types"
 	"github.com/bloxapp/ssv-spec/types/testingutils"
@@ -62,7 +63,7 @@ type KeygenRequest struct {
 	Operators            map[types.OperatorID]string `json:"operators"`
 	Threshold            int                         `json:"threshold"`
 	WithdrawalCredential string                      `json:"withdrawal_credentials"`
-	ForkVersion          string                      `json:"fork_version"`
+	Network              *network.Network            `json:"-"`
 }
 
 func (request *KeygenRequest) allOperators() []types.OperatorID {
@@ -77,7 +78,11 @@ func (request *KeygenRequest) parseKeygenRequest(c *cli.Context) error {
 	request.Operators = make(map[types.OperatorID]string)
 	request.Threshold = c.Int("threshold")
 	request.WithdrawalCredential = c.String("withdrawal-credentials")
-	request.ForkVersion = c.String("fork-version")
+
+	var err error
+	if request.Network, err = networkFromContext(c); err != nil {
+		return err
+	}
 
 	operatorkv := c.StringSlice("operator")
 	for _, op := range operatorkv {
@@ -97,13 +102,11 @@ func (request *KeygenRequest) parseKeygenRequest(c *cli.Context) error {
 
 func (request *KeygenRequest) initMsgForKeygen(requestID dkg.RequestID) ([]byte, error) {
 	withdrawalCred, _ := hex.DecodeString(request.WithdrawalCredential)
-	forkVersion := types.NetworkFromString(request.ForkVersion).ForkVersion()
-
 	init := testingutils.InitMessageData(
 		request.allOperators(),
 		uint16(request.Threshold),
 		withdrawalCred,
-		forkVersion,
+		request.Network.ForkVersion,
 	)
 	initBytes, _ := init.Encode()
 
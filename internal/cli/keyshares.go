@@ -53,6 +53,10 @@ func (ks *KeyShares) ParseDKGResult(result *DKGResult) error {
 		return fmt.Errorf("ParseDKGResult: result contains blame output")
 	}
 
+	if result.Timeout != nil {
+		return fmt.Errorf("ParseDKGResult: ceremony timed out waiting on operators %v", result.Stalled)
+	}
+
 	if len(result.Output) == 0 {
 		return fmt.Errorf("ParseDKGResult: dkg result is empty")
 	}
@@ -0,0 +1,15 @@
+package cli
+
+import (
+	"github.com/RockX-SG/frost-dkg-demo/internal/network"
+	"github.com/urfave/cli/v2"
+)
+
+// networkFromContext resolves the --network flag, using --custom-fork-version for the custom network
+func networkFromContext(c *cli.Context) (*network.Network, error) {
+	name := c.String("network")
+	if name == network.Custom {
+		return network.NewCustom(c.String("custom-fork-version"), "", "")
+	}
+	return network.FromName(name)
+}
@@ -0,0 +1,40 @@
+package keymanager
+
+import (
+	"crypto/ecdsa"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// ETHSigner signs with the operator's ethereum key
+type ETHSigner interface {
+	// Address is the ethereum address of the key
+	Address() common.Address
+	// SignDigest signs a 32 byte digest, returning a 65 byte [R || S || V] signature with V 0 or 1.
+	// Local keys sign the digest as is, remote signers as an EIP-191 personal message.
+	SignDigest(digest []byte) ([]byte, error)
+	// Check returns an error if the key can't be used for signing
+	Check() error
+}
+
+type localSigner struct {
+	sk *ecdsa.PrivateKey
+}
+
+// NewLocalSigner creates a signer for a key held in process memory
+func NewLocalSigner(sk *ecdsa.PrivateKey) ETHSigner {
+	return &localSigner{sk: sk}
+}
+
+func (s *localSigner) Address() common.Address {
+	return crypto.PubkeyToAddress(s.sk.PublicKey)
+}
+
+func (s *localSigner) SignDigest(digest []byte) ([]byte, error) {
+	return crypto.Sign(digest, s.sk)
+}
+
+func (s *localSigner) Check() error {
+	return nil
+}
@@ -1,26 +1,68 @@
 package keymanager
 
 import (
-	"crypto/ecdsa"
 	"crypto/rand"
 	"crypto/rsa"
+	"encoding/hex"
 	"errors"
+	"fmt"
+	"sync"
 
+	"github.com/RockX-SG/frost-dkg-demo/internal/network"
 	"github.com/bloxapp/ssv-spec/types"
 	"github.com/ethereum/go-ethereum/common"
-	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/herumi/bls-eth-go-binary/bls"
 )
 
+// Signer is the dkg signer of the node, which also holds the operator's RSA key
+type Signer interface {
+	types.DKGSigner
+	// HasOperatorKey reports whether the operator's RSA key was loaded
+	HasOperatorKey() bool
+	// DecryptWithOperatorKey decrypts data encrypted to the operator's RSA public key
+	DecryptWithOperatorKey(cipher []byte) ([]byte, error)
+	// AddShare makes a BLS key share available to SignRoot
+	AddShare(share *bls.SecretKey) error
+}
+
 type keyManager struct {
-	Domain types.DomainType
-	sk     *ecdsa.PrivateKey
+	// Domain is the SSV domain of the network the node runs on
+	Domain    types.DomainType
+	ethSigner ETHSigner
+	rsaKey    *rsa.PrivateKey
+
+	mu     sync.RWMutex
+	shares map[string]*bls.SecretKey
 }
 
-func NewKeyManager(domain types.DomainType, privateKey *ecdsa.PrivateKey) types.DKGSigner {
+// NewKeyManager creates the key manager of the node. ethSigner signs with the operator's
+// ethereum key, locally or through a remote signer. operatorKey is the operator's RSA key and
+// may be nil, in which case nothing encrypted to the operator can be decrypted.
+func NewKeyManager(net *network.Network, ethSigner ETHSigner, operatorKey *rsa.PrivateKey) Signer {
 	return &keyManager{
-		Domain: domain,
-		sk:     privateKey,
+		Domain:    net.DomainType,
+		ethSigner: ethSigner,
+		rsaKey:    operatorKey,
+		shares:    make(map[string]*bls.SecretKey),
+	}
+}
+
+func (km *keyManager) AddShare(share *bls.SecretKey) error {
+	if share == nil {
+		return errors.New("share is nil")
 	}
+	km.mu.Lock()
+	defer km.mu.Unlock()
+	km.shares[hex.EncodeToString(share.GetPublicKey().Serialize())] = share
+	return nil
+}
+
+func (km *keyManager) HasOperatorKey() bool {
+	return km.rsaKey != nil
+}
+
+func (km *keyManager) DecryptWithOperatorKey(cipher []byte) ([]byte, error) {
+	return km.Decrypt(km.rsaKey, cipher)
 }
 
 func (km *keyManager) Decrypt(sk *rsa.PrivateKey, cipher []byte) ([]byte, error) {
@@ -51,17 +93,64 @@ func (km *keyManager) Encrypt(pk *rsa.PublicKey, plaintext []byte) ([]byte, erro
 }
 
 func (km *keyManager) SignDKGOutput(output types.Root, address common.Address) (types.Signature, error) {
-	root, err := types.ComputeSigningRoot(output, types.ComputeSignatureDomain(km.Domain, types.DKGSignatureType))
+	if err := km.checkAddress(address); err != nil {
+		return nil, err
+	}
+	root, err := types.ComputeSigningRoot(output, types.ComputeSignatureDomain(network.DKGSignatureDomain, types.DKGSignatureType))
 	if err != nil {
 		return nil, err
 	}
-	return crypto.Sign(root, km.sk)
+	return km.ethSigner.SignDigest(root)
 }
 
+// SignRoot signs data with the BLS share whose public key is pk, in the SSV domain of the network
 func (km *keyManager) SignRoot(data types.Root, sigType types.SignatureType, pk []byte) (types.Signature, error) {
-	panic("not implemented")
+	km.mu.RLock()
+	share, found := km.shares[hex.EncodeToString(pk)]
+	km.mu.RUnlock()
+	if !found {
+		return nil, fmt.Errorf("no share for public key %x", pk)
+	}
+
+	root, err := types.ComputeSigningRoot(data, types.ComputeSignatureDomain(km.Domain, sigType))
+	if err != nil {
+		return nil, err
+	}
+	return share.SignByte(root).Serialize(), nil
 }
 
+// SignETHDepositRoot signs the signing root of deposit data with the operator's ethereum key,
+// bound to the SSV domain of the network so a signature can't be replayed on another network.
+// The signed digest is sha256(root || domain type || DKGSignatureType), as SignDKGOutput signs,
+// not the beacon chain deposit domain: the signature vouches for the deposit data to the
+// ceremony, it isn't a deposit signature.
 func (km *keyManager) SignETHDepositRoot(root []byte, address common.Address) (types.Signature, error) {
-	panic("not implemented")
+	if err := km.checkAddress(address); err != nil {
+		return nil, err
+	}
+	if len(root) != 32 {
+		return nil, fmt.Errorf("deposit root has %d bytes, expected 32", len(root))
+	}
+
+	signingRoot, err := types.ComputeSigningRoot(depositRoot(root), types.ComputeSignatureDomain(km.Domain, types.DKGSignatureType))
+	if err != nil {
+		return nil, err
+	}
+	return km.ethSigner.SignDigest(signingRoot)
+}
+
+func (km *keyManager) checkAddress(address common.Address) error {
+	if km.ethSigner == nil {
+		return errors.New("operator ethereum key is not loaded")
+	}
+	if own := km.ethSigner.Address(); own != address {
+		return fmt.Errorf("no key for address %s, the node signs as %s", address.Hex(), own.Hex())
+	}
+	return nil
+}
+
+type depositRoot []byte
+
+func (r depositRoot) GetRoot() ([]byte, error) {
+	return r, nil
 }
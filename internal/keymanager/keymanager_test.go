@@ -0,0 +1,171 @@
+package keymanager
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/RockX-SG/frost-dkg-demo/internal/network"
+	"github.com/bloxapp/ssv-spec/types"
+	"github.com/bloxapp/ssv-spec/types/testingutils"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+func testKeyManager(t *testing.T, domain types.DomainType) (Signer, *testingutils.TestKeySet) {
+	t.Helper()
+	ks := testingutils.Testing4SharesSet()
+	km := NewKeyManager(&network.Network{DomainType: domain}, NewLocalSigner(ks.DKGOperators[1].SK), nil)
+	for _, share := range ks.Shares {
+		if err := km.AddShare(share); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return km, ks
+}
+
+func TestSignRoot(t *testing.T) {
+	km, ks := testKeyManager(t, types.PrimusTestnet)
+	spec := testingutils.NewTestingKeyManager()
+	data := testingData
+
+	for id, share := range ks.Shares {
+		pk := share.GetPublicKey().Serialize()
+		for _, sigType := range []types.SignatureType{types.QBFTSignatureType, types.PartialSignatureType, types.DKGSignatureType} {
+			expected, err := spec.SignRoot(testingRoot(data), sigType, pk)
+			if err != nil {
+				t.Fatal(err)
+			}
+			sig, err := km.SignRoot(testingRoot(data), sigType, pk)
+			if err != nil {
+				t.Fatalf("operator %d: %v", id, err)
+			}
+			if !bytes.Equal(sig, expected) {
+				t.Errorf("operator %d, signature type %x: signature doesn't match the spec key manager", id, sigType)
+			}
+		}
+	}
+}
+
+func TestSignRootDomainSeparation(t *testing.T) {
+	primus, ks := testKeyManager(t, types.PrimusTestnet)
+	hoodi, err := network.FromName(network.Hoodi)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, _ := testKeyManager(t, hoodi.DomainType)
+
+	pk := ks.Shares[1].GetPublicKey().Serialize()
+	sig1, err := primus.SignRoot(testingRoot(testingData), types.QBFTSignatureType, pk)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sig2, err := other.SignRoot(testingRoot(testingData), types.QBFTSignatureType, pk)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Equal(sig1, sig2) {
+		t.Error("signatures for different networks are equal")
+	}
+}
+
+func TestSignRootUnknownShare(t *testing.T) {
+	km, _ := testKeyManager(t, types.PrimusTestnet)
+	pk := testingutils.Testing7SharesSet().Shares[1].GetPublicKey().Serialize()
+	if _, err := km.SignRoot(testingRoot(testingData), types.QBFTSignatureType, pk); err == nil {
+		t.Error("expected an error for an unknown share")
+	}
+}
+
+func TestSignDKGOutput(t *testing.T) {
+	km, ks := testKeyManager(t, types.PrimusTestnet)
+	spec := testingutils.NewTestingKeyManager()
+	output := testingRoot(testingData)
+	address := ks.DKGOperators[1].ETHAddress
+
+	expected, err := spec.SignDKGOutput(output, address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sig, err := km.SignDKGOutput(output, address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(sig, expected) {
+		t.Error("signature doesn't match the spec key manager")
+	}
+
+	if _, err := km.SignDKGOutput(output, ks.DKGOperators[2].ETHAddress); err == nil {
+		t.Error("expected an error for another operator's address")
+	}
+}
+
+func TestSignETHDepositRoot(t *testing.T) {
+	km, ks := testKeyManager(t, types.PrimusTestnet)
+	address := ks.DKGOperators[1].ETHAddress
+	root := bytes.Repeat([]byte{0xab}, 32)
+
+	sig, err := km.SignETHDepositRoot(root, address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if recoverAddress(t, root, types.PrimusTestnet, sig) != address {
+		t.Error("signature doesn't recover to the operator address")
+	}
+
+	// the digest is sha256(root || "primus_testnet" || 03000000), computed independently
+	digest := common.FromHex("919aea485c4178e4816949f7bb7e263137cc6f3168cc47712c5481fbed93bf27")
+	pub, err := crypto.SigToPub(digest, sig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if crypto.PubkeyToAddress(*pub) != address {
+		t.Error("signature isn't over the expected digest")
+	}
+
+	hoodi, _ := network.FromName(network.Hoodi)
+	if recoverAddress(t, root, hoodi.DomainType, sig) == address {
+		t.Error("signature is valid for another network")
+	}
+
+	if _, err := km.SignETHDepositRoot(root, ks.DKGOperators[2].ETHAddress); err == nil {
+		t.Error("expected an error for another operator's address")
+	}
+	if _, err := km.SignETHDepositRoot(root[:31], address); err == nil {
+		t.Error("expected an error for a short root")
+	}
+}
+
+func TestNoPanicWithoutKeys(t *testing.T) {
+	km := NewKeyManager(&network.Network{DomainType: types.PrimusTestnet}, nil, nil)
+	root := bytes.Repeat([]byte{0x01}, 32)
+	if _, err := km.SignETHDepositRoot(root, common.Address{}); err == nil {
+		t.Error("expected an error without an ethereum key")
+	}
+	if _, err := km.SignDKGOutput(testingRoot(root), common.Address{}); err == nil {
+		t.Error("expected an error without an ethereum key")
+	}
+	if _, err := km.DecryptWithOperatorKey([]byte{0x01}); err == nil {
+		t.Error("expected an error without an operator key")
+	}
+}
+
+func recoverAddress(t *testing.T, root []byte, domain types.DomainType, sig []byte) common.Address {
+	t.Helper()
+	signingRoot, err := types.ComputeSigningRoot(depositRoot(root), types.ComputeSignatureDomain(domain, types.DKGSignatureType))
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub, err := crypto.SigToPub(signingRoot, sig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return crypto.PubkeyToAddress(*pub)
+}
+
+var testingData = []byte("key manager test data")
+
+type testingRoot []byte
+
+func (r testingRoot) GetRoot() ([]byte, error) {
+	return crypto.Keccak256(r), nil
+}
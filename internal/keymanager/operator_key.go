@@ -0,0 +1,211 @@
+package keymanager
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/RockX-SG/frost-dkg-demo/internal/secrets"
+	"golang.org/x/crypto/pbkdf2"
+	"golang.org/x/crypto/scrypt"
+	"golang.org/x/text/unicode/norm"
+)
+
+// encryptedOperatorKey is the SSV encrypted operator key file, an EIP-2335 style keystore
+// whose secret is the PEM encoded RSA private key
+type encryptedOperatorKey struct {
+	Checksum keystoreModule `json:"checksum"`
+	Cipher   keystoreModule `json:"cipher"`
+	KDF      keystoreModule `json:"kdf"`
+	PubKey   string         `json:"pubKey"`
+}
+
+type keystoreModule struct {
+	Function string                 `json:"function"`
+	Params   map[string]interface{} `json:"params"`
+	Message  string                 `json:"message"`
+}
+
+// LoadOperatorKey loads the operator's RSA private key from a file in the SSV encrypted operator
+// key format, or from a PEM file which may be base64 encoded as SSV prints unencrypted keys. The
+// password is only read for an encrypted key file.
+func LoadOperatorKey(path string, password secrets.Source) (*rsa.PrivateKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	data = bytes.TrimSpace(data)
+
+	if bytes.HasPrefix(data, []byte("{")) {
+		keyFile := &encryptedOperatorKey{}
+		if err := json.Unmarshal(data, keyFile); err != nil {
+			return nil, fmt.Errorf("failed to parse encrypted operator key: %v", err)
+		}
+		pw, err := password.Secret()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read operator key password: %v", err)
+		}
+		data, err = keyFile.decrypt(pw)
+		secrets.Zero(pw)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decrypt operator key: %v", err)
+		}
+		defer secrets.Zero(data)
+	}
+	return parseRSAPrivateKey(data)
+}
+
+func parseRSAPrivateKey(data []byte) (*rsa.PrivateKey, error) {
+	data = bytes.TrimSpace(data)
+	if !bytes.HasPrefix(data, []byte("-----")) {
+		decoded, err := base64.StdEncoding.DecodeString(string(data))
+		if err != nil {
+			return nil, errors.New("operator key is neither PEM nor base64 encoded PEM")
+		}
+		data = decoded
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("failed to parse PEM block containing the operator key")
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse operator key: %v", err)
+	}
+	rsaKey, ok := key.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("operator key is not an RSA key")
+	}
+	return rsaKey, nil
+}
+
+func (k *encryptedOperatorKey) decrypt(password []byte) ([]byte, error) {
+	normalized := normalizePassword(password)
+	decryptionKey, err := k.deriveKey(normalized)
+	secrets.Zero(normalized)
+	if err != nil {
+		return nil, err
+	}
+	defer secrets.Zero(decryptionKey)
+
+	cipherText, err := hex.DecodeString(k.Cipher.Message)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cipher message: %v", err)
+	}
+	if k.Checksum.Function != "sha256" {
+		return nil, fmt.Errorf("unsupported checksum function %s", k.Checksum.Function)
+	}
+	checksum := sha256.Sum256(append(append([]byte{}, decryptionKey[16:32]...), cipherText...))
+	if hex.EncodeToString(checksum[:]) != strings.ToLower(k.Checksum.Message) {
+		return nil, errors.New("invalid password")
+	}
+
+	if k.Cipher.Function != "aes-128-ctr" {
+		return nil, fmt.Errorf("unsupported cipher function %s", k.Cipher.Function)
+	}
+	iv, err := hexParam(k.Cipher.Params, "iv")
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(decryptionKey[:16])
+	if err != nil {
+		return nil, err
+	}
+	plaintext := make([]byte, len(cipherText))
+	cipher.NewCTR(block, iv).XORKeyStream(plaintext, cipherText)
+	return plaintext, nil
+}
+
+func (k *encryptedOperatorKey) deriveKey(password []byte) ([]byte, error) {
+	salt, err := hexParam(k.KDF.Params, "salt")
+	if err != nil {
+		return nil, err
+	}
+	dklen, err := intParam(k.KDF.Params, "dklen")
+	if err != nil {
+		return nil, err
+	}
+	if dklen < 32 {
+		return nil, fmt.Errorf("kdf dklen %d is too short", dklen)
+	}
+
+	switch k.KDF.Function {
+	case "scrypt":
+		n, err := intParam(k.KDF.Params, "n")
+		if err != nil {
+			return nil, err
+		}
+		r, err := intParam(k.KDF.Params, "r")
+		if err != nil {
+			return nil, err
+		}
+		p, err := intParam(k.KDF.Params, "p")
+		if err != nil {
+			return nil, err
+		}
+		return scrypt.Key(password, salt, n, r, p, dklen)
+	case "pbkdf2":
+		c, err := intParam(k.KDF.Params, "c")
+		if err != nil {
+			return nil, err
+		}
+		if prf, _ := k.KDF.Params["prf"].(string); prf != "hmac-sha256" {
+			return nil, fmt.Errorf("unsupported pbkdf2 prf %s", prf)
+		}
+		return pbkdf2.Key(password, salt, c, dklen, sha256.New), nil
+	default:
+		return nil, fmt.Errorf("unsupported kdf function %s", k.KDF.Function)
+	}
+}
+
+// normalizePassword applies the EIP-2335 password processing: NFKD normalization and
+// removal of control characters
+func normalizePassword(password []byte) []byte {
+	normalized := norm.NFKD.Bytes(password)
+	out := make([]byte, 0, len(normalized))
+	for rest := normalized; len(rest) > 0; {
+		r, size := utf8.DecodeRune(rest)
+		if r >= 0x20 && (r < 0x7f || r > 0x9f) {
+			out = append(out, rest[:size]...)
+		}
+		rest = rest[size:]
+	}
+	secrets.Zero(normalized)
+	return out
+}
+
+func hexParam(params map[string]interface{}, name string) ([]byte, error) {
+	s, ok := params[name].(string)
+	if !ok {
+		return nil, fmt.Errorf("missing %s parameter", name)
+	}
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s parameter: %v", name, err)
+	}
+	return b, nil
+}
+
+func intParam(params map[string]interface{}, name string) (int, error) {
+	f, ok := params[name].(float64)
+	if !ok {
+		return 0, fmt.Errorf("missing %s parameter", name)
+	}
+	return int(f), nil
+}
@@ -0,0 +1,139 @@
+package keymanager
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/RockX-SG/frost-dkg-demo/internal/secrets"
+	"golang.org/x/crypto/pbkdf2"
+)
+
+// encryptTestOperatorKey encrypts a PEM encoded key the way SSV encrypts operator keys, with
+// pbkdf2 to keep the test fast
+func encryptTestOperatorKey(t *testing.T, keyPEM []byte, password string) *encryptedOperatorKey {
+	t.Helper()
+	salt, iv := make([]byte, 32), make([]byte, aes.BlockSize)
+	if _, err := rand.Read(salt); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := rand.Read(iv); err != nil {
+		t.Fatal(err)
+	}
+	decryptionKey := pbkdf2.Key([]byte(password), salt, 1024, 32, sha256.New)
+	block, err := aes.NewCipher(decryptionKey[:16])
+	if err != nil {
+		t.Fatal(err)
+	}
+	cipherText := make([]byte, len(keyPEM))
+	cipher.NewCTR(block, iv).XORKeyStream(cipherText, keyPEM)
+	checksum := sha256.Sum256(append(append([]byte{}, decryptionKey[16:32]...), cipherText...))
+
+	return &encryptedOperatorKey{
+		Checksum: keystoreModule{Function: "sha256", Params: map[string]interface{}{}, Message: hex.EncodeToString(checksum[:])},
+		Cipher:   keystoreModule{Function: "aes-128-ctr", Params: map[string]interface{}{"iv": hex.EncodeToString(iv)}, Message: hex.EncodeToString(cipherText)},
+		KDF: keystoreModule{Function: "pbkdf2", Params: map[string]interface{}{
+			"dklen": 32,
+			"c":     1024,
+			"prf":   "hmac-sha256",
+			"salt":  hex.EncodeToString(salt),
+		}},
+	}
+}
+
+func writeTestFile(t *testing.T, data []byte) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "operator_key")
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadOperatorKey(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+	t.Setenv("OPERATOR_KEY_PASSWORD", "operator key password")
+	password := &secrets.EnvSource{Name: "OPERATOR_KEY_PASSWORD"}
+
+	encrypted, err := json.Marshal(encryptTestOperatorKey(t, keyPEM, "operator key password"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, data := range map[string][]byte{
+		"pem":       keyPEM,
+		"base64":    []byte(base64.StdEncoding.EncodeToString(keyPEM) + "\n"),
+		"encrypted": encrypted,
+	} {
+		loaded, err := LoadOperatorKey(writeTestFile(t, data), password)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if !loaded.Equal(key) {
+			t.Fatalf("%s: loaded another key", name)
+		}
+	}
+}
+
+func TestLoadOperatorKeyErrors(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+	t.Setenv("OPERATOR_KEY_PASSWORD", "operator key password")
+	password := &secrets.EnvSource{Name: "OPERATOR_KEY_PASSWORD"}
+
+	encrypt := func(password string, modify func(*encryptedOperatorKey)) []byte {
+		keyFile := encryptTestOperatorKey(t, keyPEM, password)
+		modify(keyFile)
+		data, err := json.Marshal(keyFile)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return data
+	}
+	unchanged := func(*encryptedOperatorKey) {}
+
+	for name, test := range map[string]struct {
+		data []byte
+		err  string
+	}{
+		"wrong password":   {encrypt("another password", unchanged), "invalid password"},
+		"truncated json":   {encrypt("operator key password", unchanged)[:40], "failed to parse encrypted operator key"},
+		"unsupported kdf":  {encrypt("operator key password", func(k *encryptedOperatorKey) { k.KDF.Function = "argon2" }), "unsupported kdf function argon2"},
+		"missing salt":     {encrypt("operator key password", func(k *encryptedOperatorKey) { delete(k.KDF.Params, "salt") }), "missing salt parameter"},
+		"short dklen":      {encrypt("operator key password", func(k *encryptedOperatorKey) { k.KDF.Params["dklen"] = 16 }), "kdf dklen 16 is too short"},
+		"corrupt cipher":   {encrypt("operator key password", func(k *encryptedOperatorKey) { k.Cipher.Message = "not hex" }), "invalid cipher message"},
+		"unknown cipher":   {encrypt("operator key password", func(k *encryptedOperatorKey) { k.Cipher.Function = "aes-256-gcm" }), "unsupported cipher function aes-256-gcm"},
+		"not a key":        {[]byte("not a key"), "neither PEM nor base64 encoded PEM"},
+		"not an rsa block": {pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: []byte("garbage")}), "failed to parse operator key"},
+	} {
+		_, err := LoadOperatorKey(writeTestFile(t, test.data), password)
+		if err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Fatalf("%s: got error %v, expected %q", name, err, test.err)
+		}
+	}
+
+	// the password is only read for an encrypted key
+	if _, err := LoadOperatorKey(writeTestFile(t, keyPEM), &secrets.EnvSource{Name: "UNSET_OPERATOR_KEY_PASSWORD"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadOperatorKey(writeTestFile(t, encrypt("operator key password", unchanged)), &secrets.EnvSource{Name: "UNSET_OPERATOR_KEY_PASSWORD"}); err == nil {
+		t.Fatal("loaded an encrypted key without its password")
+	}
+}
@@ -0,0 +1,194 @@
+package keymanager
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+const remoteSignerTimeout = 10 * time.Second
+
+// checkDigest is signed by Check to verify that the signer signs with the operator key
+var checkDigest = crypto.Keccak256([]byte("frost-dkg-node remote signer check"))
+
+// RemoteSignerConfig configures the connection to a remote signer
+type RemoteSignerConfig struct {
+	// URL is the http endpoint of the signer's external api
+	URL string
+	// Address is the ethereum address of the operator key held by the signer
+	Address common.Address
+	// CACertFile verifies the signer's certificate, the system roots are used when empty
+	CACertFile string
+	// ClientCertFile and ClientKeyFile authenticate the node to the signer
+	ClientCertFile string
+	ClientKeyFile  string
+}
+
+// RemoteSigner signs with an operator key held by Clef, over its external api: account_list and
+// account_signData with the text/plain content type. Clef only signs data as an EIP-191 personal
+// message, so SignDigest returns the signature of keccak256("\x19Ethereum Signed Message:\n32" ||
+// digest) rather than of the digest itself. Every signature is checked to recover to the
+// operator address.
+type RemoteSigner struct {
+	url     string
+	address common.Address
+	client  *http.Client
+}
+
+// NewRemoteSigner creates a remote signer client, authenticated with the client certificate when one is configured
+func NewRemoteSigner(config RemoteSignerConfig) (*RemoteSigner, error) {
+	if config.URL == "" {
+		return nil, errors.New("remote signer URL is required")
+	}
+	if config.Address == (common.Address{}) {
+		return nil, errors.New("remote signer address is required")
+	}
+
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	if config.CACertFile != "" {
+		pem, err := os.ReadFile(config.CACertFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read remote signer CA: %v", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificate found in %s", config.CACertFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	if (config.ClientCertFile == "") != (config.ClientKeyFile == "") {
+		return nil, errors.New("remote signer client auth needs both a certificate and a key file")
+	}
+	if config.ClientCertFile != "" {
+		cert, err := tls.LoadX509KeyPair(config.ClientCertFile, config.ClientKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load remote signer client certificate: %v", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	return &RemoteSigner{
+		url:     strings.TrimSuffix(config.URL, "/"),
+		address: config.Address,
+		client: &http.Client{
+			Timeout:   remoteSignerTimeout,
+			Transport: &http.Transport{TLSClientConfig: tlsConfig},
+		},
+	}, nil
+}
+
+func (s *RemoteSigner) Address() common.Address {
+	return s.address
+}
+
+// SignDigest signs the digest as an EIP-191 personal message
+func (s *RemoteSigner) SignDigest(digest []byte) ([]byte, error) {
+	if len(digest) != 32 {
+		return nil, fmt.Errorf("digest has %d bytes, expected 32", len(digest))
+	}
+	var result string
+	if err := s.call("account_signData", &result, "text/plain", s.address.Hex(), hexutil.Encode(digest)); err != nil {
+		return nil, err
+	}
+
+	sig, err := hexutil.Decode(result)
+	if err != nil || len(sig) != crypto.SignatureLength {
+		return nil, errors.New("remote signer: response is not a 65 byte hex signature")
+	}
+	// clef returns V as 27 or 28
+	if sig[crypto.RecoveryIDOffset] >= 27 {
+		sig[crypto.RecoveryIDOffset] -= 27
+	}
+
+	pub, err := crypto.SigToPub(accounts.TextHash(digest), sig)
+	if err != nil {
+		return nil, fmt.Errorf("remote signer: invalid signature: %v", err)
+	}
+	if signer := crypto.PubkeyToAddress(*pub); signer != s.address {
+		return nil, fmt.Errorf("remote signer: signature recovers to %s instead of %s", signer.Hex(), s.address.Hex())
+	}
+	return sig, nil
+}
+
+// Check verifies that the signer is up, holds the operator key and signs with it
+func (s *RemoteSigner) Check() error {
+	var listed []common.Address
+	if err := s.call("account_list", &listed); err != nil {
+		return err
+	}
+	held := false
+	for _, account := range listed {
+		if account == s.address {
+			held = true
+		}
+	}
+	if !held {
+		return fmt.Errorf("remote signer doesn't hold the key of %s", s.address.Hex())
+	}
+	_, err := s.SignDigest(checkDigest)
+	return err
+}
+
+type rpcRequest struct {
+	JSONRPC string        `json:"jsonrpc"`
+	ID      int           `json:"id"`
+	Method  string        `json:"method"`
+	Params  []interface{} `json:"params"`
+}
+
+type rpcResponse struct {
+	Result json.RawMessage `json:"result"`
+	Error  *struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// call calls a json-rpc method of the signer and decodes its result into result
+func (s *RemoteSigner) call(method string, result interface{}, params ...interface{}) error {
+	if params == nil {
+		params = []interface{}{}
+	}
+	body, err := json.Marshal(&rpcRequest{JSONRPC: "2.0", ID: 1, Method: method, Params: params})
+	if err != nil {
+		return err
+	}
+
+	resp, err := s.client.Post(s.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("remote signer: %v", err)
+	}
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("remote signer: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("remote signer: %s failed with status %d: %s", method, resp.StatusCode, strings.TrimSpace(string(respBody)))
+	}
+
+	rpcResp := &rpcResponse{}
+	if err := json.Unmarshal(respBody, rpcResp); err != nil {
+		return fmt.Errorf("remote signer: failed to parse %s response: %v", method, err)
+	}
+	if rpcResp.Error != nil {
+		return fmt.Errorf("remote signer: %s failed: %s (code %d)", method, rpcResp.Error.Message, rpcResp.Error.Code)
+	}
+	if err := json.Unmarshal(rpcResp.Result, result); err != nil {
+		return fmt.Errorf("remote signer: failed to parse %s result: %v", method, err)
+	}
+	return nil
+}
@@ -0,0 +1,346 @@
+package keymanager
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/RockX-SG/frost-dkg-demo/internal/network"
+	"github.com/bloxapp/ssv-spec/types"
+	"github.com/bloxapp/ssv-spec/types/testingutils"
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// fakeSigner is a stand-in for clef holding one ethereum key, signing the posted data as an
+// EIP-191 personal message
+type fakeSigner struct {
+	sk *ecdsa.PrivateKey
+	// signWith, when set, signs instead of sk, to fake a signer returning wrong signatures
+	signWith *ecdsa.PrivateKey
+	// rawDigest fakes a signer signing the posted data as is, without the EIP-191 prefix
+	rawDigest bool
+	// deny fakes a signer whose rules refuse to sign
+	deny bool
+}
+
+func (f *fakeSigner) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	req := struct {
+		ID     int           `json:"id"`
+		Method string        `json:"method"`
+		Params []interface{} `json:"params"`
+	}{}
+	if r.Method != http.MethodPost {
+		http.NotFound(w, r)
+		return
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	respond := func(result interface{}, err string) {
+		resp := map[string]interface{}{"jsonrpc": "2.0", "id": req.ID}
+		if err != "" {
+			resp["error"] = map[string]interface{}{"code": -32000, "message": err}
+		} else {
+			resp["result"] = result
+		}
+		_ = json.NewEncoder(w).Encode(resp)
+	}
+
+	address := crypto.PubkeyToAddress(f.sk.PublicKey)
+	switch req.Method {
+	case "account_list":
+		respond([]string{address.Hex()}, "")
+	case "account_signData":
+		if len(req.Params) != 3 || req.Params[0] != "text/plain" || !strings.EqualFold(req.Params[1].(string), address.Hex()) {
+			respond(nil, "invalid params")
+			return
+		}
+		if f.deny {
+			respond(nil, "Request denied")
+			return
+		}
+		data, err := hexutil.Decode(req.Params[2].(string))
+		if err != nil {
+			respond(nil, err.Error())
+			return
+		}
+		digest := accounts.TextHash(data)
+		if f.rawDigest {
+			digest = data
+		}
+		sk := f.sk
+		if f.signWith != nil {
+			sk = f.signWith
+		}
+		sig, err := crypto.Sign(digest, sk)
+		if err != nil {
+			respond(nil, err.Error())
+			return
+		}
+		sig[crypto.RecoveryIDOffset] += 27
+		respond(hexutil.Encode(sig), "")
+	default:
+		respond(nil, "the method "+req.Method+" does not exist/is not available")
+	}
+}
+
+type testPKI struct {
+	dir    string
+	caCert *x509.Certificate
+	caKey  *ecdsa.PrivateKey
+	caFile string
+}
+
+func newTestPKI(t *testing.T) *testPKI {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pki := &testPKI{dir: t.TempDir(), caCert: cert, caKey: key}
+	pki.caFile = pki.write(t, "ca.pem", "CERTIFICATE", der)
+	return pki
+}
+
+// issue creates a certificate signed by the test CA and returns its certificate and key files
+func (pki *testPKI) issue(t *testing.T, name string, usage x509.ExtKeyUsage) (string, string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, pki.caCert, &key.PublicKey, pki.caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pki.write(t, name+".pem", "CERTIFICATE", der), pki.write(t, name+".key", "EC PRIVATE KEY", keyDER)
+}
+
+func (pki *testPKI) write(t *testing.T, name, blockType string, der []byte) string {
+	t.Helper()
+	path := filepath.Join(pki.dir, name)
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// startFakeSigner serves the fake signer over TLS, requiring a client certificate of the test CA
+func startFakeSigner(t *testing.T, pki *testPKI, signer *fakeSigner) string {
+	t.Helper()
+	certFile, keyFile := pki.issue(t, "server", x509.ExtKeyUsageServerAuth)
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool := x509.NewCertPool()
+	pool.AddCert(pki.caCert)
+
+	srv := httptest.NewUnstartedServer(signer)
+	srv.TLS = &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		ClientCAs:    pool,
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+	}
+	srv.StartTLS()
+	t.Cleanup(srv.Close)
+	return srv.URL
+}
+
+func TestRemoteSigner(t *testing.T) {
+	ks := testingutils.Testing4SharesSet()
+	sk := ks.DKGOperators[1].SK
+	address := ks.DKGOperators[1].ETHAddress
+
+	pki := newTestPKI(t)
+	url := startFakeSigner(t, pki, &fakeSigner{sk: sk})
+	clientCert, clientKey := pki.issue(t, "client", x509.ExtKeyUsageClientAuth)
+
+	remote, err := NewRemoteSigner(RemoteSignerConfig{
+		URL:            url,
+		Address:        address,
+		CACertFile:     pki.caFile,
+		ClientCertFile: clientCert,
+		ClientKeyFile:  clientKey,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := remote.Check(); err != nil {
+		t.Fatal(err)
+	}
+
+	// signatures through the remote signer are personal message signatures of the digests the
+	// local key signs
+	net := &network.Network{DomainType: types.PrimusTestnet}
+	km := NewKeyManager(net, remote, nil)
+	requireSigned := func(sig []byte, root types.Root) {
+		t.Helper()
+		digest, err := types.ComputeSigningRoot(root, types.ComputeSignatureDomain(network.DKGSignatureDomain, types.DKGSignatureType))
+		if err != nil {
+			t.Fatal(err)
+		}
+		pub, err := crypto.SigToPub(accounts.TextHash(digest), sig)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if crypto.PubkeyToAddress(*pub) != address {
+			t.Error("remote signature doesn't recover to the operator address")
+		}
+	}
+
+	output := testingRoot(testingData)
+	sig, err := km.SignDKGOutput(output, address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	requireSigned(sig, output)
+
+	root := bytes.Repeat([]byte{0xab}, 32)
+	sig, err = km.SignETHDepositRoot(root, address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	requireSigned(sig, depositRoot(root))
+}
+
+func TestRemoteSignerRequiresClientCert(t *testing.T) {
+	ks := testingutils.Testing4SharesSet()
+	pki := newTestPKI(t)
+	url := startFakeSigner(t, pki, &fakeSigner{sk: ks.DKGOperators[1].SK})
+
+	remote, err := NewRemoteSigner(RemoteSignerConfig{URL: url, Address: ks.DKGOperators[1].ETHAddress, CACertFile: pki.caFile})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := remote.Check(); err == nil {
+		t.Error("expected the signer to reject a client without a certificate")
+	}
+}
+
+func TestRemoteSignerRejectsWrongKey(t *testing.T) {
+	ks := testingutils.Testing4SharesSet()
+	pki := newTestPKI(t)
+	url := startFakeSigner(t, pki, &fakeSigner{sk: ks.DKGOperators[1].SK, signWith: ks.DKGOperators[2].SK})
+	clientCert, clientKey := pki.issue(t, "client", x509.ExtKeyUsageClientAuth)
+
+	remote, err := NewRemoteSigner(RemoteSignerConfig{
+		URL:            url,
+		Address:        ks.DKGOperators[1].ETHAddress,
+		CACertFile:     pki.caFile,
+		ClientCertFile: clientCert,
+		ClientKeyFile:  clientKey,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := remote.SignDigest(bytes.Repeat([]byte{0x01}, 32)); err == nil {
+		t.Error("expected an error for a signature of another key")
+	}
+
+	other, err := NewRemoteSigner(RemoteSignerConfig{
+		URL:            url,
+		Address:        common.BytesToAddress(bytes.Repeat([]byte{0x01}, 20)),
+		CACertFile:     pki.caFile,
+		ClientCertFile: clientCert,
+		ClientKeyFile:  clientKey,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := other.Check(); err == nil {
+		t.Error("expected an error for an address the signer holds no key for")
+	}
+}
+
+func TestRemoteSignerRejectsUnprefixedSigner(t *testing.T) {
+	ks := testingutils.Testing4SharesSet()
+	pki := newTestPKI(t)
+	url := startFakeSigner(t, pki, &fakeSigner{sk: ks.DKGOperators[1].SK, rawDigest: true})
+	clientCert, clientKey := pki.issue(t, "client", x509.ExtKeyUsageClientAuth)
+
+	remote, err := NewRemoteSigner(RemoteSignerConfig{
+		URL:            url,
+		Address:        ks.DKGOperators[1].ETHAddress,
+		CACertFile:     pki.caFile,
+		ClientCertFile: clientCert,
+		ClientKeyFile:  clientKey,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the signer holds the key, but its signatures don't recover to it
+	if err := remote.Check(); err == nil || !strings.Contains(err.Error(), "signature recovers to") {
+		t.Errorf("expected the check to reject a signer not prefixing the digest, got %v", err)
+	}
+}
+
+func TestRemoteSignerDenied(t *testing.T) {
+	ks := testingutils.Testing4SharesSet()
+	pki := newTestPKI(t)
+	url := startFakeSigner(t, pki, &fakeSigner{sk: ks.DKGOperators[1].SK, deny: true})
+	clientCert, clientKey := pki.issue(t, "client", x509.ExtKeyUsageClientAuth)
+
+	remote, err := NewRemoteSigner(RemoteSignerConfig{
+		URL:            url,
+		Address:        ks.DKGOperators[1].ETHAddress,
+		CACertFile:     pki.caFile,
+		ClientCertFile: clientCert,
+		ClientKeyFile:  clientKey,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := remote.Check(); err == nil || !strings.Contains(err.Error(), "Request denied") {
+		t.Errorf("expected the signer's error, got %v", err)
+	}
+}
@@ -0,0 +1,48 @@
+package mesh
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/bloxapp/ssv-spec/types"
+)
+
+// AddressBook maps operator IDs to the base url of their dkg node
+type AddressBook map[types.OperatorID]string
+
+// LoadAddressBook reads an address book from a json file of the form {"1": "http://host:8081", ...}
+func LoadAddressBook(filepath string) (AddressBook, error) {
+	data, err := os.ReadFile(filepath)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make(map[string]string)
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("failed to parse address book %s: %s", filepath, err.Error())
+	}
+
+	book := make(AddressBook)
+	for id, addr := range entries {
+		operatorID, err := strconv.ParseUint(id, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid operator id %s in address book: %s", id, err.Error())
+		}
+		if addr == "" {
+			return nil, fmt.Errorf("empty address for operator %s in address book", id)
+		}
+		book[types.OperatorID(operatorID)] = strings.TrimSuffix(addr, "/")
+	}
+	return book, nil
+}
+
+func (book AddressBook) Operators() []types.OperatorID {
+	operators := make([]types.OperatorID, 0, len(book))
+	for operatorID := range book {
+		operators = append(operators, operatorID)
+	}
+	return operators
+}
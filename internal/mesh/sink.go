@@ -0,0 +1,81 @@
+package mesh
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/RockX-SG/frost-dkg-demo/internal/messenger"
+	"github.com/bloxapp/ssv-spec/dkg"
+	"github.com/bloxapp/ssv-spec/types"
+)
+
+// Sink receives the ceremony output, blame, timeout and rejection that the messenger would
+// otherwise collect
+type Sink interface {
+	StreamDKGOutput(output map[types.OperatorID]*dkg.SignedOutput) error
+	StreamDKGBlame(blame *dkg.BlameOutput) error
+	StreamDKGTimeout(timeout *messenger.TimeoutOutput) error
+	StreamDKGRejection(rejection *messenger.RejectionOutput) error
+}
+
+// NewSink creates a sink from its config value: either "messenger" to keep streaming results
+// to the messenger at messengerAddr, or "file:<dir>" to write them as json files to dir
+func NewSink(config, messengerAddr string) (Sink, error) {
+	switch {
+	case config == "" || config == "messenger":
+		return messenger.NewMessengerClient(messengerAddr), nil
+	case strings.HasPrefix(config, "file:"):
+		dir := strings.TrimPrefix(config, "file:")
+		if err := os.MkdirAll(dir, 0o750); err != nil {
+			return nil, err
+		}
+		return &FileSink{Dir: dir}, nil
+	default:
+		return nil, fmt.Errorf("unknown sink %s: has to be messenger or file:<dir>", config)
+	}
+}
+
+// FileSink writes the ceremony results to <Dir>/<request_id>.<kind>.json, kind being output, blame,
+// timeout or rejection, in the same format the messenger serves on /data
+type FileSink struct {
+	Dir string
+}
+
+func (s *FileSink) StreamDKGOutput(output map[types.OperatorID]*dkg.SignedOutput) error {
+	var requestID string
+	for _, o := range output {
+		requestID = hex.EncodeToString(o.Data.RequestID[:])
+	}
+	return s.write(requestID, "output", &messenger.DataStore{DKGOutputs: output})
+}
+
+func (s *FileSink) StreamDKGBlame(blame *dkg.BlameOutput) error {
+	requestID := hex.EncodeToString(blame.BlameMessage.Message.Identifier[:])
+	return s.write(requestID, "blame", &messenger.DataStore{BlameOutput: blame})
+}
+
+func (s *FileSink) StreamDKGTimeout(timeout *messenger.TimeoutOutput) error {
+	requestID := hex.EncodeToString(timeout.RequestID[:])
+	return s.write(requestID, "timeout", &messenger.DataStore{
+		TimeoutOutputs: map[types.OperatorID]*messenger.TimeoutOutput{timeout.OperatorID: timeout},
+	})
+}
+
+func (s *FileSink) StreamDKGRejection(rejection *messenger.RejectionOutput) error {
+	requestID := hex.EncodeToString(rejection.RequestID[:])
+	return s.write(requestID, "rejection", &messenger.DataStore{
+		Rejections: map[types.OperatorID]*messenger.RejectionOutput{rejection.OperatorID: rejection},
+	})
+}
+
+func (s *FileSink) write(requestID, kind string, data *messenger.DataStore) error {
+	byts, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path.Join(s.Dir, fmt.Sprintf("%s.%s.json", requestID, kind)), byts, 0o640)
+}
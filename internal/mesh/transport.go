@@ -0,0 +1,214 @@
+package mesh
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/RockX-SG/frost-dkg-demo/internal/logger"
+	"github.com/RockX-SG/frost-dkg-demo/internal/messenger"
+	"github.com/bloxapp/ssv-spec/dkg"
+	"github.com/bloxapp/ssv-spec/types"
+)
+
+const (
+	maxSendAttempts = 6
+	retryBaseDelay  = 500 * time.Millisecond
+	peerQueueSize   = 100
+
+	// committeeTTL is how long the operator set of a ceremony is remembered
+	committeeTTL = 2 * time.Hour
+)
+
+type committee struct {
+	operators []types.OperatorID
+	seenAt    time.Time
+}
+
+// Transport is a dkg.Network that sends every round message directly to the /consume endpoint
+// of the other operators in the ceremony, without going through the messenger. The operators
+// of a ceremony are learned from its init or reshare message, and the ceremony output, blame
+// and timeout are streamed by its Sink.
+type Transport struct {
+	Sink
+
+	operatorID types.OperatorID
+	peers      AddressBook
+
+	client *http.Client
+	queues map[types.OperatorID]chan []byte
+
+	committees map[string]*committee
+	mu         sync.Mutex
+
+	logger *logger.Logger
+}
+
+func NewTransport(operatorID types.OperatorID, peers AddressBook, sink Sink, logger *logger.Logger) *Transport {
+	t := &Transport{
+		operatorID: operatorID,
+		Sink:       sink,
+		peers:      peers,
+		client:     &http.Client{Timeout: time.Minute},
+		queues:     make(map[types.OperatorID]chan []byte),
+		committees: make(map[string]*committee),
+		logger:     logger,
+	}
+
+	// every peer gets its own queue and worker so a slow or unreachable peer
+	// doesn't hold up the others, while each peer still sees messages in order
+	for operatorID, addr := range peers {
+		if operatorID == t.operatorID {
+			continue
+		}
+		queue := make(chan []byte, peerQueueSize)
+		t.queues[operatorID] = queue
+		go t.sendWorker(operatorID, addr, queue)
+	}
+	return t
+}
+
+func (t *Transport) BroadcastDKGMessage(msg *dkg.SignedMessage) error {
+	msgBytes, err := msg.Encode()
+	if err != nil {
+		return err
+	}
+	ssvMsg := types.SSVMessage{
+		MsgType: types.DKGMsgType,
+		Data:    msgBytes,
+	}
+	data, err := ssvMsg.Encode()
+	if err != nil {
+		return err
+	}
+
+	// every recipient is checked before queueing the message for any, and a full queue doesn't
+	// keep the message from the other recipients
+	recipients := t.recipients(msg.Message.Identifier)
+	for _, operatorID := range recipients {
+		if _, ok := t.queues[operatorID]; !ok {
+			return fmt.Errorf("operator %d is not in the address book", operatorID)
+		}
+	}
+	full := make([]types.OperatorID, 0)
+	for _, operatorID := range recipients {
+		select {
+		case t.queues[operatorID] <- data:
+		default:
+			full = append(full, operatorID)
+		}
+	}
+	if len(full) > 0 {
+		return fmt.Errorf("send queues of operators %v are full, the message was only queued for the other operators", full)
+	}
+	return nil
+}
+
+// Observe is called for every new message the node receives. It records the operator set of
+// new ceremonies.
+func (t *Transport) Observe(ssvMsg *types.SSVMessage) error {
+	signedMsg := &dkg.SignedMessage{}
+	if err := signedMsg.Decode(ssvMsg.Data); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	switch signedMsg.Message.MsgType {
+	case dkg.InitMsgType:
+		init := &dkg.Init{}
+		if err := init.Decode(signedMsg.Message.Data); err != nil {
+			return err
+		}
+		t.addCommittee(signedMsg.Message.Identifier, init.OperatorIDs, now)
+	case dkg.ReshareMsgType:
+		reshare := &dkg.Reshare{}
+		if err := reshare.Decode(signedMsg.Message.Data); err != nil {
+			return err
+		}
+		operators := append([]types.OperatorID{}, reshare.OperatorIDs...)
+		operators = append(operators, reshare.OldOperatorIDs...)
+		t.addCommittee(signedMsg.Message.Identifier, operators, now)
+	}
+	return nil
+}
+
+func (t *Transport) addCommittee(requestID dkg.RequestID, operators []types.OperatorID, now time.Time) {
+	for k, c := range t.committees {
+		if now.Sub(c.seenAt) > committeeTTL {
+			delete(t.committees, k)
+		}
+	}
+	t.committees[hex.EncodeToString(requestID[:])] = &committee{operators: operators, seenAt: now}
+}
+
+// recipients returns the operators of the ceremony other than this one, or every peer in the
+// address book when the ceremony is unknown
+func (t *Transport) recipients(requestID dkg.RequestID) []types.OperatorID {
+	t.mu.Lock()
+	c, ok := t.committees[hex.EncodeToString(requestID[:])]
+	t.mu.Unlock()
+
+	operators := t.peers.Operators()
+	if ok {
+		operators = c.operators
+	}
+
+	recipients := make([]types.OperatorID, 0, len(operators))
+	seen := make(map[types.OperatorID]bool)
+	for _, operatorID := range operators {
+		if operatorID == t.operatorID || seen[operatorID] {
+			continue
+		}
+		seen[operatorID] = true
+		recipients = append(recipients, operatorID)
+	}
+	return recipients
+}
+
+func (t *Transport) sendWorker(operatorID types.OperatorID, addr string, queue chan []byte) {
+	for data := range queue {
+		var err error
+		attempts := 0
+		for attempts < maxSendAttempts {
+			attempts++
+			if err = t.send(addr, data); err == nil {
+				break
+			}
+			t.logger.Errorf("mesh: failed to send message to operator %d on attempt %d: %v", operatorID, attempts, err)
+			// a peer that refused the message for good refuses it again
+			errPush, pushFailed := err.(*messenger.ErrPushFailed)
+			if pushFailed && errPush.Permanent() {
+				break
+			}
+			if attempts < maxSendAttempts {
+				delay := retryBaseDelay << uint(attempts-1)
+				if pushFailed && errPush.RetryAfter > delay {
+					delay = errPush.RetryAfter
+				}
+				time.Sleep(delay)
+			}
+		}
+		if err != nil {
+			t.logger.Errorf("mesh: giving up on message to operator %d after %d attempts", operatorID, attempts)
+		}
+	}
+}
+
+func (t *Transport) send(addr string, data []byte) error {
+	resp, err := t.client.Post(fmt.Sprintf("%s/consume", addr), "application/json", bytes.NewBuffer(data))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return messenger.NewErrPushFailed(resp)
+	}
+	return nil
+}
@@ -0,0 +1,136 @@
+package mesh
+
+import (
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/RockX-SG/frost-dkg-demo/internal/logger"
+	"github.com/RockX-SG/frost-dkg-demo/internal/messenger"
+	"github.com/bloxapp/ssv-spec/dkg"
+	"github.com/bloxapp/ssv-spec/types"
+	"github.com/bloxapp/ssv-spec/types/testingutils"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+)
+
+var testRequestID = dkg.NewRequestID(testingutils.Testing4SharesSet().DKGOperators[1].ETHAddress, 1)
+
+func testMessage(msgType dkg.MsgType, data []byte) *dkg.SignedMessage {
+	return &dkg.SignedMessage{Message: &dkg.Message{MsgType: msgType, Identifier: testRequestID, Data: data}, Signer: 1}
+}
+
+func encodeSSVMessage(t *testing.T, msg *dkg.SignedMessage) *types.SSVMessage {
+	t.Helper()
+	data, err := msg.Encode()
+	require.NoError(t, err)
+	return &types.SSVMessage{MsgType: types.DKGMsgType, Data: data}
+}
+
+// peer is a node answering /consume with the statuses it's given, then with 200
+type peer struct {
+	mu       sync.Mutex
+	statuses []int
+	received int
+}
+
+func (p *peer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.received++
+	if len(p.statuses) > 0 {
+		w.WriteHeader(p.statuses[0])
+		p.statuses = p.statuses[1:]
+	}
+}
+
+func (p *peer) count() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.received
+}
+
+func TestTransportBroadcast(t *testing.T) {
+	peers := map[types.OperatorID]*peer{
+		2: {},
+		3: {statuses: []int{http.StatusInternalServerError}},
+		4: {statuses: []int{http.StatusUnprocessableEntity}},
+	}
+	book := AddressBook{1: "http://127.0.0.1:1"}
+	for operatorID, p := range peers {
+		srv := httptest.NewServer(p)
+		t.Cleanup(srv.Close)
+		book[operatorID] = srv.URL
+	}
+	transport := NewTransport(1, book, &FileSink{Dir: t.TempDir()}, &logger.Logger{Logger: logrus.New()})
+
+	// a message of an unknown ceremony goes to every peer, failed sends are retried unless
+	// the peer refused the message for good
+	require.NoError(t, transport.BroadcastDKGMessage(testMessage(dkg.ProtocolMsgType, nil)))
+	require.Eventually(t, func() bool {
+		return peers[2].count() == 1 && peers[3].count() == 2 && peers[4].count() == 1
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// once the ceremony is known, only its operators get its messages
+	init := testingutils.InitMessageDataBytes([]types.OperatorID{1, 2, 3}, 2, testingutils.TestingWithdrawalCredentials, testingutils.TestingForkVersion)
+	require.NoError(t, transport.Observe(encodeSSVMessage(t, testMessage(dkg.InitMsgType, init))))
+	require.NoError(t, transport.BroadcastDKGMessage(testMessage(dkg.ProtocolMsgType, nil)))
+	require.Eventually(t, func() bool {
+		return peers[2].count() == 2 && peers[3].count() == 3
+	}, 5*time.Second, 10*time.Millisecond)
+	time.Sleep(50 * time.Millisecond)
+	require.Equal(t, 1, peers[4].count())
+}
+
+func TestTransportBroadcastQueues(t *testing.T) {
+	transport := &Transport{
+		operatorID: 1,
+		peers:      AddressBook{1: "", 2: "", 3: "", 4: ""},
+		queues: map[types.OperatorID]chan []byte{
+			2: make(chan []byte, 1),
+			3: make(chan []byte, 1),
+			4: make(chan []byte, 1),
+		},
+		committees: make(map[string]*committee),
+	}
+	transport.queues[2] <- []byte("queued")
+
+	// a full queue doesn't keep the message from the other operators
+	err := transport.BroadcastDKGMessage(testMessage(dkg.ProtocolMsgType, nil))
+	require.EqualError(t, err, "send queues of operators [2] are full, the message was only queued for the other operators")
+	require.Len(t, transport.queues[3], 1)
+	require.Len(t, transport.queues[4], 1)
+
+	// an operator missing from the address book fails the broadcast before anything is queued
+	transport.addCommittee(testRequestID, []types.OperatorID{1, 3, 5}, time.Now())
+	<-transport.queues[3]
+	err = transport.BroadcastDKGMessage(testMessage(dkg.ProtocolMsgType, nil))
+	require.EqualError(t, err, "operator 5 is not in the address book")
+	require.Empty(t, transport.queues[3])
+}
+
+func TestFileSink(t *testing.T) {
+	dir := t.TempDir()
+	sink, err := NewSink("file:"+dir, "")
+	require.NoError(t, err)
+
+	blame := &dkg.BlameOutput{BlameMessage: testMessage(dkg.ProtocolMsgType, nil)}
+	require.NoError(t, sink.StreamDKGBlame(blame))
+	require.NoError(t, sink.StreamDKGTimeout(&messenger.TimeoutOutput{RequestID: testRequestID, OperatorID: 1, Round: "round 1"}))
+	require.NoError(t, sink.StreamDKGRejection(&messenger.RejectionOutput{RequestID: testRequestID, OperatorID: 2, Reasons: []string{"cluster size 4 isn't allowed"}}))
+
+	// the results of a ceremony don't overwrite each other
+	requestID := hex.EncodeToString(testRequestID[:])
+	for _, kind := range []string{"blame", "timeout", "rejection"} {
+		_, err := os.Stat(filepath.Join(dir, requestID+"."+kind+".json"))
+		require.NoError(t, err, kind)
+	}
+
+	_, err = NewSink("s3:bucket", "")
+	require.Error(t, err)
+}
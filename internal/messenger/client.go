@@ -2,6 +2,7 @@ package messenger
 
 import (
 	"bytes"
+	"context"
 	"crypto/tls"
 	"encoding/hex"
 	"encoding/json"
@@ -9,11 +10,18 @@ import (
 	"log"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/bloxapp/ssv-spec/dkg"
 	"github.com/bloxapp/ssv-spec/types"
 )
 
+const (
+	DefaultSrvAddr = "https://dkg-messenger.rockx.com"
+
+	healthCheckTimeout = 5 * time.Second
+)
+
 type Client struct {
 	SrvAddr string
 	client  *http.Client
@@ -25,7 +33,7 @@ func NewMessengerClient(srvAddr string) *Client {
 	}
 
 	if srvAddr == "" {
-		srvAddr = "https://dkg-messenger.rockx.com"
+		srvAddr = DefaultSrvAddr
 	}
 
 	return &Client{
@@ -59,6 +67,24 @@ func (cl *Client) StreamDKGOutput(output map[types.OperatorID]*dkg.SignedOutput)
 	return cl.stream("dkgoutput", requestID, data)
 }
 
+func (cl *Client) StreamDKGTimeout(timeout *TimeoutOutput) error {
+	requestID := hex.EncodeToString(timeout.RequestID[:])
+	data, err := json.Marshal(timeout)
+	if err != nil {
+		return err
+	}
+	return cl.stream("dkgtimeout", requestID, data)
+}
+
+func (cl *Client) StreamDKGRejection(rejection *RejectionOutput) error {
+	requestID := hex.EncodeToString(rejection.RequestID[:])
+	data, err := json.Marshal(rejection)
+	if err != nil {
+		return err
+	}
+	return cl.stream("dkgrejection", requestID, data)
+}
+
 func (cl *Client) BroadcastDKGMessage(msg *dkg.SignedMessage) error {
 	requestID := hex.EncodeToString(msg.Message.Identifier[:])
 
@@ -75,7 +101,7 @@ func (cl *Client) BroadcastDKGMessage(msg *dkg.SignedMessage) error {
 	return cl.publish(requestID, ssvMsgBytes)
 }
 
-func (cl *Client) RegisterOperatorNode(id, addr string) error {
+func (cl *Client) RegisterOperatorNode(id, addr, mode string) error {
 	numtries := 3
 	try := 1
 
@@ -84,6 +110,7 @@ func (cl *Client) RegisterOperatorNode(id, addr string) error {
 		sub := &Subscriber{
 			Name:    id,
 			SrvAddr: addr,
+			Mode:    mode,
 		}
 		byts, _ := json.Marshal(sub)
 
@@ -112,6 +139,70 @@ func (cl *Client) RegisterOperatorNode(id, addr string) error {
 	return nil
 }
 
+// IsRegistered checks whether the operator node is registered as a subscriber on the messenger
+func (cl *Client) IsRegistered(id string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), healthCheckTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("%s/subscribers/%s/stats", cl.SrvAddr, id), nil)
+	if err != nil {
+		return err
+	}
+	resp, err := cl.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return nil
+	case http.StatusNotFound:
+		return fmt.Errorf("operator %s is not registered with the messenger", id)
+	default:
+		return fmt.Errorf("messenger responded with status %s", resp.Status)
+	}
+}
+
+// PullMessages long-polls the messenger for messages queued for the subscriber, until wait
+// passes or ctx is done
+func (cl *Client) PullMessages(ctx context.Context, id string, wait time.Duration) ([]*Delivery, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("%s/subscribers/%s/messages?wait=%d", cl.SrvAddr, id, int(wait.Seconds())), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := cl.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to pull messages from messenger with status %s", resp.Status)
+	}
+
+	deliveries := make([]*Delivery, 0)
+	if err := json.NewDecoder(resp.Body).Decode(&deliveries); err != nil {
+		return nil, fmt.Errorf("failed to parse pulled messages: %s", err.Error())
+	}
+	return deliveries, nil
+}
+
+func (cl *Client) AckMessages(id string, deliveryIDs []string) error {
+	data, _ := json.Marshal(&AckRequest{IDs: deliveryIDs})
+
+	resp, err := cl.client.Post(fmt.Sprintf("%s/subscribers/%s/ack", cl.SrvAddr, id), "application/json", bytes.NewBuffer(data))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to ack messages on messenger with status %s", resp.Status)
+	}
+	return nil
+}
+
 func (cl *Client) publish(topicName string, data []byte) error {
 	resp, err := cl.client.Post(fmt.Sprintf("%s/publish?topic_name=%s", cl.SrvAddr, topicName), "application/json", bytes.NewBuffer(data))
 	if err != nil {
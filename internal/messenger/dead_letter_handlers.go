@@ -0,0 +1,85 @@
+package messenger
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+func (m *Messenger) HandleGetDeadLetters() func(*gin.Context) {
+	return func(c *gin.Context) {
+		c.JSON(http.StatusOK, m.DeadLetters.List(c.Query("subscriber"), c.Query("topic")))
+	}
+}
+
+// HandleReplayDeadLetter puts a dead letter back on its subscriber's outgoing queue with a fresh
+// retry budget. The dead letter is kept when the queue is full.
+func (m *Messenger) HandleReplayDeadLetter() func(*gin.Context) {
+	return func(c *gin.Context) {
+		letter, ok := m.DeadLetters.Get(c.Param("id"))
+		if !ok {
+			c.JSON(http.StatusNotFound, gin.H{
+				"message": fmt.Sprintf("dead letter %s not found", c.Param("id")),
+				"error":   "dead letter not found",
+			})
+			return
+		}
+
+		subscriber, ok := m.Topics[DefaultTopic].Subscribers[letter.Subscriber]
+		if !ok {
+			c.JSON(http.StatusNotFound, gin.H{
+				"message": fmt.Sprintf("subscriber %s is not registered anymore", letter.Subscriber),
+				"error":   "subscriber not found",
+			})
+			return
+		}
+
+		msg := &Message{Topic: letter.Topic, Data: letter.Data, PublishedAt: letter.FailedAt}
+		subscriber.clearRetries(msg)
+		select {
+		case subscriber.Outgoing <- msg:
+		default:
+			m.logger.Warnf("HandleReplayDeadLetter: outgoing queue of %s is full, keeping dead letter %s", letter.Subscriber, letter.ID)
+			c.JSON(http.StatusServiceUnavailable, gin.H{
+				"message": fmt.Sprintf("outgoing queue of %s is full, replay the dead letter later", letter.Subscriber),
+				"error":   "outgoing queue full",
+			})
+			return
+		}
+		m.DeadLetters.Remove(letter.ID)
+
+		m.logger.Infof("HandleReplayDeadLetter: replaying dead letter %s to %s", letter.ID, letter.Subscriber)
+		c.JSON(http.StatusOK, letter)
+	}
+}
+
+func (m *Messenger) HandleDeleteDeadLetter() func(*gin.Context) {
+	return func(c *gin.Context) {
+		if _, ok := m.DeadLetters.Remove(c.Param("id")); !ok {
+			c.JSON(http.StatusNotFound, nil)
+			return
+		}
+		c.JSON(http.StatusOK, nil)
+	}
+}
+
+func (m *Messenger) HandleGetSubscriberStats() func(*gin.Context) {
+	return func(c *gin.Context) {
+		stats := make(map[string]*DeliveryStats)
+		for name, subscriber := range m.Topics[DefaultTopic].Subscribers {
+			stats[name] = subscriber.Stats.Snapshot()
+		}
+
+		if name := c.Param("name"); name != "" {
+			st, ok := stats[name]
+			if !ok {
+				c.JSON(http.StatusNotFound, nil)
+				return
+			}
+			c.JSON(http.StatusOK, st)
+			return
+		}
+		c.JSON(http.StatusOK, stats)
+	}
+}
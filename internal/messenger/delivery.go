@@ -0,0 +1,190 @@
+package messenger
+
+import (
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	maxRetriesAllowed = 10
+
+	retryBaseDelay = 500 * time.Millisecond
+	retryMaxDelay  = 30 * time.Second
+)
+
+// retryBackoff returns the delay before the given retry attempt (starting at 1). The delay
+// doubles on every attempt up to retryMaxDelay and is jittered over its upper half so that
+// subscribers failing at the same time don't retry in lockstep.
+func retryBackoff(attempt int) time.Duration {
+	delay := retryMaxDelay
+	if attempt < 16 {
+		if d := retryBaseDelay << uint(attempt-1); d < retryMaxDelay {
+			delay = d
+		}
+	}
+	half := delay / 2
+	return half + time.Duration(rand.Int63n(int64(half)+1))
+}
+
+// DeadLetter is a message that couldn't be delivered to a subscriber within maxRetriesAllowed attempts
+type DeadLetter struct {
+	ID         string    `json:"id"`
+	Subscriber string    `json:"subscriber"`
+	Topic      string    `json:"topic"`
+	Data       []byte    `json:"data"`
+	Attempts   int       `json:"attempts"`
+	LastError  string    `json:"last_error"`
+	FailedAt   time.Time `json:"failed_at"`
+}
+
+type DeadLetterQueue struct {
+	letters map[string]*DeadLetter
+	mu      sync.Mutex
+}
+
+func NewDeadLetterQueue() *DeadLetterQueue {
+	return &DeadLetterQueue{
+		letters: make(map[string]*DeadLetter),
+	}
+}
+
+func (q *DeadLetterQueue) Add(subscriber string, msg *Message, attempts int, lastErr string) *DeadLetter {
+	letter := &DeadLetter{
+		ID:         uuid.New().String(),
+		Subscriber: subscriber,
+		Topic:      msg.Topic,
+		Data:       msg.Data,
+		Attempts:   attempts,
+		LastError:  lastErr,
+		FailedAt:   time.Now().UTC(),
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.letters[letter.ID] = letter
+	return letter
+}
+
+// List returns the dead letters, oldest first, optionally filtered by subscriber and topic
+func (q *DeadLetterQueue) List(subscriber, topic string) []*DeadLetter {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	letters := make([]*DeadLetter, 0)
+	for _, letter := range q.letters {
+		if subscriber != "" && letter.Subscriber != subscriber {
+			continue
+		}
+		if topic != "" && letter.Topic != topic {
+			continue
+		}
+		letters = append(letters, letter)
+	}
+	sort.SliceStable(letters, func(i, j int) bool {
+		return letters[i].FailedAt.Before(letters[j].FailedAt)
+	})
+	return letters
+}
+
+func (q *DeadLetterQueue) Get(id string) (*DeadLetter, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	letter, ok := q.letters[id]
+	return letter, ok
+}
+
+// Remove deletes a dead letter from the queue and returns it
+func (q *DeadLetterQueue) Remove(id string) (*DeadLetter, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	letter, ok := q.letters[id]
+	if ok {
+		delete(q.letters, id)
+	}
+	return letter, ok
+}
+
+// RemoveTopic drops every dead letter of a topic
+func (q *DeadLetterQueue) RemoveTopic(topic string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for id, letter := range q.letters {
+		if letter.Topic == topic {
+			delete(q.letters, id)
+		}
+	}
+}
+
+// DeliveryStats holds the delivery counters of a single subscriber
+type DeliveryStats struct {
+	Delivered    uint64        `json:"delivered"`
+	Failed       uint64        `json:"failed"`
+	Retried      uint64        `json:"retried"`
+	DeadLettered uint64        `json:"dead_lettered"`
+	AvgLatency   time.Duration `json:"avg_latency_ns"`
+	MaxLatency   time.Duration `json:"max_latency_ns"`
+	LastError    string        `json:"last_error"`
+	LastSuccess  time.Time     `json:"last_success"`
+
+	totalLatency time.Duration
+	mu           sync.Mutex
+}
+
+func NewDeliveryStats() *DeliveryStats {
+	return &DeliveryStats{}
+}
+
+// RecordDelivered records a successful delivery with the time it took since the message was published
+func (st *DeliveryStats) RecordDelivered(latency time.Duration) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	st.Delivered++
+	st.totalLatency += latency
+	st.AvgLatency = st.totalLatency / time.Duration(st.Delivered)
+	if latency > st.MaxLatency {
+		st.MaxLatency = latency
+	}
+	st.LastSuccess = time.Now().UTC()
+}
+
+func (st *DeliveryStats) RecordFailed(err string, retried bool) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	st.Failed++
+	st.LastError = err
+	if retried {
+		st.Retried++
+	}
+}
+
+func (st *DeliveryStats) RecordDeadLettered() {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	st.DeadLettered++
+}
+
+// Snapshot returns a copy of the counters that is safe to serialize
+func (st *DeliveryStats) Snapshot() *DeliveryStats {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	return &DeliveryStats{
+		Delivered:    st.Delivered,
+		Failed:       st.Failed,
+		Retried:      st.Retried,
+		DeadLettered: st.DeadLettered,
+		AvgLatency:   st.AvgLatency,
+		MaxLatency:   st.MaxLatency,
+		LastError:    st.LastError,
+		LastSuccess:  st.LastSuccess,
+	}
+}
@@ -0,0 +1,129 @@
+package messenger
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/RockX-SG/frost-dkg-demo/internal/logger"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestSubscriber(name string, queueSize int) *Subscriber {
+	return &Subscriber{
+		Name:         name,
+		Mode:         DeliveryModePush,
+		SubscribesTo: make(map[string]*Topic),
+		Outgoing:     make(chan *Message, queueSize),
+		Inflight:     make(map[string]*Delivery),
+		RetryData:    make(map[string]map[string]int),
+		Stats:        NewDeliveryStats(),
+		deadLetters:  NewDeadLetterQueue(),
+	}
+}
+
+func TestRetryBackoff(t *testing.T) {
+	for attempt, max := range map[int]time.Duration{
+		1:  retryBaseDelay,
+		2:  2 * retryBaseDelay,
+		4:  8 * retryBaseDelay,
+		10: retryMaxDelay,
+		64: retryMaxDelay,
+	} {
+		for i := 0; i < 20; i++ {
+			delay := retryBackoff(attempt)
+			require.GreaterOrEqual(t, delay, max/2, "attempt %d", attempt)
+			require.LessOrEqual(t, delay, max, "attempt %d", attempt)
+		}
+	}
+}
+
+func TestRetryLater(t *testing.T) {
+	log := &logger.Logger{Logger: logrus.New()}
+	msg := &Message{Topic: "ceremony", Data: []byte("message"), PublishedAt: time.Now()}
+
+	// a retryable failure pushes the message again after the backoff
+	s := newTestSubscriber("1", 1)
+	s.retryLater(msg, errors.New("connection refused"), log)
+	select {
+	case retried := <-s.Outgoing:
+		require.Equal(t, msg, retried)
+	case <-time.After(2 * retryBaseDelay):
+		t.Fatal("message wasn't retried")
+	}
+	require.Empty(t, s.deadLetters.List("", ""))
+
+	// until the retries are used up
+	for i := 1; i < maxRetriesAllowed; i++ {
+		s.recordAttempt(msg)
+	}
+	s.retryLater(msg, errors.New("connection refused"), log)
+	letters := s.deadLetters.List("1", "ceremony")
+	require.Len(t, letters, 1)
+	require.Equal(t, maxRetriesAllowed+1, letters[0].Attempts)
+	require.Empty(t, s.RetryData)
+
+	// a message refused for good is dead lettered right away
+	s = newTestSubscriber("1", 1)
+	s.retryLater(msg, &ErrPushFailed{StatusCode: http.StatusUnprocessableEntity}, log)
+	require.Len(t, s.deadLetters.List("", ""), 1)
+
+	// a busy subscriber is retried after the delay it asked for
+	s = newTestSubscriber("1", 1)
+	s.retryLater(msg, &ErrPushFailed{StatusCode: http.StatusTooManyRequests, RetryAfter: time.Hour}, log)
+	require.Empty(t, s.deadLetters.List("", ""))
+	s.mu.Lock()
+	require.Len(t, s.retryTimers["ceremony"], 1)
+	s.mu.Unlock()
+	s.ClearTopic("ceremony")
+}
+
+func TestClearTopicStopsRetries(t *testing.T) {
+	s := newTestSubscriber("1", 1)
+	s.retryLater(&Message{Topic: "ceremony", Data: []byte("message")}, errors.New("connection refused"), &logger.Logger{Logger: logrus.New()})
+	s.ClearTopic("ceremony")
+	require.Empty(t, s.RetryData)
+
+	select {
+	case <-s.Outgoing:
+		t.Fatal("message of a cleared topic was retried")
+	case <-time.After(2 * retryBaseDelay):
+	}
+}
+
+func TestHandleReplayDeadLetter(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	s := newTestSubscriber("1", 1)
+	m := &Messenger{
+		Topics:      map[string]*Topic{DefaultTopic: {Name: DefaultTopic, Subscribers: map[string]*Subscriber{"1": s}}},
+		DeadLetters: s.deadLetters,
+		logger:      &logger.Logger{Logger: logrus.New()},
+	}
+	r := gin.New()
+	r.POST("/dead_letters/:id/replay", m.HandleReplayDeadLetter())
+	replay := func(id string) int {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/dead_letters/"+id+"/replay", nil))
+		return w.Code
+	}
+
+	first := m.DeadLetters.Add("1", &Message{Topic: "ceremony", Data: []byte("first")}, 11, "connection refused")
+	second := m.DeadLetters.Add("1", &Message{Topic: "ceremony", Data: []byte("second")}, 11, "connection refused")
+	require.Equal(t, http.StatusOK, replay(first.ID))
+	_, ok := m.DeadLetters.Get(first.ID)
+	require.False(t, ok)
+
+	// the queue is full, the dead letter is kept for later
+	require.Equal(t, http.StatusServiceUnavailable, replay(second.ID))
+	_, ok = m.DeadLetters.Get(second.ID)
+	require.True(t, ok)
+
+	require.Equal(t, []byte("first"), (<-s.Outgoing).Data)
+	require.Equal(t, http.StatusOK, replay(second.ID))
+	require.Equal(t, []byte("second"), (<-s.Outgoing).Data)
+	require.Equal(t, http.StatusNotFound, replay(second.ID))
+}
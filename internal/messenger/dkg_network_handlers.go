@@ -70,6 +70,9 @@ func (m *Messenger) HandleStreamDKGOutput() func(*gin.Context) {
 			return
 		}
 
+		if _, ok := m.Data[requestID]; !ok {
+			metricsCeremonies.WithLabelValues(ceremonyOutcomeCompleted).Inc()
+		}
 		m.Data[requestID] = &DataStore{DKGOutputs: data}
 		c.JSON(http.StatusOK, nil)
 	}
@@ -90,7 +93,81 @@ func (m *Messenger) HandleStreamDKGBlame() func(*gin.Context) {
 			return
 		}
 
+		if _, ok := m.Data[requestID]; !ok {
+			metricsCeremonies.WithLabelValues(ceremonyOutcomeBlamed).Inc()
+		}
 		m.Data[requestID] = &DataStore{BlameOutput: data}
 		c.JSON(http.StatusOK, nil)
 	}
 }
+
+// HandleStreamDKGTimeout collects the timeouts of a ceremony, unless it already finished with
+// an output or a blame
+func (m *Messenger) HandleStreamDKGTimeout() func(*gin.Context) {
+
+	return func(c *gin.Context) {
+		timeout := new(TimeoutOutput)
+		requestID := c.Query("request_id")
+
+		body, _ := io.ReadAll(c.Request.Body)
+		if err := json.Unmarshal(body, timeout); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"message": "failed to parse request body",
+				"error":   err.Error(),
+			})
+			return
+		}
+
+		data, ok := m.Data[requestID]
+		if !ok {
+			metricsCeremonies.WithLabelValues(ceremonyOutcomeTimedOut).Inc()
+			data = &DataStore{}
+			m.Data[requestID] = data
+		}
+		if !data.finished() {
+			if data.TimeoutOutputs == nil {
+				data.TimeoutOutputs = make(map[types.OperatorID]*TimeoutOutput)
+			}
+			data.TimeoutOutputs[timeout.OperatorID] = timeout
+		}
+		c.JSON(http.StatusOK, nil)
+	}
+}
+
+// HandleStreamDKGRejection collects the operators that refused a ceremony and their reasons,
+// unless it already finished with an output or a blame
+func (m *Messenger) HandleStreamDKGRejection() func(*gin.Context) {
+
+	return func(c *gin.Context) {
+		rejection := new(RejectionOutput)
+		requestID := c.Query("request_id")
+
+		body, _ := io.ReadAll(c.Request.Body)
+		if err := json.Unmarshal(body, rejection); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"message": "failed to parse request body",
+				"error":   err.Error(),
+			})
+			return
+		}
+
+		data, ok := m.Data[requestID]
+		if !ok {
+			metricsCeremonies.WithLabelValues(ceremonyOutcomeRejected).Inc()
+			data = &DataStore{}
+			m.Data[requestID] = data
+		}
+		if !data.finished() {
+			if data.Rejections == nil {
+				data.Rejections = make(map[types.OperatorID]*RejectionOutput)
+			}
+			data.Rejections[rejection.OperatorID] = rejection
+		}
+		c.JSON(http.StatusOK, nil)
+	}
+}
+
+// finished returns true once the ceremony streamed an output or a blame
+func (d *DataStore) finished() bool {
+	return len(d.DKGOutputs) > 0 || d.BlameOutput != nil
+}
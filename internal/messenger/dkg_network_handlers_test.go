@@ -0,0 +1,58 @@
+package messenger
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStreamCeremonyOutcomes(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	m := &Messenger{Data: make(map[string]*DataStore)}
+	r := gin.New()
+	r.POST("/stream/dkgoutput", m.HandleStreamDKGOutput())
+	r.POST("/stream/dkgblame", m.HandleStreamDKGBlame())
+	r.POST("/stream/dkgtimeout", m.HandleStreamDKGTimeout())
+	r.POST("/stream/dkgrejection", m.HandleStreamDKGRejection())
+	stream := func(kind, requestID, body string) {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/stream/"+kind+"?request_id="+requestID, strings.NewReader(body)))
+		require.Equal(t, http.StatusOK, w.Code)
+	}
+	counts := func() [4]float64 {
+		return [4]float64{
+			testutil.ToFloat64(metricsCeremonies.WithLabelValues(ceremonyOutcomeCompleted)),
+			testutil.ToFloat64(metricsCeremonies.WithLabelValues(ceremonyOutcomeBlamed)),
+			testutil.ToFloat64(metricsCeremonies.WithLabelValues(ceremonyOutcomeTimedOut)),
+			testutil.ToFloat64(metricsCeremonies.WithLabelValues(ceremonyOutcomeRejected)),
+		}
+	}
+	before := counts()
+
+	// every operator of a ceremony streams its result, which is counted once by ceremony
+	for _, operator := range []string{"1", "2", "3", "4"} {
+		stream("dkgoutput", "completed", `{"`+operator+`":{}}`)
+		stream("dkgblame", "blamed", `{}`)
+		stream("dkgtimeout", "timed_out", `{"OperatorID":`+operator+`}`)
+	}
+	// by the first result streamed for it
+	stream("dkgoutput", "timed_out", `{"1":{}}`)
+
+	// the operators that didn't refuse a rejected ceremony time out, and both are kept
+	stream("dkgrejection", "rejected", `{"OperatorID":1,"Reasons":["cluster size 4 isn't allowed"]}`)
+	stream("dkgtimeout", "rejected", `{"OperatorID":2}`)
+	require.Equal(t, []string{"cluster size 4 isn't allowed"}, m.Data["rejected"].Rejections[1].Reasons)
+	require.Len(t, m.Data["rejected"].TimeoutOutputs, 1)
+
+	// nothing is added to a ceremony that finished
+	stream("dkgrejection", "blamed", `{"OperatorID":1,"Reasons":["cluster size 4 isn't allowed"]}`)
+	require.Empty(t, m.Data["blamed"].Rejections)
+
+	after := counts()
+	require.Equal(t, [4]float64{before[0] + 1, before[1] + 1, before[2] + 1, before[3] + 1}, after)
+}
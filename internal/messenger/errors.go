@@ -1,6 +1,12 @@
 package messenger
 
-import "fmt"
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
 
 type ErrTopicNotFound struct {
 	TopicName string
@@ -9,3 +15,33 @@ type ErrTopicNotFound struct {
 func (err *ErrTopicNotFound) Error() string {
 	return fmt.Sprintf("topic with name %s not found\n", err.TopicName)
 }
+
+// ErrPushFailed is returned when a subscriber answered a pushed message with another status
+// than 200
+type ErrPushFailed struct {
+	StatusCode int
+	Body       string
+	// RetryAfter is when the subscriber asked for the message to be sent again, if it did
+	RetryAfter time.Duration
+}
+
+func (err *ErrPushFailed) Error() string {
+	return fmt.Sprintf("subscriber responded with status %d: %s", err.StatusCode, err.Body)
+}
+
+// NewErrPushFailed reads the failed response of a subscriber
+func NewErrPushFailed(resp *http.Response) *ErrPushFailed {
+	body, _ := io.ReadAll(resp.Body)
+	err := &ErrPushFailed{StatusCode: resp.StatusCode, Body: string(body)}
+	if seconds, convErr := strconv.Atoi(resp.Header.Get("Retry-After")); convErr == nil && seconds > 0 {
+		err.RetryAfter = time.Duration(seconds) * time.Second
+	}
+	return err
+}
+
+// Permanent returns true when the subscriber refused the message for good, so sending it again
+// won't help
+func (err *ErrPushFailed) Permanent() bool {
+	return err.StatusCode >= 400 && err.StatusCode < 500 &&
+		err.StatusCode != http.StatusRequestTimeout && err.StatusCode != http.StatusTooManyRequests
+}
@@ -6,10 +6,10 @@ import (
 	"crypto/sha256"
 	"encoding/base64"
 	"fmt"
-	"io"
 	"net/http"
 	"os"
 	"strconv"
+	"sync"
 	"time"
 
 	"github.com/RockX-SG/frost-dkg-demo/internal/logger"
@@ -23,9 +23,17 @@ var (
 	DefaultTopic = "default"
 )
 
+const (
+	// DeliveryModePush makes the messenger POST messages to the subscriber's /consume endpoint
+	DeliveryModePush = "push"
+	// DeliveryModePull makes the subscriber fetch and acknowledge its messages from the messenger
+	DeliveryModePull = "pull"
+)
+
 type Messenger struct {
-	Topics map[string]*Topic
-	Data   map[string]*DataStore
+	Topics      map[string]*Topic
+	Data        map[string]*DataStore
+	DeadLetters *DeadLetterQueue
 
 	Incoming chan *Message
 
@@ -42,21 +50,65 @@ type Topic struct {
 }
 
 type Subscriber struct {
-	Name         string            `json:"name"`
-	SrvAddr      string            `json:"srv_addr"`
-	SubscribesTo map[string]*Topic `json:"-"`
-	Outgoing     chan *Message     `json:"-"`
-	RetryData    map[string]int    `json:"-"`
+	Name         string               `json:"name"`
+	SrvAddr      string               `json:"srv_addr"`
+	Mode         string               `json:"mode"`
+	SubscribesTo map[string]*Topic    `json:"-"`
+	Outgoing     chan *Message        `json:"-"`
+	Inflight     map[string]*Delivery `json:"-"`
+	Stats        *DeliveryStats       `json:"-"`
+
+	// RetryData holds the number of failed delivery attempts per message hash, keyed by
+	// the topic (ceremony request ID) the message was published to
+	RetryData map[string]map[string]int `json:"-"`
+
+	// retryTimers are the pending retries by topic, stopped when the topic is cleared
+	retryTimers map[string]map[*time.Timer]bool
+	deadLetters *DeadLetterQueue
+	mu          sync.Mutex
+}
+
+func (s *Subscriber) IsPullMode() bool {
+	return s.Mode == DeliveryModePull
 }
 
 type Message struct {
-	Topic string
-	Data  []byte
+	Topic       string
+	Data        []byte
+	PublishedAt time.Time
 }
 
 type DataStore struct {
 	DKGOutputs  map[types.OperatorID]*dkg.SignedOutput
 	BlameOutput *dkg.BlameOutput
+	// TimeoutOutputs are the timeouts streamed by the operators of a ceremony that stalled
+	TimeoutOutputs map[types.OperatorID]*TimeoutOutput `json:",omitempty"`
+	// Rejections are streamed by the operators whose policy refused the ceremony
+	Rejections map[types.OperatorID]*RejectionOutput `json:",omitempty"`
+}
+
+// RejectionOutput is what an operator streams when its policy refuses to join a ceremony
+type RejectionOutput struct {
+	RequestID  dkg.RequestID
+	OperatorID types.OperatorID
+	Reasons    []string
+}
+
+// Ceremony deadlines a TimeoutOutput can report
+const (
+	DeadlineRound    = "round"
+	DeadlineCeremony = "ceremony"
+)
+
+// TimeoutOutput is what an operator streams when a ceremony stalled. Round is the round the
+// operator waited on when the deadline expired, and Missing the operators whose message of
+// that round it didn't get.
+type TimeoutOutput struct {
+	RequestID  dkg.RequestID
+	OperatorID types.OperatorID
+	Round      string
+	Missing    []types.OperatorID
+	Deadline   string
 }
 
 func (m *Messenger) Publish(topicName string, data []byte) error {
@@ -66,7 +118,7 @@ func (m *Messenger) Publish(topicName string, data []byte) error {
 		return &ErrTopicNotFound{TopicName: topicName}
 	}
 
-	m.Incoming <- &Message{Topic: tp.Name, Data: data}
+	m.Incoming <- &Message{Topic: tp.Name, Data: data, PublishedAt: time.Now()}
 	return nil
 }
 
@@ -75,23 +127,27 @@ func (m *Messenger) ProcessIncomingMessageWorker(ctx *context.Context) {
 		tp, exist := m.Topics[msg.Topic]
 		if !exist {
 			var err = &ErrTopicNotFound{TopicName: msg.Topic}
-			m.logger.Errorf("ProcessIncomingMessageWorker: %w", err)
+			m.logger.Errorf("ProcessIncomingMessageWorker: %v", err)
+			metricsMessagesDropped.WithLabelValues("", dropReasonTopicNotFound).Inc()
 			continue
 		}
 
 		ssvMsg := &types.SSVMessage{}
 		if err := ssvMsg.Decode(msg.Data); err != nil {
-			m.logger.Errorf("ProcessIncomingMessageWorker: %w", err)
+			m.logger.Errorf("ProcessIncomingMessageWorker: %v", err)
+			metricsMessagesDropped.WithLabelValues("", dropReasonUndecodable).Inc()
 			continue
 		}
 		signedMsg := &dkg.SignedMessage{}
 		if err := signedMsg.Decode(ssvMsg.Data); err != nil {
-			m.logger.Errorf("ProcessIncomingMessageWorker: failed to decode signed message: %w", err)
+			m.logger.Errorf("ProcessIncomingMessageWorker: failed to decode signed message: %v", err)
+			metricsMessagesDropped.WithLabelValues("", dropReasonUndecodable).Inc()
 			continue
 		}
 		protocolMsg := &frost.ProtocolMsg{}
 		if err := protocolMsg.Decode(signedMsg.Message.Data); err != nil {
-			m.logger.Errorf("ProcessIncomingMessageWorker: failed to decode protocol message: %w", err)
+			m.logger.Errorf("ProcessIncomingMessageWorker: failed to decode protocol message: %v", err)
+			metricsMessagesDropped.WithLabelValues("", dropReasonUndecodable).Inc()
 			continue
 		}
 
@@ -101,6 +157,10 @@ func (m *Messenger) ProcessIncomingMessageWorker(ctx *context.Context) {
 			signedMsg.Message.MsgType,
 			protocolMsg.Round,
 		)
+		metricsMessagesRelayed.WithLabelValues(
+			MsgTypeName(signedMsg.Message.MsgType),
+			RoundName(signedMsg.Message.MsgType, protocolMsg.Round),
+		).Inc()
 
 		for _, subscriber := range tp.Subscribers {
 			operatorID := strconv.Itoa(int(signedMsg.Signer))
@@ -112,9 +172,7 @@ func (m *Messenger) ProcessIncomingMessageWorker(ctx *context.Context) {
 	}
 }
 
-const (
-	maxRetriesAllowed = 10
-)
+var outgoingClient = &http.Client{Timeout: 30 * time.Second}
 
 func (s *Subscriber) ProcessOutgoingMessageWorker(ctx *context.Context) {
 
@@ -125,51 +183,143 @@ func (s *Subscriber) ProcessOutgoingMessageWorker(ctx *context.Context) {
 	logger := log.(*logger.Logger)
 	logger.Infof("ProcessOutgoingMessageWorker: logger loaded successfully")
 
-	for msg := range s.Outgoing {
-
-		h := sha256.Sum256(msg.Data)
-		k := base64.RawStdEncoding.EncodeToString(h[:])
-
-		numRetries, ok := s.RetryData[k]
-		if ok {
-			if numRetries >= maxRetriesAllowed {
-				continue
-			} else {
-				s.RetryData[k]++
-			}
-		} else {
-			s.RetryData[k] = 0
-		}
-
-		if numRetries > 0 {
-			time.Sleep(2 * (time.Second))
+	// a worker cancelled while messages are queued stops before taking one
+	for (*ctx).Err() == nil {
+		var msg *Message
+		select {
+		case <-(*ctx).Done():
+			continue
+		case msg = <-s.Outgoing:
 		}
 
 		_, exist := s.SubscribesTo[msg.Topic]
 		if !exist {
 			var err = &ErrTopicNotFound{TopicName: msg.Topic}
-			logger.Errorf("ProcessOutgoingMessageWorker: %w", err)
+			logger.Errorf("ProcessOutgoingMessageWorker: %v", err)
+			metricsMessagesDropped.WithLabelValues(s.Name, dropReasonTopicNotFound).Inc()
 			continue
 		}
 
-		// TODO: replace this client
-		resp, err := http.Post(fmt.Sprintf("%s/consume", s.SrvAddr), "application/json", bytes.NewBuffer(msg.Data))
-		if err != nil {
-			logger.Errorf("ProcessOutgoingMessageWorker: %w", err)
+		if err := s.push(msg); err != nil {
+			logger.Errorf("ProcessOutgoingMessageWorker: failed to publish message to the subscriber %s: %v", s.Name, err)
+			s.retryLater(msg, err, logger)
 			continue
 		}
 
-		respbody, _ := io.ReadAll(resp.Body)
-		if resp.StatusCode != http.StatusOK {
-			s.Outgoing <- msg
+		s.clearRetries(msg)
+		s.recordDelivered(msg)
+		logger.Infof("ProcessOutgoingMessageWorker: message sent to %s successfully", s.Name)
+	}
+	logger.Infof("ProcessOutgoingMessageWorker: stopped pushing messages to %s", s.Name)
+}
+
+func (s *Subscriber) push(msg *Message) error {
+	resp, err := outgoingClient.Post(fmt.Sprintf("%s/consume", s.SrvAddr), "application/json", bytes.NewBuffer(msg.Data))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
 
-			err := fmt.Errorf("failed to publish message to the subscriber %s %v", s.Name, string(respbody))
-			logger.Errorf("ProcessOutgoingMessageWorker: %w", err)
+	if resp.StatusCode != http.StatusOK {
+		return NewErrPushFailed(resp)
+	}
+	return nil
+}
+
+// retryLater schedules the message to be pushed again after an exponential backoff, or the
+// delay the subscriber asked for if it's longer. It moves the message to the dead letter queue
+// once it has used up all of its retries, or right away when the subscriber refused it for good.
+func (s *Subscriber) retryLater(msg *Message, cause error, logger *logger.Logger) {
+	attempts := s.recordAttempt(msg)
+	errPush, pushFailed := cause.(*ErrPushFailed)
+	refused := pushFailed && errPush.Permanent()
+	if refused || attempts > maxRetriesAllowed {
+		s.recordDeadLettered(cause.Error())
+		s.clearRetries(msg)
+		letter := s.deadLetters.Add(s.Name, msg, attempts, cause.Error())
+		if refused {
+			logger.Errorf("ProcessOutgoingMessageWorker: %s refused message for good, moved to dead letter %s", s.Name, letter.ID)
 		} else {
-			logger.Infof("ProcessOutgoingMessageWorker: message sent to %s successfully", s.Name)
+			logger.Errorf("ProcessOutgoingMessageWorker: gave up on message for %s after %d attempts, moved to dead letter %s", s.Name, attempts, letter.ID)
 		}
-		resp.Body.Close()
+		return
+	}
+
+	s.recordRetry(cause.Error())
+	delay := retryBackoff(attempts)
+	if pushFailed && errPush.RetryAfter > delay {
+		delay = errPush.RetryAfter
+	}
+	logger.Debugf("ProcessOutgoingMessageWorker: retrying message for %s in %s (attempt %d)", s.Name, delay, attempts)
+	s.scheduleRetry(msg, delay)
+}
+
+// scheduleRetry queues the message again after delay, unless its topic is cleared before
+func (s *Subscriber) scheduleRetry(msg *Message, delay time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.retryTimers == nil {
+		s.retryTimers = make(map[string]map[*time.Timer]bool)
+	}
+	if _, ok := s.retryTimers[msg.Topic]; !ok {
+		s.retryTimers[msg.Topic] = make(map[*time.Timer]bool)
+	}
+	var timer *time.Timer
+	timer = time.AfterFunc(delay, func() {
+		s.mu.Lock()
+		pending := s.retryTimers[msg.Topic][timer]
+		delete(s.retryTimers[msg.Topic], timer)
+		if len(s.retryTimers[msg.Topic]) == 0 {
+			delete(s.retryTimers, msg.Topic)
+		}
+		s.mu.Unlock()
+
+		if pending {
+			s.Outgoing <- msg
+		}
+	})
+	s.retryTimers[msg.Topic][timer] = true
+}
+
+func messageKey(msg *Message) string {
+	h := sha256.Sum256(msg.Data)
+	return base64.RawStdEncoding.EncodeToString(h[:])
+}
+
+func (s *Subscriber) recordAttempt(msg *Message) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.RetryData[msg.Topic]; !ok {
+		s.RetryData[msg.Topic] = make(map[string]int)
+	}
+	k := messageKey(msg)
+	s.RetryData[msg.Topic][k]++
+	return s.RetryData[msg.Topic][k]
+}
+
+func (s *Subscriber) clearRetries(msg *Message) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if retries, ok := s.RetryData[msg.Topic]; ok {
+		delete(retries, messageKey(msg))
+		if len(retries) == 0 {
+			delete(s.RetryData, msg.Topic)
+		}
+	}
+}
+
+// ClearTopic drops all retry state the subscriber holds for a topic, and stops its pending retries
+func (s *Subscriber) ClearTopic(topic string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.RetryData, topic)
+	for timer := range s.retryTimers[topic] {
+		timer.Stop()
 	}
+	delete(s.retryTimers, topic)
 }
 
 func MessengerAddrFromEnv() string {
@@ -0,0 +1,140 @@
+package messenger
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/bloxapp/ssv-spec/dkg"
+	"github.com/bloxapp/ssv-spec/dkg/frost"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	metricsMessagesRelayed = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "dkg_messenger_messages_relayed_total",
+		Help: "Number of dkg messages fanned out to the subscribers of a topic",
+	}, []string{"msg_type", "round"})
+
+	metricsDeliveryLatency = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "dkg_messenger_delivery_latency_seconds",
+		Help:    "Time from a message being published to it being delivered to a subscriber",
+		Buckets: []float64{0.01, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 300},
+	}, []string{"subscriber", "mode"})
+
+	metricsDeliveryRetries = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "dkg_messenger_delivery_retries_total",
+		Help: "Number of times a message delivery to a subscriber was retried",
+	}, []string{"subscriber"})
+
+	metricsMessagesDropped = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "dkg_messenger_messages_dropped_total",
+		Help: "Number of messages that were not delivered, by reason",
+	}, []string{"subscriber", "reason"})
+
+	// metricsCeremonies counts a ceremony once, not once per operator streaming its result
+	metricsCeremonies = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "dkg_messenger_ceremonies_total",
+		Help: "Number of ceremonies that streamed a final result, by the outcome first streamed for the ceremony",
+	}, []string{"outcome"})
+
+	queueDepthDesc = prometheus.NewDesc(
+		"dkg_messenger_outgoing_queue_depth",
+		"Number of messages waiting in a subscriber's outgoing queue",
+		[]string{"subscriber", "mode"}, nil,
+	)
+)
+
+const (
+	dropReasonDeadLettered  = "dead_lettered"
+	dropReasonTopicNotFound = "topic_not_found"
+	dropReasonUndecodable   = "undecodable"
+
+	ceremonyOutcomeCompleted = "completed"
+	ceremonyOutcomeBlamed    = "blamed"
+	ceremonyOutcomeTimedOut  = "timed_out"
+	ceremonyOutcomeRejected  = "rejected"
+)
+
+// MsgTypeName returns a readable name of a dkg message type to be used in logs and metric labels
+func MsgTypeName(msgType dkg.MsgType) string {
+	switch msgType {
+	case dkg.InitMsgType:
+		return "init"
+	case dkg.ProtocolMsgType:
+		return "protocol"
+	case dkg.DepositDataMsgType:
+		return "deposit_data"
+	case dkg.OutputMsgType:
+		return "output"
+	case dkg.ReshareMsgType:
+		return "reshare"
+	default:
+		return strconv.Itoa(int(msgType))
+	}
+}
+
+// RoundName returns the frost round of a protocol message, or an empty string for other message types
+func RoundName(msgType dkg.MsgType, round frost.ProtocolRound) string {
+	if msgType != dkg.ProtocolMsgType {
+		return ""
+	}
+	return round.String()
+}
+
+// RegisterMetrics registers the collectors that read the messenger's topics and queues on every scrape
+func (m *Messenger) RegisterMetrics() {
+	promauto.NewGaugeFunc(prometheus.GaugeOpts{
+		Name: "dkg_messenger_active_topics",
+		Help: "Number of topics (ceremonies) on the messenger, not counting the default topic",
+	}, func() float64 {
+		return float64(len(m.Topics) - 1)
+	})
+
+	promauto.NewGaugeFunc(prometheus.GaugeOpts{
+		Name: "dkg_messenger_registered_subscribers",
+		Help: "Number of operator nodes registered with the messenger",
+	}, func() float64 {
+		return float64(len(m.Topics[DefaultTopic].Subscribers))
+	})
+
+	promauto.NewGaugeFunc(prometheus.GaugeOpts{
+		Name: "dkg_messenger_incoming_queue_depth",
+		Help: "Number of published messages waiting to be fanned out",
+	}, func() float64 {
+		return float64(len(m.Incoming))
+	})
+
+	prometheus.MustRegister(&queueCollector{m: m})
+}
+
+type queueCollector struct {
+	m *Messenger
+}
+
+func (c *queueCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- queueDepthDesc
+}
+
+func (c *queueCollector) Collect(ch chan<- prometheus.Metric) {
+	for name, subscriber := range c.m.Topics[DefaultTopic].Subscribers {
+		ch <- prometheus.MustNewConstMetric(queueDepthDesc, prometheus.GaugeValue, float64(len(subscriber.Outgoing)), name, subscriber.Mode)
+	}
+}
+
+func (s *Subscriber) recordDelivered(msg *Message) {
+	latency := time.Since(msg.PublishedAt)
+	s.Stats.RecordDelivered(latency)
+	metricsDeliveryLatency.WithLabelValues(s.Name, s.Mode).Observe(latency.Seconds())
+}
+
+func (s *Subscriber) recordRetry(cause string) {
+	s.Stats.RecordFailed(cause, true)
+	metricsDeliveryRetries.WithLabelValues(s.Name).Inc()
+}
+
+func (s *Subscriber) recordDeadLettered(cause string) {
+	s.Stats.RecordFailed(cause, false)
+	s.Stats.RecordDeadLettered()
+	metricsMessagesDropped.WithLabelValues(s.Name, dropReasonDeadLettered).Inc()
+}
@@ -0,0 +1,206 @@
+package messenger
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+const (
+	defaultPullWait = 30 * time.Second
+	maxPullWait     = 60 * time.Second
+	maxPullBatch    = 50
+	ackTimeout      = 30 * time.Second
+)
+
+// Delivery is a message handed out to a pull mode subscriber. It stays in flight
+// until the subscriber acknowledges it, and is handed out again if the ack doesn't
+// arrive before its deadline.
+type Delivery struct {
+	ID       string `json:"id"`
+	Topic    string `json:"topic"`
+	Data     []byte `json:"data"`
+	Attempts int    `json:"attempts"`
+
+	deadline time.Time
+	msg      *Message
+}
+
+type AckRequest struct {
+	IDs []string `json:"ids"`
+}
+
+// Pull waits up to `wait` for messages queued for the subscriber and returns at most
+// `max` of them. Deliveries whose ack deadline passed are returned first.
+func (s *Subscriber) Pull(ctx context.Context, max int, wait time.Duration) []*Delivery {
+	deliveries := s.expiredDeliveries(max)
+	if len(deliveries) == 0 {
+		timer := time.NewTimer(wait)
+		defer timer.Stop()
+
+		select {
+		case <-ctx.Done():
+			return deliveries
+		case <-timer.C:
+			return deliveries
+		case msg := <-s.Outgoing:
+			if d := s.newDelivery(msg); d != nil {
+				deliveries = append(deliveries, d)
+			}
+		}
+	}
+
+	for len(deliveries) < max {
+		select {
+		case msg := <-s.Outgoing:
+			if d := s.newDelivery(msg); d != nil {
+				deliveries = append(deliveries, d)
+			}
+		default:
+			return deliveries
+		}
+	}
+	return deliveries
+}
+
+// Ack removes the acknowledged deliveries from the in-flight set and returns how many were found
+func (s *Subscriber) Ack(ids []string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	acked := 0
+	for _, id := range ids {
+		if d, ok := s.Inflight[id]; ok {
+			delete(s.Inflight, id)
+			s.recordDelivered(d.msg)
+			acked++
+		}
+	}
+	return acked
+}
+
+func (s *Subscriber) newDelivery(msg *Message) *Delivery {
+	if _, exist := s.SubscribesTo[msg.Topic]; !exist {
+		return nil
+	}
+
+	d := &Delivery{
+		ID:       uuid.New().String(),
+		Topic:    msg.Topic,
+		Data:     msg.Data,
+		Attempts: 1,
+		deadline: time.Now().Add(ackTimeout),
+		msg:      msg,
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Inflight[d.ID] = d
+	return d
+}
+
+func (s *Subscriber) expiredDeliveries(max int) []*Delivery {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	deliveries := make([]*Delivery, 0)
+	for id, d := range s.Inflight {
+		if len(deliveries) == max {
+			break
+		}
+		if now.Before(d.deadline) {
+			continue
+		}
+		if d.Attempts > maxRetriesAllowed {
+			delete(s.Inflight, id)
+			s.recordDeadLettered("ack deadline exceeded")
+			s.deadLetters.Add(s.Name, d.msg, d.Attempts, "ack deadline exceeded")
+			continue
+		}
+		s.recordRetry("ack deadline exceeded")
+		d.Attempts++
+		d.deadline = now.Add(ackTimeout)
+		deliveries = append(deliveries, d)
+	}
+	return deliveries
+}
+
+func (m *Messenger) pullSubscriber(c *gin.Context) (*Subscriber, bool) {
+	name := c.Param("name")
+	subscriber, ok := m.Topics[DefaultTopic].Subscribers[name]
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{
+			"message": fmt.Sprintf("subscriber %s is not registered", name),
+			"error":   "subscriber not found",
+		})
+		return nil, false
+	}
+	if !subscriber.IsPullMode() {
+		c.JSON(http.StatusConflict, gin.H{
+			"message": fmt.Sprintf("subscriber %s is registered in %s mode", name, subscriber.Mode),
+			"error":   "subscriber is not in pull mode",
+		})
+		return nil, false
+	}
+	return subscriber, true
+}
+
+func (m *Messenger) HandlePullMessages() func(*gin.Context) {
+
+	return func(c *gin.Context) {
+		subscriber, ok := m.pullSubscriber(c)
+		if !ok {
+			return
+		}
+
+		wait := defaultPullWait
+		if c.Query("wait") != "" {
+			seconds, err := strconv.Atoi(c.Query("wait"))
+			if err != nil || seconds < 0 {
+				c.JSON(http.StatusBadRequest, gin.H{
+					"message": "wait has to be a non negative number of seconds",
+					"error":   fmt.Sprintf("invalid wait %s", c.Query("wait")),
+				})
+				return
+			}
+			wait = time.Duration(seconds) * time.Second
+		}
+		if wait > maxPullWait {
+			wait = maxPullWait
+		}
+
+		deliveries := subscriber.Pull(c.Request.Context(), maxPullBatch, wait)
+		c.JSON(http.StatusOK, deliveries)
+	}
+}
+
+func (m *Messenger) HandleAckMessages() func(*gin.Context) {
+
+	return func(c *gin.Context) {
+		subscriber, ok := m.pullSubscriber(c)
+		if !ok {
+			return
+		}
+
+		req := &AckRequest{}
+		if err := c.ShouldBindJSON(req); err != nil {
+			m.logger.Errorf("HandleAckMessages: failed to parse ack request from request body: %v", err)
+			c.JSON(http.StatusBadRequest, gin.H{
+				"message": "failed to load data from request body",
+				"error":   err.Error(),
+			})
+			return
+		}
+
+		acked := subscriber.Ack(req.IDs)
+		c.JSON(http.StatusOK, gin.H{
+			"acked": acked,
+		})
+	}
+}
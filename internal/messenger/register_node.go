@@ -17,7 +17,7 @@ func (m *Messenger) HandleNodeRegistration(runner *workers.Runner) func(*gin.Con
 		_, exist := m.Topics[subscribesTo]
 		if !exist {
 			err := &ErrTopicNotFound{TopicName: subscribesTo}
-			m.logger.Errorf("HandleNodeRegistration: %w", err)
+			m.logger.Errorf("HandleNodeRegistration: %v", err)
 			c.JSON(http.StatusNotFound, gin.H{
 				"message": fmt.Sprintf("topic %s doesn't exist", subscribesTo),
 				"error":   err.Error(),
@@ -27,12 +27,10 @@ func (m *Messenger) HandleNodeRegistration(runner *workers.Runner) func(*gin.Con
 
 		subscriber := &Subscriber{
 			SubscribesTo: map[string]*Topic{},
-			Outgoing:     make(chan *Message, 50),
-			RetryData:    make(map[string]int),
 		}
 
 		if err := c.ShouldBindJSON(subscriber); err != nil {
-			m.logger.Errorf("HandleNodeRegistration: failed to parse subscriber from request body: %w", err)
+			m.logger.Errorf("HandleNodeRegistration: failed to parse subscriber from request body: %v", err)
 			c.JSON(http.StatusBadRequest, gin.H{
 				"message": "failed to parse subscriber data from the request body",
 				"error":   err.Error(),
@@ -40,9 +38,22 @@ func (m *Messenger) HandleNodeRegistration(runner *workers.Runner) func(*gin.Con
 			return
 		}
 
-		if subscriber.Name == "" || subscriber.SrvAddr == "" {
+		if subscriber.Mode == "" {
+			subscriber.Mode = DeliveryModePush
+		}
+		if subscriber.Mode != DeliveryModePush && subscriber.Mode != DeliveryModePull {
+			err := fmt.Errorf("unknown delivery mode %s", subscriber.Mode)
+			m.logger.Errorf("HandleNodeRegistration: %v", err)
+			c.JSON(http.StatusBadRequest, gin.H{
+				"message": "invalid subscriber data: delivery mode has to be push or pull",
+				"error":   err.Error(),
+			})
+			return
+		}
+
+		if subscriber.Name == "" || (subscriber.SrvAddr == "" && !subscriber.IsPullMode()) {
 			err := fmt.Errorf("empty name %s or subscriber's address %s", subscriber.Name, subscriber.SrvAddr)
-			m.logger.Errorf("HandleNodeRegistration: %w", err)
+			m.logger.Errorf("HandleNodeRegistration: %v", err)
 			c.JSON(http.StatusBadRequest, gin.H{
 				"message": "invalid subscriber data: empty name or addr",
 				"error":   err.Error(),
@@ -50,20 +61,34 @@ func (m *Messenger) HandleNodeRegistration(runner *workers.Runner) func(*gin.Con
 			return
 		}
 
+		jobID := fmt.Sprintf("SUBSCRIBER__%s", subscriber.Name)
 		existingSubscriber, ok := m.Topics[subscribesTo].Subscribers[subscriber.Name]
 		if ok {
 			existingSubscriber.SrvAddr = subscriber.SrvAddr
+			if existingSubscriber.Mode != subscriber.Mode {
+				if existingSubscriber.IsPullMode() {
+					runner.AddJob(&workers.Job{ID: jobID, Fn: existingSubscriber.ProcessOutgoingMessageWorker})
+				} else {
+					runner.Cancel(jobID)
+				}
+				existingSubscriber.Mode = subscriber.Mode
+			}
 			m.Topics[subscribesTo].Subscribers[subscriber.Name] = existingSubscriber
 		} else {
 			subscriber.Outgoing = make(chan *Message, 50)
-			subscriber.RetryData = make(map[string]int)
+			subscriber.RetryData = make(map[string]map[string]int)
+			subscriber.Inflight = make(map[string]*Delivery)
+			subscriber.Stats = NewDeliveryStats()
+			subscriber.deadLetters = m.DeadLetters
 			subscriber.SubscribesTo[subscribesTo] = m.Topics[subscribesTo]
 			m.Topics[subscribesTo].Subscribers[subscriber.Name] = subscriber
 
-			runner.AddJob(&workers.Job{
-				ID: fmt.Sprintf("SUBSCRIBER__%s", subscriber.Name),
-				Fn: m.Topics[subscribesTo].Subscribers[subscriber.Name].ProcessOutgoingMessageWorker,
-			})
+			if !subscriber.IsPullMode() {
+				runner.AddJob(&workers.Job{
+					ID: jobID,
+					Fn: m.Topics[subscribesTo].Subscribers[subscriber.Name].ProcessOutgoingMessageWorker,
+				})
+			}
 		}
 		c.JSON(http.StatusOK, nil)
 	}
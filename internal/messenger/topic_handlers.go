@@ -21,7 +21,7 @@ func (m *Messenger) HandleCreateTopic() func(*gin.Context) {
 	return func(c *gin.Context) {
 		topicJSON := &TopicJSON{}
 		if err := c.ShouldBindJSON(topicJSON); err != nil {
-			m.logger.Errorf("HandleCreateTopic: failed to parse topic from request body: %w", err)
+			m.logger.Errorf("HandleCreateTopic: failed to parse topic from request body: %v", err)
 			c.JSON(http.StatusBadRequest, gin.H{
 				"message": "failed to load data from request body",
 				"error":   err.Error(),
@@ -64,6 +64,10 @@ func (m *Messenger) DeleteTopic() func(*gin.Context) {
 			ctx.JSON(http.StatusNotFound, nil)
 			return
 		}
+		for _, subscriber := range topic.Subscribers {
+			subscriber.ClearTopic(topic.Name)
+		}
+		m.DeadLetters.RemoveTopic(topic.Name)
 		delete(m.Topics, topic.Name)
 	}
 }
@@ -0,0 +1,102 @@
+package network
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"gopkg.in/yaml.v3"
+)
+
+// NetworksFileEnv is the environment variable tools read the path of a custom networks file from
+const NetworksFileEnv = "DKG_NETWORKS_FILE"
+
+// networkDefinition is a network in a custom networks file
+type networkDefinition struct {
+	Name            string `yaml:"name"`
+	ForkVersion     string `yaml:"fork_version"`
+	DepositContract string `yaml:"deposit_contract"`
+	AmountUnit      string `yaml:"amount_unit"`
+	DomainType      string `yaml:"domain_type"`
+	RegistryURL     string `yaml:"registry_url"`
+}
+
+type networksFile struct {
+	Networks []*networkDefinition `yaml:"networks"`
+}
+
+// LoadFile adds the networks defined in a YAML file to the known networks, so they can be
+// selected by name like the built-in ones. Only the name and fork version are required, the
+// domain type and registry are only needed by nodes.
+func LoadFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to read networks file %s: %v", path, err)
+	}
+	defer f.Close()
+
+	file := &networksFile{}
+	decoder := yaml.NewDecoder(f)
+	decoder.KnownFields(true)
+	if err := decoder.Decode(file); err != nil {
+		return fmt.Errorf("failed to parse networks file %s: %v", path, err)
+	}
+
+	loaded := make(map[string]*Network)
+	for _, def := range file.Networks {
+		n, err := def.network()
+		if err != nil {
+			return fmt.Errorf("networks file %s: %v", path, err)
+		}
+		if _, exist := loaded[n.Name]; exist {
+			return fmt.Errorf("networks file %s: network %s is defined twice", path, n.Name)
+		}
+		loaded[n.Name] = n
+	}
+
+	for name, n := range loaded {
+		profiles[name] = n
+	}
+	return nil
+}
+
+// LoadFileFromEnv loads the networks file named by DKG_NETWORKS_FILE, if set
+func LoadFileFromEnv() error {
+	if path := os.Getenv(NetworksFileEnv); path != "" {
+		return LoadFile(path)
+	}
+	return nil
+}
+
+func (def *networkDefinition) network() (*Network, error) {
+	name := strings.ToLower(def.Name)
+	if name == "" {
+		return nil, errors.New("network without a name")
+	}
+	if name == Custom || builtin(name) {
+		return nil, fmt.Errorf("network %s can't be redefined", name)
+	}
+
+	n, err := NewCustom(def.ForkVersion, def.DomainType, def.RegistryURL)
+	if err != nil {
+		return nil, fmt.Errorf("network %s: %v", name, err)
+	}
+	n.Name = name
+
+	if def.DepositContract != "" {
+		if !common.IsHexAddress(def.DepositContract) {
+			return nil, fmt.Errorf("network %s: invalid deposit contract address %s", name, def.DepositContract)
+		}
+		n.DepositContract = def.DepositContract
+	}
+	if def.AmountUnit != "" {
+		n.AmountUnit = def.AmountUnit
+	}
+	return n, nil
+}
+
+func builtin(name string) bool {
+	return name == Mainnet || name == Holesky || name == Hoodi
+}
@@ -0,0 +1,115 @@
+package network
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/bloxapp/ssv-spec/types"
+	"github.com/stretchr/testify/require"
+)
+
+// writeNetworksFile writes a networks file and restores the known networks once the test ends
+func writeNetworksFile(t *testing.T, content string) string {
+	t.Helper()
+	saved := make(map[string]*Network)
+	for name, n := range profiles {
+		saved[name] = n
+	}
+	t.Cleanup(func() { profiles = saved })
+
+	path := filepath.Join(t.TempDir(), "networks.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0600))
+	return path
+}
+
+// gnosisNetworks is the Gnosis Chain example of the README
+const gnosisNetworks = `networks:
+  - name: gnosis
+    fork_version: "0x00000064"
+    deposit_contract: "0x0B98057eA310F4d31F2a452B414647007d1645d9"
+    amount_unit: mGNO
+    # only needed by DKG nodes running on this network
+    domain_type: "0x00000001"
+    registry_url: https://registry.example.com/api/v4/gnosis
+`
+
+func TestLoadFileGnosis(t *testing.T) {
+	// the verify tool loads the file named by DKG_NETWORKS_FILE and selects the network by name
+	t.Setenv(NetworksFileEnv, writeNetworksFile(t, gnosisNetworks))
+	require.NoError(t, LoadFileFromEnv())
+
+	n, err := FromName("Gnosis")
+	require.NoError(t, err)
+	require.Equal(t, &Network{
+		Name:            "gnosis",
+		ForkVersion:     phase0.Version{0x00, 0x00, 0x00, 0x64},
+		DomainType:      types.DomainType{0x00, 0x00, 0x00, 0x01},
+		RegistryURL:     "https://registry.example.com/api/v4/gnosis",
+		DepositContract: "0x0B98057eA310F4d31F2a452B414647007d1645d9",
+		AmountUnit:      "mGNO",
+	}, n)
+	require.Contains(t, Names(), "gnosis")
+	require.NoError(t, n.CheckForkVersion(phase0.Version{0x00, 0x00, 0x00, 0x64}))
+
+	// only the name and fork version are required
+	require.NoError(t, LoadFile(writeNetworksFile(t, "networks:\n  - name: devnet\n    fork_version: \"0x10000001\"\n")))
+	n, err = FromName("devnet")
+	require.NoError(t, err)
+	require.Equal(t, "ETH", n.AmountUnit)
+	require.Empty(t, n.DepositContract)
+}
+
+func TestLoadFileErrors(t *testing.T) {
+	for _, test := range []struct {
+		name    string
+		content string
+		err     string
+	}{
+		{"missing name", "networks:\n  - fork_version: \"0x00000064\"\n", "network without a name"},
+		{"missing fork version", "networks:\n  - name: gnosis\n", "invalid fork version"},
+		{"short fork version", "networks:\n  - name: gnosis\n    fork_version: \"0x0064\"\n", "expected 4 bytes"},
+		{"invalid deposit contract", "networks:\n  - name: gnosis\n    fork_version: \"0x00000064\"\n    deposit_contract: \"0x0B98\"\n", "invalid deposit contract address"},
+		{"duplicate names", "networks:\n  - name: gnosis\n    fork_version: \"0x00000064\"\n  - name: Gnosis\n    fork_version: \"0x00000065\"\n", "defined twice"},
+		{"redefined built-in", "networks:\n  - name: mainnet\n    fork_version: \"0x00000064\"\n", "can't be redefined"},
+		{"redefined custom", "networks:\n  - name: custom\n    fork_version: \"0x00000064\"\n", "can't be redefined"},
+		{"unknown key", "networks:\n  - name: gnosis\n    fork_version: \"0x00000064\"\n    chain_id: 100\n", "field chain_id not found"},
+		{"not yaml", "networks: [", "failed to parse networks file"},
+	} {
+		err := LoadFile(writeNetworksFile(t, test.content))
+		require.Error(t, err, test.name)
+		require.Contains(t, err.Error(), test.err, test.name)
+	}
+
+	// a file with an error adds none of its networks
+	err := LoadFile(writeNetworksFile(t, "networks:\n  - name: gnosis\n    fork_version: \"0x00000064\"\n  - name: mainnet\n    fork_version: \"0x00000064\"\n"))
+	require.Error(t, err)
+	_, err = FromName("gnosis")
+	require.Error(t, err)
+
+	mainnet, err := FromName(Mainnet)
+	require.NoError(t, err)
+	require.Equal(t, phase0.Version{0x00, 0x00, 0x00, 0x00}, mainnet.ForkVersion)
+
+	require.Error(t, LoadFile(filepath.Join(t.TempDir(), "missing.yaml")))
+}
+
+func TestNewCustom(t *testing.T) {
+	n, err := NewCustom("0x00000064", "00000001", "https://registry.example.com")
+	require.NoError(t, err)
+	require.Equal(t, Custom, n.Name)
+	require.Equal(t, phase0.Version{0x00, 0x00, 0x00, 0x64}, n.ForkVersion)
+	require.Equal(t, types.DomainType{0x00, 0x00, 0x00, 0x01}, n.DomainType)
+	require.Equal(t, "ETH", n.AmountUnit)
+
+	// tools that only deal with deposit data leave the domain type empty
+	n, err = NewCustom("00000064", "", "")
+	require.NoError(t, err)
+	require.Nil(t, n.DomainType)
+
+	for _, test := range [][2]string{{"", ""}, {"0x000064", ""}, {"0x0000006z", ""}, {"0x00000064", "0x0001"}, {"0x00000064", "domain"}} {
+		_, err := NewCustom(test[0], test[1], "")
+		require.Error(t, err, "fork version %q, domain type %q", test[0], test[1])
+	}
+}
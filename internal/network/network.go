@@ -0,0 +1,132 @@
+package network
+
+import (
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/bloxapp/ssv-spec/types"
+)
+
+const (
+	Mainnet = "mainnet"
+	Holesky = "holesky"
+	Hoodi   = "hoodi"
+	Custom  = "custom"
+)
+
+// DKGSignatureDomain is the domain every dkg message is signed with. The frost implementation
+// verifies round messages against this domain whatever the network, so it can't differ per network.
+var DKGSignatureDomain = types.PrimusTestnet
+
+// Network is the beacon chain and SSV network a ceremony is run for
+type Network struct {
+	Name string
+	// ForkVersion is the genesis fork version the deposit data is signed for
+	ForkVersion phase0.Version
+	// DomainType is the SSV domain of the network
+	DomainType types.DomainType
+	// RegistryURL is the base URL of the SSV operator registry api of the network
+	RegistryURL string
+	// DepositContract is the address of the deposit contract validators are funded through
+	DepositContract string
+	// AmountUnit is the token the 32e9 gwei of a deposit are counted in, e.g. ETH or mGNO
+	AmountUnit string
+}
+
+var profiles = map[string]*Network{
+	Mainnet: {
+		Name:            Mainnet,
+		ForkVersion:     phase0.Version{0x00, 0x00, 0x00, 0x00},
+		DomainType:      types.DomainType{0x00, 0x00, 0x00, 0x01},
+		RegistryURL:     "https://api.ssv.network/api/v4/mainnet",
+		DepositContract: "0x00000000219ab540356cBB839Cbe05303d7705Fa",
+		AmountUnit:      "ETH",
+	},
+	Holesky: {
+		Name:            Holesky,
+		ForkVersion:     phase0.Version{0x01, 0x01, 0x70, 0x00},
+		DomainType:      types.DomainType{0x00, 0x00, 0x05, 0x02},
+		RegistryURL:     "https://api.ssv.network/api/v4/holesky",
+		DepositContract: "0x4242424242424242424242424242424242424242",
+		AmountUnit:      "ETH",
+	},
+	Hoodi: {
+		Name:            Hoodi,
+		ForkVersion:     phase0.Version{0x10, 0x00, 0x09, 0x10},
+		DomainType:      types.DomainType{0x00, 0x00, 0x31, 0x13},
+		RegistryURL:     "https://api.ssv.network/api/v4/hoodi",
+		DepositContract: "0x00000000219ab540356cBB839Cbe05303d7705Fa",
+		AmountUnit:      "ETH",
+	},
+}
+
+// FromName returns the profile of a known network
+func FromName(name string) (*Network, error) {
+	n, ok := profiles[strings.ToLower(name)]
+	if !ok {
+		return nil, fmt.Errorf("unknown network %s: has to be one of %s or %s", name, strings.Join(Names(), ", "), Custom)
+	}
+	copied := *n
+	return &copied, nil
+}
+
+// NewCustom builds a network from a hex encoded fork version and SSV domain type. The domain
+// type may be left empty by tools that only deal with deposit data.
+func NewCustom(forkVersion, domainType, registryURL string) (*Network, error) {
+	fork, err := decodeFixedHex(forkVersion, 4)
+	if err != nil {
+		return nil, fmt.Errorf("invalid fork version %s: %v", forkVersion, err)
+	}
+
+	n := &Network{
+		Name:        Custom,
+		RegistryURL: registryURL,
+		AmountUnit:  "ETH",
+	}
+	copy(n.ForkVersion[:], fork)
+
+	if domainType != "" {
+		domain, err := decodeFixedHex(domainType, 4)
+		if err != nil {
+			return nil, fmt.Errorf("invalid domain type %s: %v", domainType, err)
+		}
+		n.DomainType = types.DomainType(domain)
+	}
+	return n, nil
+}
+
+// Names returns the names of the known networks
+func Names() []string {
+	names := make([]string, 0, len(profiles))
+	for name := range profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// CheckForkVersion returns an error if a ceremony for the given fork version doesn't belong to this network
+func (n *Network) CheckForkVersion(fork phase0.Version) error {
+	if fork != n.ForkVersion {
+		return fmt.Errorf("fork version %s doesn't match network %s (%s)", hex.EncodeToString(fork[:]), n.Name, hex.EncodeToString(n.ForkVersion[:]))
+	}
+	return nil
+}
+
+func (n *Network) String() string {
+	return fmt.Sprintf("%s (fork version %s, domain %s)", n.Name, hex.EncodeToString(n.ForkVersion[:]), hex.EncodeToString(n.DomainType))
+}
+
+func decodeFixedHex(s string, size int) ([]byte, error) {
+	b, err := hex.DecodeString(strings.TrimPrefix(s, "0x"))
+	if err != nil {
+		return nil, err
+	}
+	if len(b) != size {
+		return nil, fmt.Errorf("expected %d bytes, got %d", size, len(b))
+	}
+	return b, nil
+}
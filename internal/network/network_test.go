@@ -0,0 +1,70 @@
+package network
+
+import (
+	"testing"
+
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/bloxapp/ssv-spec/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFromName(t *testing.T) {
+	for _, test := range []struct {
+		name   string
+		fork   phase0.Version
+		domain types.DomainType
+	}{
+		{"mainnet", phase0.Version{0x00, 0x00, 0x00, 0x00}, types.DomainType{0x00, 0x00, 0x00, 0x01}},
+		{"holesky", phase0.Version{0x01, 0x01, 0x70, 0x00}, types.DomainType{0x00, 0x00, 0x05, 0x02}},
+		{"hoodi", phase0.Version{0x10, 0x00, 0x09, 0x10}, types.DomainType{0x00, 0x00, 0x31, 0x13}},
+		{"Hoodi", phase0.Version{0x10, 0x00, 0x09, 0x10}, types.DomainType{0x00, 0x00, 0x31, 0x13}},
+	} {
+		n, err := FromName(test.name)
+		require.NoError(t, err, test.name)
+		require.Equal(t, test.fork, n.ForkVersion, test.name)
+		require.Equal(t, test.domain, n.DomainType, test.name)
+		require.NotEmpty(t, n.RegistryURL, test.name)
+		require.NotEmpty(t, n.DepositContract, test.name)
+	}
+
+	// the profiles are copies, changing one doesn't change the network
+	n, err := FromName(Mainnet)
+	require.NoError(t, err)
+	n.RegistryURL = "http://localhost"
+	n, err = FromName(Mainnet)
+	require.NoError(t, err)
+	require.Equal(t, "https://api.ssv.network/api/v4/mainnet", n.RegistryURL)
+}
+
+func TestFromNameUnknown(t *testing.T) {
+	for _, name := range []string{"", "prater", "goerli", "custom", "mainnet "} {
+		_, err := FromName(name)
+		require.Error(t, err, name)
+		require.Contains(t, err.Error(), "unknown network", name)
+	}
+}
+
+func TestCheckForkVersion(t *testing.T) {
+	for _, name := range Names() {
+		n, err := FromName(name)
+		require.NoError(t, err)
+		require.NoError(t, n.CheckForkVersion(n.ForkVersion), name)
+
+		// the fork versions of every other network are refused
+		for _, otherName := range Names() {
+			if otherName == name {
+				continue
+			}
+			other, err := FromName(otherName)
+			require.NoError(t, err)
+			require.Error(t, n.CheckForkVersion(other.ForkVersion), "%s accepts the fork version of %s", name, otherName)
+		}
+	}
+
+	custom, err := NewCustom("0x12345678", "", "")
+	require.NoError(t, err)
+	hoodi, err := FromName(Hoodi)
+	require.NoError(t, err)
+	require.Error(t, hoodi.CheckForkVersion(custom.ForkVersion))
+	require.Error(t, custom.CheckForkVersion(hoodi.ForkVersion))
+}
@@ -0,0 +1,130 @@
+package node
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+
+	store "github.com/RockX-SG/frost-dkg-demo/internal/storage"
+	"github.com/bloxapp/ssv-spec/types"
+	"github.com/gin-gonic/gin"
+)
+
+// ListenAdmin listens on the unix socket the admin and backup endpoints are served on, so that
+// they're only reachable by users of the node host allowed to open the socket, and never through
+// a proxy in front of the node api. Only the node user can connect to the socket. A socket left
+// by a node that didn't stop cleanly is replaced.
+func ListenAdmin(path string) (net.Listener, error) {
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to remove the stale admin socket: %v", err)
+	}
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(path, 0600); err != nil {
+		listener.Close()
+		return nil, err
+	}
+	return listener, nil
+}
+
+// HandleInvalidateOperator marks an operator of the operator cache stale, so it's fetched from
+// the registry on next use
+func (h *ApiHandler) HandleInvalidateOperator(storage *store.Storage) func(*gin.Context) {
+	return func(c *gin.Context) {
+		operatorID, ok := operatorIDParam(c)
+		if !ok {
+			return
+		}
+		if err := storage.InvalidateOperator(operatorID); err != nil {
+			h.logger.Errorf("HandleInvalidateOperator: failed to invalidate operator %d: %v", operatorID, err)
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"message": "failed to invalidate operator",
+				"error":   err.Error(),
+			})
+			return
+		}
+		h.logger.Infof("HandleInvalidateOperator: invalidated cached operator %d", operatorID)
+		c.JSON(http.StatusOK, gin.H{
+			"message": "invalidated operator",
+			"error":   nil,
+		})
+	}
+}
+
+// HandleInvalidateOperators marks every operator of the operator cache stale
+func (h *ApiHandler) HandleInvalidateOperators(storage *store.Storage) func(*gin.Context) {
+	return func(c *gin.Context) {
+		invalidated, err := storage.InvalidateOperators()
+		if err != nil {
+			h.logger.Errorf("HandleInvalidateOperators: failed to invalidate operators: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"message": "failed to invalidate operators",
+				"error":   err.Error(),
+			})
+			return
+		}
+		h.logger.Infof("HandleInvalidateOperators: invalidated %d cached operators", invalidated)
+		c.JSON(http.StatusOK, gin.H{
+			"message":     "invalidated operators",
+			"invalidated": invalidated,
+			"error":       nil,
+		})
+	}
+}
+
+// HandleRefreshOperator fetches an operator from the registry into the operator cache. A
+// registry RSA key that differs from the pinned one only replaces it with accept_key_change=true.
+func (h *ApiHandler) HandleRefreshOperator(storage *store.Storage) func(*gin.Context) {
+	return func(c *gin.Context) {
+		operatorID, ok := operatorIDParam(c)
+		if !ok {
+			return
+		}
+		acceptKeyChange := c.Query("accept_key_change") == "true"
+		operator, keyChanged, err := storage.RefreshOperator(operatorID, acceptKeyChange)
+		if errors.Is(err, store.ErrOperatorKeyChanged) {
+			h.logger.Warnf("HandleRefreshOperator: the registry returned another RSA key for operator %d than the pinned one", operatorID)
+			c.JSON(http.StatusConflict, gin.H{
+				"message": "the registry returned another RSA key than the pinned one, refresh with accept_key_change=true to accept it",
+				"error":   err.Error(),
+			})
+			return
+		}
+		if err != nil {
+			h.logger.Errorf("HandleRefreshOperator: failed to refresh operator %d: %v", operatorID, err)
+			c.JSON(http.StatusBadGateway, gin.H{
+				"message": "failed to fetch operator from the registry",
+				"error":   err.Error(),
+			})
+			return
+		}
+		if keyChanged {
+			h.logger.Warnf("HandleRefreshOperator: accepted the registry RSA key of operator %d", operatorID)
+		} else {
+			h.logger.Infof("HandleRefreshOperator: refreshed cached operator %d", operatorID)
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"message":     "refreshed operator",
+			"owner":       operator.ETHAddress.Hex(),
+			"key_changed": keyChanged,
+			"error":       nil,
+		})
+	}
+}
+
+func operatorIDParam(c *gin.Context) (types.OperatorID, bool) {
+	operatorID, err := strconv.ParseUint(c.Param("operator_id"), 10, 64)
+	if err != nil || operatorID == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "invalid operator ID",
+			"error":   c.Param("operator_id"),
+		})
+		return 0, false
+	}
+	return types.OperatorID(operatorID), true
+}
@@ -0,0 +1,182 @@
+package node
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/RockX-SG/frost-dkg-demo/internal/logger"
+	"github.com/RockX-SG/frost-dkg-demo/internal/messenger"
+	store "github.com/RockX-SG/frost-dkg-demo/internal/storage"
+	"github.com/bloxapp/ssv-spec/dkg"
+	"github.com/bloxapp/ssv-spec/dkg/frost"
+	"github.com/bloxapp/ssv-spec/types"
+	"github.com/gin-gonic/gin"
+)
+
+// AuditLog wraps a dkg.Network and appends every ceremony message the node sends to the audit
+// log of the storage. Received messages are appended by the api handler through Process.
+type AuditLog struct {
+	wrappedNetwork
+
+	storage *store.Storage
+	logger  *logger.Logger
+
+	mu sync.Mutex
+	// processing holds the entries of the messages sent for a ceremony while a received message
+	// of the ceremony is processed, to append them after the received one
+	processing map[dkg.RequestID][]*store.AuditEntry
+}
+
+func NewAuditLog(network dkg.Network, storage *store.Storage, logger *logger.Logger) *AuditLog {
+	return &AuditLog{
+		wrappedNetwork: wrappedNetwork{network},
+		storage:        storage,
+		logger:         logger,
+		processing:     make(map[dkg.RequestID][]*store.AuditEntry),
+	}
+}
+
+func (h *ApiHandler) WithAuditLog(audit *AuditLog) {
+	h.audit = audit
+}
+
+// Process processes a received message with fn and appends it once fn accepted it, before the
+// messages the node sent in response. Messages that fail processing, whose signature may not
+// even have been checked, aren't appended, so unauthenticated messages can't fill the log.
+// Messages of a ceremony must not be processed concurrently, which the dispatcher ensures.
+func (a *AuditLog) Process(msg *types.SSVMessage, fn func() error) error {
+	signedMsg := &dkg.SignedMessage{}
+	if err := signedMsg.Decode(msg.Data); err != nil {
+		return fn()
+	}
+	requestID := signedMsg.Message.Identifier
+	a.mu.Lock()
+	a.processing[requestID] = make([]*store.AuditEntry, 0)
+	a.mu.Unlock()
+
+	err := fn()
+
+	a.mu.Lock()
+	sent := a.processing[requestID]
+	delete(a.processing, requestID)
+	a.mu.Unlock()
+	if err == nil {
+		a.append(newAuditEntry(store.AuditReceived, signedMsg, msg.Data, nil))
+	}
+	for _, entry := range sent {
+		a.append(entry)
+	}
+	return err
+}
+
+func (a *AuditLog) BroadcastDKGMessage(msg *dkg.SignedMessage) error {
+	err := a.Network.BroadcastDKGMessage(msg)
+	encoded, encodeErr := msg.Encode()
+	if encodeErr != nil {
+		a.logger.Errorf("AuditLog: failed to encode sent message: %v", encodeErr)
+		return err
+	}
+	entry := newAuditEntry(store.AuditSent, msg, encoded, err)
+
+	a.mu.Lock()
+	sent, processing := a.processing[msg.Message.Identifier]
+	if processing {
+		a.processing[msg.Message.Identifier] = append(sent, entry)
+	}
+	a.mu.Unlock()
+	if !processing {
+		a.append(entry)
+	}
+	return err
+}
+
+func newAuditEntry(direction string, msg *dkg.SignedMessage, encoded []byte, sendErr error) *store.AuditEntry {
+	round := frost.Uninitialized
+	if msg.Message.MsgType == dkg.ProtocolMsgType {
+		protocolMsg := &frost.ProtocolMsg{}
+		if err := protocolMsg.Decode(msg.Message.Data); err == nil {
+			round = protocolMsg.Round
+		}
+	}
+	msgHash := sha256.Sum256(encoded)
+	entry := &store.AuditEntry{
+		Timestamp: time.Now(),
+		Direction: direction,
+		RequestID: hex.EncodeToString(msg.Message.Identifier[:]),
+		Signer:    msg.Signer,
+		MsgType:   messenger.MsgTypeName(msg.Message.MsgType),
+		Round:     messenger.RoundName(msg.Message.MsgType, round),
+		MsgHash:   hex.EncodeToString(msgHash[:]),
+	}
+	if sendErr != nil {
+		entry.Error = sendErr.Error()
+	}
+	return entry
+}
+
+func (a *AuditLog) append(entry *store.AuditEntry) {
+	if err := a.storage.AppendAudit(entry); err != nil {
+		a.logger.Errorf("AuditLog: failed to append %s %s message of ceremony %s: %v", entry.Direction, entry.MsgType, entry.RequestID, err)
+	}
+}
+
+// auditExport is the audit log of a ceremony, with the result of verifying its entries against
+// their neighbours in the chain
+type auditExport struct {
+	RequestID string              `json:"request_id"`
+	Entries   []*store.AuditEntry `json:"entries"`
+	Chain     auditChain          `json:"chain"`
+}
+
+type auditChain struct {
+	Verified bool             `json:"verified"`
+	Head     *store.AuditHead `json:"head,omitempty"`
+	Error    string           `json:"error,omitempty"`
+}
+
+// HandleExportAudit exports the audit entries of a ceremony as JSON, after verifying that they
+// chain to the entries around them
+func (h *ApiHandler) HandleExportAudit(storage *store.Storage) func(*gin.Context) {
+	return func(c *gin.Context) {
+		requestID, err := store.ParseRequestID(c.Param("request_id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"message": "invalid request ID",
+				"error":   err.Error(),
+			})
+			return
+		}
+
+		entries, err := storage.AuditLog(requestID)
+		if err != nil {
+			h.logger.Errorf("HandleExportAudit: failed to read the audit log of ceremony %s: %v", c.Param("request_id"), err)
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"message": "failed to read the audit log",
+				"error":   err.Error(),
+			})
+			return
+		}
+		export := &auditExport{RequestID: hex.EncodeToString(requestID[:]), Entries: entries}
+
+		head, err := storage.VerifyAuditEntries(entries)
+		switch {
+		case err == nil:
+			export.Chain = auditChain{Verified: true, Head: head}
+		case errors.Is(err, store.ErrAuditChainBroken):
+			h.logger.Errorf("HandleExportAudit: %v", err)
+			export.Chain = auditChain{Error: err.Error()}
+		default:
+			h.logger.Errorf("HandleExportAudit: failed to verify the audit log: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"message": "failed to verify the audit log",
+				"error":   err.Error(),
+			})
+			return
+		}
+		c.JSON(http.StatusOK, export)
+	}
+}
@@ -0,0 +1,54 @@
+package node
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/RockX-SG/frost-dkg-demo/internal/logger"
+	store "github.com/RockX-SG/frost-dkg-demo/internal/storage"
+	"github.com/bloxapp/ssv-spec/dkg"
+	"github.com/bloxapp/ssv-spec/types"
+	"github.com/bloxapp/ssv-spec/types/testingutils"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuditLogProcess(t *testing.T) {
+	db := store.NewMemoryBackend()
+	defer db.Close()
+	storage := store.NewStorage(db)
+	audit := NewAuditLog(testingutils.NewTestingNetwork(), storage, &logger.Logger{Logger: logrus.New()})
+
+	received := func(requestID dkg.RequestID) *types.SSVMessage {
+		data, err := testMessage(requestID, dkg.InitMsgType).Encode()
+		require.NoError(t, err)
+		return &types.SSVMessage{MsgType: types.DKGMsgType, Data: data}
+	}
+	respond := func(requestID dkg.RequestID, err error) func() error {
+		return func() error {
+			require.NoError(t, audit.BroadcastDKGMessage(testMessage(requestID, dkg.ProtocolMsgType)))
+			return err
+		}
+	}
+
+	// an accepted message is appended before the message sent in response to it
+	accepted := testRequestID(1)
+	require.NoError(t, audit.Process(received(accepted), respond(accepted, nil)))
+	entries, err := storage.AuditLog(accepted)
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	require.Equal(t, store.AuditReceived, entries[0].Direction)
+	require.Equal(t, store.AuditSent, entries[1].Direction)
+
+	// a message that fails processing isn't appended
+	failed := testRequestID(2)
+	require.Error(t, audit.Process(received(failed), respond(failed, errors.New("signed message invalid"))))
+	entries, err = storage.AuditLog(failed)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	require.Equal(t, store.AuditSent, entries[0].Direction)
+
+	head, err := storage.VerifyAuditEntries(entries)
+	require.NoError(t, err)
+	require.Equal(t, uint64(3), head.Seq)
+}
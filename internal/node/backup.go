@@ -0,0 +1,64 @@
+package node
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+
+	"github.com/RockX-SG/frost-dkg-demo/internal/backup"
+	"github.com/RockX-SG/frost-dkg-demo/internal/secrets"
+	store "github.com/RockX-SG/frost-dkg-demo/internal/storage"
+	"github.com/gin-gonic/gin"
+)
+
+// maxBackupPassword bounds the size of the backup password posted to HandleBackup
+const maxBackupPassword = 4096
+
+// HandleBackup serves a backup of the database, encrypted with the backup password posted as
+// the request body, for the backup command to take while the node is running. Serve it on the
+// admin listener only.
+func (h *ApiHandler) HandleBackup(storage *store.Storage) func(*gin.Context) {
+	return func(c *gin.Context) {
+		password, err := io.ReadAll(io.LimitReader(c.Request.Body, maxBackupPassword))
+		defer secrets.Zero(password)
+		if err != nil || len(password) == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"message": "the backup password is required as the request body",
+				"error":   nil,
+			})
+			return
+		}
+
+		data := &bytes.Buffer{}
+		counts, err := storage.Backup(data)
+		if err != nil {
+			h.logger.Errorf("HandleBackup: failed to back up the database: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"message": "failed to back up the database",
+				"error":   err.Error(),
+			})
+			return
+		}
+		schemaVersion, err := storage.SchemaVersion()
+		if err != nil {
+			h.logger.Errorf("HandleBackup: failed to read the schema version: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"message": "failed to back up the database",
+				"error":   err.Error(),
+			})
+			return
+		}
+
+		// buffered so a failure isn't sent as a truncated backup
+		encrypted := &bytes.Buffer{}
+		if err := backup.Write(encrypted, backup.NewManifest(data.Bytes(), schemaVersion, counts), data.Bytes(), password); err != nil {
+			h.logger.Errorf("HandleBackup: failed to encrypt the backup: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"message": "failed to encrypt the backup",
+				"error":   err.Error(),
+			})
+			return
+		}
+		c.Data(http.StatusOK, "application/octet-stream", encrypted.Bytes())
+	}
+}
@@ -0,0 +1,86 @@
+package node
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/RockX-SG/frost-dkg-demo/internal/backup"
+	"github.com/RockX-SG/frost-dkg-demo/internal/logger"
+	dkgnetwork "github.com/RockX-SG/frost-dkg-demo/internal/network"
+	store "github.com/RockX-SG/frost-dkg-demo/internal/storage"
+	"github.com/bloxapp/ssv-spec/dkg"
+	"github.com/bloxapp/ssv-spec/types"
+	"github.com/bloxapp/ssv-spec/types/testingutils"
+	"github.com/gin-gonic/gin"
+	"github.com/herumi/bls-eth-go-binary/bls"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandleBackupOnAdminSocket(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	ks := testingutils.Testing4SharesSet()
+	db := store.NewMemoryBackend()
+	defer db.Close()
+	storage := store.NewStorage(db)
+	_, err := storage.Migrate(false)
+	require.NoError(t, err)
+	_, err = storage.EnableShareEncryption(store.PasswordKEK([]byte("storage password")))
+	require.NoError(t, err)
+	require.NoError(t, storage.SaveKeyGenOutput(&dkg.KeyGenOutput{
+		Share:           ks.Shares[1],
+		ValidatorPK:     ks.ValidatorPK.Serialize(),
+		OperatorPubKeys: map[types.OperatorID]*bls.PublicKey{1: ks.Shares[1].GetPublicKey()},
+		Threshold:       3,
+	}))
+
+	h := New(&logger.Logger{Logger: logrus.New()}, &dkgnetwork.Network{DomainType: types.PrimusTestnet})
+	r := gin.New()
+	r.POST("/backup", h.HandleBackup(storage))
+
+	// a stale socket is replaced, and only the node user can connect to the new one
+	socket := filepath.Join(t.TempDir(), "admin.sock")
+	require.NoError(t, os.WriteFile(socket, nil, 0600))
+	listener, err := ListenAdmin(socket)
+	require.NoError(t, err)
+	defer listener.Close()
+	go http.Serve(listener, r)
+	info, err := os.Stat(socket)
+	require.NoError(t, err)
+	require.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	client := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, "unix", socket)
+		},
+	}}
+	post := func(password string) (int, []byte) {
+		resp, err := client.Post("http://node/backup", "application/octet-stream", bytes.NewReader([]byte(password)))
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		return resp.StatusCode, body
+	}
+
+	code, _ := post("")
+	require.Equal(t, http.StatusBadRequest, code)
+
+	// the backup is served encrypted with the posted password
+	code, file := post("backup password")
+	require.Equal(t, http.StatusOK, code)
+	_, _, err = backup.Read(file, []byte("another password"))
+	require.Error(t, err)
+	manifest, _, err := backup.Read(file, []byte("backup password"))
+	require.NoError(t, err)
+	stats, err := storage.Stats()
+	require.NoError(t, err)
+	require.Equal(t, *stats, manifest.Counts)
+	require.Equal(t, 1, manifest.Counts.KeyGenOutputs)
+}
@@ -0,0 +1,110 @@
+package node
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	store "github.com/RockX-SG/frost-dkg-demo/internal/storage"
+	"github.com/gin-gonic/gin"
+)
+
+func (h *ApiHandler) WithCeremonyRecorder(recorder *CeremonyRecorder) {
+	h.ceremonies = recorder
+}
+
+// HandleGetCeremony returns the record of the ceremony with the request ID
+func (h *ApiHandler) HandleGetCeremony(storage *store.Storage) func(*gin.Context) {
+	return func(c *gin.Context) {
+		requestID, err := store.ParseRequestID(c.Param("request_id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"message": "invalid request ID",
+				"error":   err.Error(),
+			})
+			return
+		}
+
+		record, err := storage.GetCeremony(requestID)
+		if errors.Is(err, store.ErrCeremonyNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{
+				"message": "ceremony not found",
+				"error":   err.Error(),
+			})
+			return
+		}
+		if err != nil {
+			h.logger.Errorf("HandleGetCeremony: failed to get ceremony %s: %v", c.Param("request_id"), err)
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"message": "failed to get ceremony",
+				"error":   err.Error(),
+			})
+			return
+		}
+		c.JSON(http.StatusOK, record)
+	}
+}
+
+// HandleListCeremonies pages through the ceremonies the node took part in, ordered by request
+// ID. Query parameters:
+//
+//	limit    page size, up to 1000
+//	after    the next value of the previous page
+//	type     keygen or reshare
+//	status   running, success, blame or timeout
+func (h *ApiHandler) HandleListCeremonies(storage *store.Storage) func(*gin.Context) {
+	return func(c *gin.Context) {
+		filter, err := parseCeremonyFilter(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"message": "invalid query",
+				"error":   err.Error(),
+			})
+			return
+		}
+
+		page, err := storage.ListCeremonies(*filter)
+		if err != nil {
+			h.logger.Errorf("HandleListCeremonies: failed to list ceremonies: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"message": "failed to list ceremonies",
+				"error":   err.Error(),
+			})
+			return
+		}
+		c.JSON(http.StatusOK, page)
+	}
+}
+
+func parseCeremonyFilter(c *gin.Context) (*store.CeremonyFilter, error) {
+	filter := &store.CeremonyFilter{
+		Type:   c.Query("type"),
+		Status: c.Query("status"),
+	}
+	switch filter.Type {
+	case "", store.CeremonyKeygen, store.CeremonyReshare:
+	default:
+		return nil, fmt.Errorf("invalid type %s", filter.Type)
+	}
+	switch filter.Status {
+	case "", store.CeremonyRunning, store.CeremonySucceeded, store.CeremonyBlamed, store.CeremonyTimedOut:
+	default:
+		return nil, fmt.Errorf("invalid status %s", filter.Status)
+	}
+
+	if limit := c.Query("limit"); limit != "" {
+		var err error
+		if filter.Limit, err = strconv.Atoi(limit); err != nil || filter.Limit <= 0 {
+			return nil, fmt.Errorf("invalid limit %s", limit)
+		}
+	}
+	if after := c.Query("after"); after != "" {
+		requestID, err := store.ParseRequestID(after)
+		if err != nil {
+			return nil, err
+		}
+		filter.After = &requestID
+	}
+	return filter, nil
+}
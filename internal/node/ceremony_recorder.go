@@ -0,0 +1,152 @@
+package node
+
+import (
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/RockX-SG/frost-dkg-demo/internal/logger"
+	"github.com/RockX-SG/frost-dkg-demo/internal/messenger"
+	store "github.com/RockX-SG/frost-dkg-demo/internal/storage"
+	"github.com/bloxapp/ssv-spec/dkg"
+	"github.com/bloxapp/ssv-spec/dkg/frost"
+	"github.com/bloxapp/ssv-spec/types"
+)
+
+// CeremonyTimeout is how long a ceremony can run before its record is finished as timed out
+const CeremonyTimeout = time.Hour
+
+// CeremonyRecorder wraps a dkg.Network and keeps a record of every ceremony the node takes part
+// in. A record is started by the init or reshare message the node accepted, and finished by the
+// output, blame or timeout the node streams, or by timing out.
+type CeremonyRecorder struct {
+	wrappedNetwork
+
+	storage *store.Storage
+	logger  *logger.Logger
+}
+
+func NewCeremonyRecorder(network dkg.Network, storage *store.Storage, logger *logger.Logger) *CeremonyRecorder {
+	return &CeremonyRecorder{
+		wrappedNetwork: wrappedNetwork{network},
+		storage:        storage,
+		logger:         logger,
+	}
+}
+
+// Start records the ceremony started by an init or reshare message the node processed
+func (r *CeremonyRecorder) Start(msg *types.SSVMessage) {
+	signedMsg := &dkg.SignedMessage{}
+	if err := signedMsg.Decode(msg.Data); err != nil {
+		return
+	}
+	requestID := signedMsg.Message.Identifier
+	record := &store.CeremonyRecord{
+		RequestID: hex.EncodeToString(requestID[:]),
+		Initiator: requestID.GetETHAddress().Hex(),
+		Status:    store.CeremonyRunning,
+		StartedAt: time.Now().UTC(),
+	}
+
+	switch signedMsg.Message.MsgType {
+	case dkg.InitMsgType:
+		init := &dkg.Init{}
+		if err := init.Decode(signedMsg.Message.Data); err != nil {
+			return
+		}
+		record.Type = store.CeremonyKeygen
+		record.Operators = init.OperatorIDs
+		record.Threshold = init.Threshold
+		record.WithdrawalCredentials = hex.EncodeToString(init.WithdrawalCredentials)
+		record.ForkVersion = hex.EncodeToString(init.Fork[:])
+	case dkg.ReshareMsgType:
+		reshare := &dkg.Reshare{}
+		if err := reshare.Decode(signedMsg.Message.Data); err != nil {
+			return
+		}
+		record.Type = store.CeremonyReshare
+		record.Operators = reshare.OperatorIDs
+		record.OldOperators = reshare.OldOperatorIDs
+		record.Threshold = reshare.Threshold
+		record.ValidatorPK = hex.EncodeToString(reshare.ValidatorPK)
+	default:
+		return
+	}
+
+	if err := r.storage.SaveCeremony(record); err != nil && err != store.ErrCeremonyExists {
+		r.logger.Errorf("CeremonyRecorder: failed to record ceremony %s: %v", record.RequestID, err)
+	}
+}
+
+// classifyMissingRunner makes the error of a message of a ceremony the node has no runner for
+// permanent when the node has a record of the ceremony: the ceremony ended, or was lost when
+// the node restarted, and won't run again. A failure reading the record is retryable.
+func (r *CeremonyRecorder) classifyMissingRunner(requestID dkg.RequestID, err error) error {
+	record, getErr := r.storage.GetCeremony(requestID)
+	if getErr != nil {
+		if getErr != store.ErrCeremonyNotFound {
+			r.logger.Errorf("CeremonyRecorder: failed to read the record of ceremony %x: %v", requestID[:], getErr)
+		}
+		return err
+	}
+	return &ErrPermanent{Err: fmt.Errorf("%v: ceremony %s is %s", err, record.RequestID, record.Status)}
+}
+
+func (r *CeremonyRecorder) StreamDKGOutput(output map[types.OperatorID]*dkg.SignedOutput) error {
+	for _, o := range output {
+		validatorPK := hex.EncodeToString(o.Data.ValidatorPubKey)
+		r.finish(o.Data.RequestID, store.CeremonyOutcome{Status: store.CeremonySucceeded, ValidatorPK: validatorPK})
+		break
+	}
+	return r.Network.StreamDKGOutput(output)
+}
+
+func (r *CeremonyRecorder) StreamDKGBlame(blame *dkg.BlameOutput) error {
+	if blame.BlameMessage != nil {
+		ceremonyBlame := &store.CeremonyBlame{Valid: blame.Valid}
+		protocolMsg := &frost.ProtocolMsg{}
+		if err := protocolMsg.Decode(blame.BlameMessage.Message.Data); err == nil && protocolMsg.BlameMessage != nil {
+			ceremonyBlame.Type = protocolMsg.BlameMessage.Type.ToString()
+			ceremonyBlame.Operator = types.OperatorID(protocolMsg.BlameMessage.TargetOperatorID)
+		}
+		r.finish(blame.BlameMessage.Message.Identifier, store.CeremonyOutcome{Status: store.CeremonyBlamed, Blame: ceremonyBlame})
+	}
+	return r.Network.StreamDKGBlame(blame)
+}
+
+// StreamDKGTimeout finishes the record of the ceremony as timed out in the round it waited on
+func (r *CeremonyRecorder) StreamDKGTimeout(timeout *messenger.TimeoutOutput) error {
+	r.logger.Warnf("CeremonyRecorder: ceremony %x timed out on its %s deadline in round %s, missing the messages of operators %v",
+		timeout.RequestID[:], timeout.Deadline, timeout.Round, timeout.Missing)
+	r.finish(timeout.RequestID, store.CeremonyOutcome{
+		Status: store.CeremonyTimedOut,
+		Timeout: &store.CeremonyTimeout{
+			Round:    timeout.Round,
+			Missing:  timeout.Missing,
+			Deadline: timeout.Deadline,
+		},
+	})
+	return r.wrappedNetwork.StreamDKGTimeout(timeout)
+}
+
+func (r *CeremonyRecorder) finish(requestID dkg.RequestID, outcome store.CeremonyOutcome) {
+	if _, err := r.storage.FinishCeremony(requestID, outcome, time.Now()); err != nil {
+		r.logger.Errorf("CeremonyRecorder: failed to finish ceremony %x: %v", requestID[:], err)
+	}
+}
+
+// RunTimeouts finishes the ceremonies running for longer than CeremonyTimeout as timed out,
+// checking every interval
+func (r *CeremonyRecorder) RunTimeouts(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		timedOut, err := r.storage.TimeoutCeremonies(time.Now().Add(-CeremonyTimeout))
+		if err != nil {
+			r.logger.Errorf("CeremonyRecorder: failed to time out ceremonies: %v", err)
+		} else if timedOut > 0 {
+			r.logger.Warnf("CeremonyRecorder: %d ceremonies timed out", timedOut)
+		}
+	}
+}
@@ -0,0 +1,35 @@
+package node
+
+import (
+	"testing"
+
+	"github.com/RockX-SG/frost-dkg-demo/internal/logger"
+	"github.com/RockX-SG/frost-dkg-demo/internal/messenger"
+	store "github.com/RockX-SG/frost-dkg-demo/internal/storage"
+	"github.com/bloxapp/ssv-spec/types"
+	"github.com/bloxapp/ssv-spec/types/testingutils"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCeremonyRecorderReplayedInit(t *testing.T) {
+	ks := testingutils.Testing4SharesSet()
+	db := store.NewMemoryBackend()
+	defer db.Close()
+	storage := store.NewStorage(db)
+	recorder := NewCeremonyRecorder(&timeoutNetwork{TestingNetwork: testingutils.NewTestingNetwork()}, storage, &logger.Logger{Logger: logrus.New()})
+
+	requestID := testRequestID(1)
+	init := &types.SSVMessage{}
+	require.NoError(t, init.Decode(encodeTestMessage(t, testInit(ks, requestID))))
+	recorder.Start(init)
+	require.NoError(t, recorder.StreamDKGTimeout(&messenger.TimeoutOutput{RequestID: requestID, Round: "round1", Deadline: "round"}))
+
+	// the init processed again doesn't start the ceremony over
+	recorder.Start(init)
+	record, err := storage.GetCeremony(requestID)
+	require.NoError(t, err)
+	require.Equal(t, store.CeremonyTimedOut, record.Status)
+	require.Equal(t, "round1", record.Timeout.Round)
+	require.NotNil(t, record.FinishedAt)
+}
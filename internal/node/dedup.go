@@ -0,0 +1,100 @@
+package node
+
+import (
+	"crypto/sha256"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/RockX-SG/frost-dkg-demo/internal/policy"
+	"github.com/bloxapp/ssv-spec/dkg"
+)
+
+const (
+	// DedupWindow is how long the outcome of a message is remembered after the last message
+	// of its ceremony
+	DedupWindow = CeremonyTimeout
+	// maxDedupMessages bounds the outcomes remembered per ceremony
+	maxDedupMessages = 1024
+)
+
+// processedMessages remembers the outcome of the messages the node processed by ceremony and
+// message hash, so that a message sent again, because the response to it got lost, gets the
+// same answer without being processed again. Outcomes are successes and permanent errors, a
+// message that failed with a retryable error is processed again.
+type processedMessages struct {
+	window time.Duration
+
+	mu         sync.Mutex
+	ceremonies map[dkg.RequestID]*processedCeremony
+}
+
+type processedCeremony struct {
+	lastSeen time.Time
+	outcomes map[[sha256.Size]byte]error
+}
+
+func newProcessedMessages(window time.Duration) *processedMessages {
+	return &processedMessages{
+		window:     window,
+		ceremonies: make(map[dkg.RequestID]*processedCeremony),
+	}
+}
+
+// lookup returns true and the outcome of the message if it was processed already
+func (p *processedMessages) lookup(requestID dkg.RequestID, data []byte) (bool, error) {
+	key := sha256.Sum256(data)
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	ceremony, ok := p.ceremonies[requestID]
+	if !ok {
+		return false, nil
+	}
+	outcome, ok := ceremony.outcomes[key]
+	return ok, outcome
+}
+
+// process returns the outcome of the message if it was processed already, and otherwise
+// processes it with fn. Messages of a ceremony must not be processed concurrently, which the
+// dispatcher ensures.
+func (p *processedMessages) process(requestID dkg.RequestID, data []byte, fn func() error) (bool, error) {
+	if duplicate, outcome := p.lookup(requestID, data); duplicate {
+		return true, outcome
+	}
+
+	err := fn()
+	if err == nil || isPermanent(err) {
+		p.record(requestID, sha256.Sum256(data), err)
+	}
+	return false, err
+}
+
+func (p *processedMessages) record(requestID dkg.RequestID, key [sha256.Size]byte, outcome error) {
+	now := time.Now()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for id, ceremony := range p.ceremonies {
+		if now.Sub(ceremony.lastSeen) > p.window {
+			delete(p.ceremonies, id)
+		}
+	}
+	ceremony, ok := p.ceremonies[requestID]
+	if !ok {
+		ceremony = &processedCeremony{outcomes: make(map[[sha256.Size]byte]error)}
+		p.ceremonies[requestID] = ceremony
+	}
+	ceremony.lastSeen = now
+	if len(ceremony.outcomes) < maxDedupMessages {
+		ceremony.outcomes[key] = outcome
+	}
+}
+
+// isPermanent returns true for errors that processing the message again won't fix
+func isPermanent(err error) bool {
+	var errInvalid *ErrInvalidMessage
+	var errPermanent *ErrPermanent
+	var rejection *policy.Rejection
+	return errors.As(err, &errInvalid) || errors.As(err, &errPermanent) || errors.As(err, &rejection)
+}
@@ -0,0 +1,215 @@
+package node
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/RockX-SG/frost-dkg-demo/internal/logger"
+	"github.com/RockX-SG/frost-dkg-demo/internal/messenger"
+	dkgnetwork "github.com/RockX-SG/frost-dkg-demo/internal/network"
+	"github.com/RockX-SG/frost-dkg-demo/internal/policy"
+	store "github.com/RockX-SG/frost-dkg-demo/internal/storage"
+	"github.com/bloxapp/ssv-spec/dkg"
+	"github.com/bloxapp/ssv-spec/types"
+	"github.com/bloxapp/ssv-spec/types/testingutils"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProcessedMessages(t *testing.T) {
+	p := newProcessedMessages(time.Hour)
+	calls := 0
+	process := func(id int, data string, err error) (bool, error) {
+		return p.process(testRequestID(id), []byte(data), func() error {
+			calls++
+			return err
+		})
+	}
+
+	// successes and permanent errors are remembered
+	duplicate, err := process(1, "a", nil)
+	require.False(t, duplicate)
+	require.NoError(t, err)
+	duplicate, err = process(1, "a", errors.New("not called"))
+	require.True(t, duplicate)
+	require.NoError(t, err)
+
+	refused := &ErrPermanent{Err: errors.New("refused")}
+	_, err = process(1, "b", refused)
+	require.Equal(t, refused, err)
+	duplicate, err = process(1, "b", nil)
+	require.True(t, duplicate)
+	require.Equal(t, refused, err)
+	require.Equal(t, 2, calls)
+
+	// retryable errors aren't
+	_, err = process(1, "c", errors.New("try again"))
+	require.Error(t, err)
+	duplicate, err = process(1, "c", nil)
+	require.False(t, duplicate)
+	require.NoError(t, err)
+
+	// the same message of another ceremony is another message
+	duplicate, _ = process(2, "a", nil)
+	require.False(t, duplicate)
+	require.Equal(t, 5, calls)
+
+	// ceremonies are forgotten after the window
+	p.window = 0
+	time.Sleep(time.Millisecond)
+	process(3, "a", nil)
+	duplicate, _ = process(1, "a", nil)
+	require.False(t, duplicate)
+}
+
+func TestHandleConsumeIdempotent(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	ks := testingutils.Testing4SharesSet()
+	d, network := newTestDispatcher(ks, 4, 4)
+	d.timeout = 500 * time.Millisecond
+	prater, err := dkgnetwork.NewCustom(hex.EncodeToString(testingutils.TestingForkVersion[:]), "", "")
+	require.NoError(t, err)
+	h := New(&logger.Logger{Logger: logrus.New()}, prater)
+	r := gin.New()
+	r.POST("/consume", h.HandleConsume(d))
+
+	consume := func(body []byte) int {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/consume", bytes.NewReader(body)))
+		return w.Code
+	}
+
+	// messages sent again get the answer they got the first time
+	requestID := testRequestID(1)
+	init := encodeTestMessage(t, testInit(ks, requestID))
+	protocol := encodeTestMessage(t, testProtocol(ks, requestID, 2))
+	for i := 0; i < 2; i++ {
+		require.Equal(t, http.StatusOK, consume(init))
+		require.Equal(t, http.StatusOK, consume(protocol))
+	}
+	require.Len(t, network.GetBroadcastMessages(), 1)
+
+	// even once the ceremony timed out, without taking a node for it again
+	require.Eventually(t, func() bool { return d.Active() == 0 }, 2*time.Second, time.Millisecond)
+	require.Equal(t, http.StatusOK, consume(init))
+	require.Equal(t, 0, d.Active())
+
+	// another init of a ceremony that started is refused for good
+	require.Equal(t, http.StatusOK, consume(encodeTestMessage(t, testInit(ks, testRequestID(2)))))
+	conflicting := testingutils.SignDKGMsg(ks.DKGOperators[1].SK, 1, &dkg.Message{
+		MsgType:    dkg.InitMsgType,
+		Identifier: testRequestID(2),
+		Data:       testingutils.InitMessageDataBytes([]types.OperatorID{1, 2, 3}, 2, testingutils.TestingWithdrawalCredentials, testingutils.TestingForkVersion),
+	})
+	for i := 0; i < 2; i++ {
+		require.Equal(t, http.StatusUnprocessableEntity, consume(encodeTestMessage(t, conflicting)))
+	}
+
+	// a message arriving before the init of its ceremony may succeed later
+	early := encodeTestMessage(t, testProtocol(ks, testRequestID(3), 2))
+	require.Equal(t, http.StatusInternalServerError, consume(early))
+	require.Equal(t, http.StatusOK, consume(encodeTestMessage(t, testInit(ks, testRequestID(3)))))
+	require.Equal(t, http.StatusOK, consume(early))
+}
+
+func TestHandleConsumePolicyLimit(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	ks := testingutils.Testing4SharesSet()
+	d, _ := newTestDispatcher(ks, 4, 4)
+	db := store.NewMemoryBackend()
+	defer db.Close()
+	storage := store.NewStorage(db)
+	engine, err := policy.NewEngine(&policy.Policy{MaxConcurrentCeremonies: 1}, storage)
+	require.NoError(t, err)
+	prater, err := dkgnetwork.NewCustom(hex.EncodeToString(testingutils.TestingForkVersion[:]), "", "")
+	require.NoError(t, err)
+	h := New(&logger.Logger{Logger: logrus.New()}, prater)
+	h.WithPolicy(engine, 1, testingutils.NewTestingNetwork())
+	r := gin.New()
+	r.POST("/consume", h.HandleConsume(d))
+
+	runningID := testRequestID(9)
+	running := &store.CeremonyRecord{
+		RequestID: hex.EncodeToString(runningID[:]),
+		Type:      store.CeremonyKeygen,
+		Status:    store.CeremonyRunning,
+		StartedAt: time.Now(),
+	}
+	require.NoError(t, storage.SaveCeremony(running))
+
+	// a ceremony over the concurrency limit is sent again later, and isn't refused for good
+	init := encodeTestMessage(t, testInit(ks, testRequestID(1)))
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/consume", bytes.NewReader(init)))
+	require.Equal(t, http.StatusTooManyRequests, w.Code)
+	require.Equal(t, "10", w.Header().Get("Retry-After"))
+
+	_, err = storage.FinishCeremony(runningID, store.CeremonyOutcome{Status: store.CeremonySucceeded}, time.Now())
+	require.NoError(t, err)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/consume", bytes.NewReader(init)))
+	require.Equal(t, http.StatusOK, w.Code)
+}
+
+// rejectionNetwork collects the rejections the node streams
+type rejectionNetwork struct {
+	*testingutils.TestingNetwork
+
+	mu         sync.Mutex
+	rejections []*messenger.RejectionOutput
+}
+
+func (n *rejectionNetwork) StreamDKGRejection(rejection *messenger.RejectionOutput) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.rejections = append(n.rejections, rejection)
+	return nil
+}
+
+func TestHandleConsumePolicyRejection(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	ks := testingutils.Testing4SharesSet()
+	d, _ := newTestDispatcher(ks, 4, 4)
+	db := store.NewMemoryBackend()
+	defer db.Close()
+	engine, err := policy.NewEngine(&policy.Policy{ClusterSizes: []int{7}}, store.NewStorage(db))
+	require.NoError(t, err)
+	prater, err := dkgnetwork.NewCustom(hex.EncodeToString(testingutils.TestingForkVersion[:]), "", "")
+	require.NoError(t, err)
+	h := New(&logger.Logger{Logger: logrus.New()}, prater)
+	network := &rejectionNetwork{TestingNetwork: testingutils.NewTestingNetwork()}
+	h.WithPolicy(engine, 1, network)
+	r := gin.New()
+	r.POST("/consume", h.HandleConsume(d))
+	consume := func(msg *dkg.SignedMessage) {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/consume", bytes.NewReader(encodeTestMessage(t, msg))))
+		require.NotEqual(t, http.StatusOK, w.Code)
+	}
+
+	// the rejection of a ceremony is streamed to its initiator once
+	requestID := testRequestID(1)
+	init := testInit(ks, requestID)
+	consume(init)
+	consume(init)
+	require.Len(t, network.rejections, 1)
+	require.Equal(t, requestID, network.rejections[0].RequestID)
+	require.Equal(t, types.OperatorID(1), network.rejections[0].OperatorID)
+	require.NotEmpty(t, network.rejections[0].Reasons)
+
+	// but not for an init somebody else signed
+	forged := testingutils.SignDKGMsg(ks.DKGOperators[2].SK, 2, &dkg.Message{
+		MsgType:    dkg.InitMsgType,
+		Identifier: testRequestID(2),
+		Data:       init.Message.Data,
+	})
+	consume(forged)
+	require.Len(t, network.rejections, 1)
+}
@@ -0,0 +1,260 @@
+package node
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/RockX-SG/frost-dkg-demo/internal/logger"
+	"github.com/RockX-SG/frost-dkg-demo/internal/messenger"
+	"github.com/bloxapp/ssv-spec/dkg"
+	"github.com/bloxapp/ssv-spec/types"
+)
+
+const (
+	// DefaultMaxCeremonies is how many ceremonies the node runs at once by default
+	DefaultMaxCeremonies = 32
+	// DefaultCeremonyQueueSize is how many messages of a ceremony wait to be processed by default
+	DefaultCeremonyQueueSize = 64
+
+	// retryAfterLimit and retryAfterQueue are when to send a message again that the node was too
+	// busy to take, because of the ceremony limit or a full ceremony queue
+	retryAfterLimit = 10 * time.Second
+	retryAfterQueue = time.Second
+)
+
+// Dispatcher runs every ceremony on its own dkg.Node, as the runners of a dkg.Node aren't safe
+// for concurrent use. Messages of a ceremony are processed one at a time in the order they
+// arrived, messages of different ceremonies concurrently, up to a limit of ceremonies.
+//
+// A ceremony holds its node from its first message until it streams its output or blame, its
+// init or reshare message fails, or it times out, and then until its queue drains. A ceremony
+// times out when it waits on the messages of a round for longer than the round timeout, or
+// runs for longer than CeremonyTimeout, and then streams a timeout with the operators it waited
+// on. Messages of a ceremony the node didn't start, like late messages of a ceremony that
+// ended, get a node too, which lasts until they're processed.
+type Dispatcher struct {
+	operator      *dkg.Operator
+	config        *dkg.Config
+	maxCeremonies int
+	queueSize     int
+	timeout       time.Duration
+	roundTimeout  time.Duration
+	logger        *logger.Logger
+
+	mu         sync.Mutex
+	ceremonies map[dkg.RequestID]*ceremonyQueue
+}
+
+type ceremonyQueue struct {
+	requestID dkg.RequestID
+	node      *dkg.Node
+	jobs      chan *dispatchJob
+	// started is set once an init or reshare message was processed, done once the ceremony
+	// ended, which the network of the node sets too
+	started bool
+	done    atomic.Bool
+	// rounds follows the ceremony from its init or reshare message
+	rounds atomic.Pointer[ceremonyRounds]
+}
+
+type dispatchJob struct {
+	msg    *dkg.SignedMessage
+	starts bool
+	fn     func(node *dkg.Node) error
+	result chan error
+}
+
+// NewDispatcher returns a dispatcher running the ceremonies of the operator with the config.
+// The network of the config is wrapped for each ceremony, and streams the timeouts too if it's
+// a TimeoutNetwork.
+func NewDispatcher(operator *dkg.Operator, config *dkg.Config, maxCeremonies, queueSize int, roundTimeout time.Duration, logger *logger.Logger) *Dispatcher {
+	return &Dispatcher{
+		operator:      operator,
+		config:        config,
+		maxCeremonies: maxCeremonies,
+		queueSize:     queueSize,
+		timeout:       CeremonyTimeout,
+		roundTimeout:  roundTimeout,
+		logger:        logger,
+		ceremonies:    make(map[dkg.RequestID]*ceremonyQueue),
+	}
+}
+
+// Dispatch queues fn to process the message on the node of its ceremony, and waits for it to
+// return. It returns *ErrBusy without queueing fn when the node runs as many ceremonies as it
+// can, or the queue of the ceremony is full.
+func (d *Dispatcher) Dispatch(msg *dkg.SignedMessage, fn func(node *dkg.Node) error) error {
+	requestID := msg.Message.Identifier
+	job := &dispatchJob{
+		msg:    msg,
+		starts: msg.Message.MsgType == dkg.InitMsgType || msg.Message.MsgType == dkg.ReshareMsgType,
+		fn:     fn,
+		result: make(chan error, 1),
+	}
+
+	d.mu.Lock()
+	q, ok := d.ceremonies[requestID]
+	if !ok {
+		if len(d.ceremonies) >= d.maxCeremonies {
+			d.mu.Unlock()
+			metricsMessagesThrottled.WithLabelValues(throttledLimit).Inc()
+			return &ErrBusy{
+				Reason:     fmt.Sprintf("the node already runs %d ceremonies", d.maxCeremonies),
+				RetryAfter: retryAfterLimit,
+			}
+		}
+		q = d.newQueue(requestID)
+		d.ceremonies[requestID] = q
+		metricsActiveCeremonies.Set(float64(len(d.ceremonies)))
+		go d.run(q)
+	}
+	select {
+	case q.jobs <- job:
+	default:
+		d.mu.Unlock()
+		metricsMessagesThrottled.WithLabelValues(throttledQueue).Inc()
+		return &ErrBusy{
+			Reason:     fmt.Sprintf("the queue of ceremony %x is full", requestID[:]),
+			RetryAfter: retryAfterQueue,
+		}
+	}
+	d.mu.Unlock()
+
+	return <-job.result
+}
+
+// Active returns how many ceremonies hold a node
+func (d *Dispatcher) Active() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return len(d.ceremonies)
+}
+
+func (d *Dispatcher) newQueue(requestID dkg.RequestID) *ceremonyQueue {
+	q := &ceremonyQueue{
+		requestID: requestID,
+		jobs:      make(chan *dispatchJob, d.queueSize),
+	}
+	config := *d.config
+	config.Network = &dispatchedNetwork{Network: d.config.Network, queue: q}
+	q.node = dkg.NewNode(d.operator, &config)
+	return q
+}
+
+// run processes the jobs of a ceremony until it ended and its queue drained
+func (d *Dispatcher) run(q *ceremonyQueue) {
+	deadline := time.NewTimer(d.timeout)
+	defer deadline.Stop()
+	roundDeadline := time.NewTimer(d.roundTimeout)
+	defer roundDeadline.Stop()
+
+	for {
+		if q.done.Load() || !q.started {
+			if d.release(q) {
+				return
+			}
+		}
+
+		select {
+		case job := <-q.jobs:
+			if job.starts && !q.started {
+				q.rounds.Store(newCeremonyRounds(job.msg, time.Now()))
+			}
+			err := job.fn(q.node)
+			if job.starts {
+				if err == nil {
+					q.started = true
+				} else if !q.started {
+					q.done.Store(true)
+				}
+			}
+			if rounds := q.rounds.Load(); err == nil && rounds != nil {
+				rounds.record(job.msg, time.Now())
+			}
+			job.result <- err
+		case <-deadline.C:
+			d.timeOut(q, messenger.DeadlineCeremony)
+		case <-roundDeadline.C:
+			d.timeOut(q, messenger.DeadlineRound)
+		}
+
+		// the round deadline moves with every round the ceremony finishes
+		if rounds := q.rounds.Load(); q.started && !q.done.Load() && rounds != nil {
+			if !roundDeadline.Stop() {
+				select {
+				case <-roundDeadline.C:
+				default:
+				}
+			}
+			roundDeadline.Reset(time.Until(rounds.deadline(d.roundTimeout)))
+		}
+	}
+}
+
+// timeOut ends a ceremony that stalled, and streams its timeout with the round it waited on
+func (d *Dispatcher) timeOut(q *ceremonyQueue, deadline string) {
+	if q.done.Swap(true) {
+		return
+	}
+	rounds := q.rounds.Load()
+	if !q.started || rounds == nil {
+		return
+	}
+
+	round, missing := rounds.waiting()
+	timeout := &messenger.TimeoutOutput{
+		RequestID:  q.requestID,
+		OperatorID: d.operator.OperatorID,
+		Round:      round,
+		Missing:    missing,
+		Deadline:   deadline,
+	}
+	if err := streamTimeout(d.config.Network, timeout); err != nil {
+		d.logger.Errorf("Dispatcher: failed to stream the timeout of ceremony %x: %v", q.requestID[:], err)
+	}
+}
+
+// release removes the ceremony if no message waits in its queue
+func (d *Dispatcher) release(q *ceremonyQueue) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if len(q.jobs) > 0 {
+		return false
+	}
+	delete(d.ceremonies, q.requestID)
+	metricsActiveCeremonies.Set(float64(len(d.ceremonies)))
+	return true
+}
+
+// dispatchedNetwork is the network of the node of a ceremony, which ends the ceremony once it
+// streams its output or blame
+type dispatchedNetwork struct {
+	dkg.Network
+
+	queue *ceremonyQueue
+}
+
+// BroadcastDKGMessage follows the rounds of the ceremony through the messages of the node. The
+// messages of a ceremony that ended, which the round timer of the frost protocol may still
+// send, are dropped.
+func (n *dispatchedNetwork) BroadcastDKGMessage(msg *dkg.SignedMessage) error {
+	if n.queue.done.Load() {
+		return nil
+	}
+	if rounds := n.queue.rounds.Load(); rounds != nil {
+		rounds.record(msg, time.Now())
+	}
+	return n.Network.BroadcastDKGMessage(msg)
+}
+
+func (n *dispatchedNetwork) StreamDKGOutput(output map[types.OperatorID]*dkg.SignedOutput) error {
+	n.queue.done.Store(true)
+	return n.Network.StreamDKGOutput(output)
+}
+
+func (n *dispatchedNetwork) StreamDKGBlame(blame *dkg.BlameOutput) error {
+	n.queue.done.Store(true)
+	return n.Network.StreamDKGBlame(blame)
+}
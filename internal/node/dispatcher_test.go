@@ -0,0 +1,362 @@
+package node
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/RockX-SG/frost-dkg-demo/internal/logger"
+	"github.com/RockX-SG/frost-dkg-demo/internal/messenger"
+	dkgnetwork "github.com/RockX-SG/frost-dkg-demo/internal/network"
+	"github.com/bloxapp/ssv-spec/dkg"
+	"github.com/bloxapp/ssv-spec/dkg/stubdkg"
+	"github.com/bloxapp/ssv-spec/types"
+	"github.com/bloxapp/ssv-spec/types/testingutils"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+)
+
+// lockedStorage makes the testing storage safe for the concurrent ceremonies
+type lockedStorage struct {
+	dkg.Storage
+	mu sync.Mutex
+}
+
+func (s *lockedStorage) GetDKGOperator(operatorID types.OperatorID) (bool, *dkg.Operator, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.Storage.GetDKGOperator(operatorID)
+}
+
+func (s *lockedStorage) SaveKeyGenOutput(output *dkg.KeyGenOutput) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.Storage.SaveKeyGenOutput(output)
+}
+
+func (s *lockedStorage) GetKeyGenOutput(pk types.ValidatorPK) (*dkg.KeyGenOutput, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.Storage.GetKeyGenOutput(pk)
+}
+
+// newTestDispatcher returns a dispatcher running the ceremonies of operator 1 of the key set with
+// a keygen protocol that finishes on its first message
+func newTestDispatcher(ks *testingutils.TestKeySet, maxCeremonies, queueSize int) (*Dispatcher, *testingutils.TestingNetwork) {
+	testNode := testingutils.TestingDKGNode(ks)
+	config := *testNode.GetConfig()
+	network := testingutils.NewTestingNetwork()
+	config.Network = network
+	config.Storage = &lockedStorage{Storage: config.Storage}
+	operator := &dkg.Operator{
+		OperatorID:       1,
+		ETHAddress:       ks.DKGOperators[1].ETHAddress,
+		EncryptionPubKey: &ks.DKGOperators[1].EncryptionKey.PublicKey,
+	}
+	return NewDispatcher(operator, &config, maxCeremonies, queueSize, DefaultRoundTimeout, &logger.Logger{Logger: logrus.New()}), network
+}
+
+func testMessage(requestID dkg.RequestID, msgType dkg.MsgType) *dkg.SignedMessage {
+	return &dkg.SignedMessage{Message: &dkg.Message{MsgType: msgType, Identifier: requestID}}
+}
+
+// testInitiator is the initiator of the test ceremonies. Key sets are only built before the
+// ceremonies run, as building one initializes BLS again.
+var testInitiator = testingutils.Testing4SharesSet().DKGOperators[1].ETHAddress
+
+func testRequestID(i int) dkg.RequestID {
+	return dkg.NewRequestID(testInitiator, uint32(i))
+}
+
+// testInit returns the init of a keygen of operators 1 to 4 of the key set, by operator 1
+func testInit(ks *testingutils.TestKeySet, requestID dkg.RequestID) *dkg.SignedMessage {
+	return testingutils.SignDKGMsg(ks.DKGOperators[1].SK, 1, &dkg.Message{
+		MsgType:    dkg.InitMsgType,
+		Identifier: requestID,
+		Data:       testingutils.InitMessageDataBytes([]types.OperatorID{1, 2, 3, 4}, 3, testingutils.TestingWithdrawalCredentials, testingutils.TestingForkVersion),
+	})
+}
+
+// testProtocol returns the message of an operator that finishes the keygen of the test dispatcher
+func testProtocol(ks *testingutils.TestKeySet, requestID dkg.RequestID, operatorID types.OperatorID) *dkg.SignedMessage {
+	return testingutils.SignDKGMsg(ks.DKGOperators[operatorID].SK, operatorID, &dkg.Message{
+		MsgType:    dkg.ProtocolMsgType,
+		Identifier: requestID,
+		Data:       testingutils.ProtocolMsgDataBytes(stubdkg.StubStage1),
+	})
+}
+
+func encodeTestMessage(t *testing.T, msg *dkg.SignedMessage) []byte {
+	data, err := msg.Encode()
+	require.NoError(t, err)
+	encoded, err := (&types.SSVMessage{MsgType: types.DKGMsgType, Data: data}).Encode()
+	require.NoError(t, err)
+	return encoded
+}
+
+// blockingJob returns a job that waits for release, and a channel closed once it runs
+func blockingJob(release chan struct{}) (func(*dkg.Node) error, chan struct{}) {
+	running := make(chan struct{})
+	return func(*dkg.Node) error {
+		close(running)
+		<-release
+		return nil
+	}, running
+}
+
+func TestDispatcherOrder(t *testing.T) {
+	d, _ := newTestDispatcher(testingutils.Testing4SharesSet(), 4, 16)
+	requestID := testRequestID(1)
+	msg := testMessage(requestID, dkg.ProtocolMsgType)
+
+	release := make(chan struct{})
+	block, running := blockingJob(release)
+	go d.Dispatch(msg, block)
+	<-running
+
+	// jobs queued while the ceremony is busy run in the order they were queued, one at a time
+	var mu sync.Mutex
+	order := make([]int, 0)
+	inFlight := 0
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		i := i
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			require.NoError(t, d.Dispatch(msg, func(*dkg.Node) error {
+				mu.Lock()
+				inFlight++
+				require.Equal(t, 1, inFlight)
+				order = append(order, i)
+				mu.Unlock()
+				time.Sleep(time.Millisecond)
+				mu.Lock()
+				inFlight--
+				mu.Unlock()
+				return nil
+			}))
+		}()
+		require.Eventually(t, func() bool {
+			d.mu.Lock()
+			defer d.mu.Unlock()
+			return len(d.ceremonies[requestID].jobs) == i+1
+		}, time.Second, time.Millisecond)
+	}
+	close(release)
+	wg.Wait()
+	require.Equal(t, []int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9}, order)
+}
+
+func TestDispatcherLimits(t *testing.T) {
+	d, _ := newTestDispatcher(testingutils.Testing4SharesSet(), 2, 1)
+	release := make(chan struct{})
+	var wg sync.WaitGroup
+	dispatch := func(msg *dkg.SignedMessage, fn func(*dkg.Node) error) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			require.NoError(t, d.Dispatch(msg, fn))
+		}()
+	}
+
+	// the ceremonies run concurrently
+	first, second := testMessage(testRequestID(1), dkg.InitMsgType), testMessage(testRequestID(2), dkg.InitMsgType)
+	block, firstRunning := blockingJob(release)
+	dispatch(first, block)
+	block, secondRunning := blockingJob(release)
+	dispatch(second, block)
+	<-firstRunning
+	<-secondRunning
+
+	var errBusy *ErrBusy
+	err := d.Dispatch(testMessage(testRequestID(3), dkg.InitMsgType), func(*dkg.Node) error { return nil })
+	require.True(t, errors.As(err, &errBusy), err)
+	require.Equal(t, retryAfterLimit, errBusy.RetryAfter)
+
+	dispatch(first, func(*dkg.Node) error { return nil })
+	require.Eventually(t, func() bool {
+		d.mu.Lock()
+		defer d.mu.Unlock()
+		return len(d.ceremonies[first.Message.Identifier].jobs) == 1
+	}, time.Second, time.Millisecond)
+	err = d.Dispatch(first, func(*dkg.Node) error { return nil })
+	require.True(t, errors.As(err, &errBusy), err)
+	require.Equal(t, retryAfterQueue, errBusy.RetryAfter)
+
+	close(release)
+	wg.Wait()
+	// both ceremonies started, so they hold their node until they end
+	require.Equal(t, 2, d.Active())
+}
+
+func TestDispatcherRelease(t *testing.T) {
+	d, _ := newTestDispatcher(testingutils.Testing4SharesSet(), 4, 4)
+	ok := func(*dkg.Node) error { return nil }
+	failed := errors.New("failed")
+	released := func(requestID dkg.RequestID) func() bool {
+		return func() bool {
+			d.mu.Lock()
+			defer d.mu.Unlock()
+			_, found := d.ceremonies[requestID]
+			return !found
+		}
+	}
+
+	// a ceremony that started holds its node until it streams its output
+	output := testRequestID(1)
+	require.NoError(t, d.Dispatch(testMessage(output, dkg.InitMsgType), ok))
+	var node *dkg.Node
+	require.NoError(t, d.Dispatch(testMessage(output, dkg.ProtocolMsgType), func(n *dkg.Node) error {
+		node = n
+		return nil
+	}))
+	require.False(t, released(output)())
+	require.NoError(t, d.Dispatch(testMessage(output, dkg.OutputMsgType), func(n *dkg.Node) error {
+		require.Same(t, node, n)
+		return n.GetConfig().Network.StreamDKGOutput(map[types.OperatorID]*dkg.SignedOutput{})
+	}))
+	require.Eventually(t, released(output), time.Second, time.Millisecond)
+
+	// or its blame
+	blame := testRequestID(2)
+	require.NoError(t, d.Dispatch(testMessage(blame, dkg.ReshareMsgType), ok))
+	require.NoError(t, d.Dispatch(testMessage(blame, dkg.ProtocolMsgType), func(n *dkg.Node) error {
+		return n.GetConfig().Network.StreamDKGBlame(&dkg.BlameOutput{})
+	}))
+	require.Eventually(t, released(blame), time.Second, time.Millisecond)
+
+	// a ceremony whose init fails or that never started is released once its queue drains
+	require.ErrorIs(t, d.Dispatch(testMessage(testRequestID(3), dkg.InitMsgType), func(*dkg.Node) error { return failed }), failed)
+	require.Eventually(t, released(testRequestID(3)), time.Second, time.Millisecond)
+	require.ErrorIs(t, d.Dispatch(testMessage(testRequestID(4), dkg.ProtocolMsgType), func(*dkg.Node) error { return failed }), failed)
+	require.Eventually(t, released(testRequestID(4)), time.Second, time.Millisecond)
+
+	// and a ceremony that doesn't end times out
+	d.timeout = 10 * time.Millisecond
+	require.NoError(t, d.Dispatch(testMessage(testRequestID(5), dkg.InitMsgType), ok))
+	require.Eventually(t, released(testRequestID(5)), time.Second, time.Millisecond)
+	require.Equal(t, 0, d.Active())
+}
+
+// TestDispatcherConcurrentCeremonies runs ceremonies on the dkg nodes concurrently, which races
+// on the runners of a single dkg.Node. Run it with -race.
+func TestDispatcherConcurrentCeremonies(t *testing.T) {
+	ks := testingutils.Testing4SharesSet()
+	const ceremonies = 20
+	d, network := newTestDispatcher(ks, ceremonies, 4)
+	prater, err := dkgnetwork.NewCustom(hex.EncodeToString(testingutils.TestingForkVersion[:]), "", "")
+	require.NoError(t, err)
+	h := New(&logger.Logger{Logger: logrus.New()}, prater)
+
+	var wg sync.WaitGroup
+	for i := 0; i < ceremonies; i++ {
+		requestID := testRequestID(i)
+		init := encodeTestMessage(t, testInit(ks, requestID))
+		protocol := encodeTestMessage(t, testProtocol(ks, requestID, 2))
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			require.NoError(t, h.dispatch(d, init))
+			require.NoError(t, h.dispatch(d, protocol))
+		}()
+	}
+	wg.Wait()
+
+	// every ceremony finished its keygen and broadcast its deposit data signature
+	require.Len(t, network.GetBroadcastMessages(), ceremonies)
+	require.Equal(t, ceremonies, d.Active())
+}
+
+// timeoutNetwork collects the timeouts the dispatcher streams
+type timeoutNetwork struct {
+	*testingutils.TestingNetwork
+
+	mu       sync.Mutex
+	timeouts []*messenger.TimeoutOutput
+}
+
+func (n *timeoutNetwork) StreamDKGTimeout(timeout *messenger.TimeoutOutput) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.timeouts = append(n.timeouts, timeout)
+	return nil
+}
+
+func (n *timeoutNetwork) streamed() []*messenger.TimeoutOutput {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return append([]*messenger.TimeoutOutput{}, n.timeouts...)
+}
+
+func TestDispatcherTimeout(t *testing.T) {
+	ks := testingutils.Testing4SharesSet()
+	d, testingNetwork := newTestDispatcher(ks, 4, 4)
+	network := &timeoutNetwork{TestingNetwork: testingNetwork}
+	d.config.Network = network
+	d.roundTimeout = 50 * time.Millisecond
+	prater, err := dkgnetwork.NewCustom(hex.EncodeToString(testingutils.TestingForkVersion[:]), "", "")
+	require.NoError(t, err)
+	h := New(&logger.Logger{Logger: logrus.New()}, prater)
+
+	// a ceremony that waits on a round for too long streams the operators it waited on, and
+	// releases its node
+	requestID := testRequestID(1)
+	require.NoError(t, h.dispatch(d, encodeTestMessage(t, testInit(ks, requestID))))
+	require.Eventually(t, func() bool { return len(network.streamed()) == 1 }, time.Second, time.Millisecond)
+	timeout := network.streamed()[0]
+	require.Equal(t, requestID, timeout.RequestID)
+	require.Equal(t, types.OperatorID(1), timeout.OperatorID)
+	require.Equal(t, "Preparation", timeout.Round)
+	require.Equal(t, []types.OperatorID{1, 2, 3, 4}, timeout.Missing)
+	require.Equal(t, messenger.DeadlineRound, timeout.Deadline)
+	require.Eventually(t, func() bool { return d.Active() == 0 }, time.Second, time.Millisecond)
+
+	// as does one that runs for too long
+	d.roundTimeout = time.Hour
+	d.timeout = 50 * time.Millisecond
+	require.NoError(t, h.dispatch(d, encodeTestMessage(t, testInit(ks, testRequestID(2)))))
+	require.Eventually(t, func() bool { return len(network.streamed()) == 2 }, time.Second, time.Millisecond)
+	require.Equal(t, messenger.DeadlineCeremony, network.streamed()[1].Deadline)
+	require.Eventually(t, func() bool { return d.Active() == 0 }, time.Second, time.Millisecond)
+
+	// a ceremony that finished doesn't time out
+	d.roundTimeout = 50 * time.Millisecond
+	d.timeout = CeremonyTimeout
+	requestID = testRequestID(3)
+	require.NoError(t, h.dispatch(d, encodeTestMessage(t, testInit(ks, requestID))))
+	require.NoError(t, d.Dispatch(testMessage(requestID, dkg.OutputMsgType), func(n *dkg.Node) error {
+		return n.GetConfig().Network.StreamDKGOutput(map[types.OperatorID]*dkg.SignedOutput{})
+	}))
+	require.Eventually(t, func() bool { return d.Active() == 0 }, time.Second, time.Millisecond)
+	time.Sleep(100 * time.Millisecond)
+	require.Len(t, network.streamed(), 2)
+}
+
+func TestHandleConsumeBusy(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	d, _ := newTestDispatcher(testingutils.Testing4SharesSet(), 1, 1)
+	h := New(&logger.Logger{Logger: logrus.New()}, nil)
+	r := gin.New()
+	r.POST("/consume", h.HandleConsume(d))
+
+	release := make(chan struct{})
+	defer close(release)
+	block, running := blockingJob(release)
+	go d.Dispatch(testMessage(testRequestID(1), dkg.InitMsgType), block)
+	<-running
+
+	body := encodeTestMessage(t, testMessage(testRequestID(2), dkg.InitMsgType))
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/consume", bytes.NewReader(body)))
+	require.Equal(t, http.StatusTooManyRequests, w.Code)
+	require.Equal(t, fmt.Sprint(int(retryAfterLimit.Seconds())), w.Header().Get("Retry-After"))
+}
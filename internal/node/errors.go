@@ -0,0 +1,93 @@
+package node
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ErrInvalidMessage is returned when an incoming payload can't be decoded into a dkg message
+type ErrInvalidMessage struct {
+	Err error
+}
+
+func (err *ErrInvalidMessage) Error() string {
+	return fmt.Sprintf("invalid message: %s", err.Err.Error())
+}
+
+func (err *ErrInvalidMessage) Unwrap() error {
+	return err.Err
+}
+
+// ErrBusy is returned when the node is too busy to take a message, which can be sent again
+// after RetryAfter
+type ErrBusy struct {
+	Reason     string
+	RetryAfter time.Duration
+}
+
+func (err *ErrBusy) Error() string {
+	return fmt.Sprintf("node busy: %s", err.Reason)
+}
+
+// ErrPermanent is returned when the dkg node refused a message that it would refuse again,
+// like one with an invalid signature or for a ceremony that already started
+type ErrPermanent struct {
+	Err error
+}
+
+func (err *ErrPermanent) Error() string {
+	return fmt.Sprintf("message refused: %s", err.Err.Error())
+}
+
+func (err *ErrPermanent) Unwrap() error {
+	return err.Err
+}
+
+// permanentProcessErrors are the errors of dkg.Node.ProcessMessage that processing the message
+// again can't fix. Others, like a message arriving before the init of its ceremony or a
+// storage failure, may succeed later.
+var permanentProcessErrors = []string{
+	"not a DKGMsgType",
+	"unknown msg type",
+	"msg type invalid",
+	"signed message doesn't pass validation",
+	"signed message invalid",
+	"init message invalid",
+	"reshare message invalid",
+	"could not get dkg init Message",
+	"could not get reshare Message",
+	"dkg started already",
+	"keygen has already completed",
+	"can't find operator",
+	"inconsistent partial signature received",
+}
+
+// retryableProcessErrors are the failures of the node itself that the permanent errors of
+// dkg.Node.ProcessMessage may wrap
+var retryableProcessErrors = []string{
+	"can't fetch operator",
+}
+
+// missingRunnerError is the error of dkg.Node.ProcessMessage for a message of a ceremony the
+// node has no runner for. It's permanent for a ceremony the node has a record of, and
+// otherwise the message may have arrived before the init of its ceremony.
+const missingRunnerError = "could not find dkg runner"
+
+// classifyProcessError wraps the permanent errors of dkg.Node.ProcessMessage in ErrPermanent
+func classifyProcessError(err error) error {
+	if err == nil {
+		return nil
+	}
+	for _, retryable := range retryableProcessErrors {
+		if strings.Contains(err.Error(), retryable) {
+			return err
+		}
+	}
+	for _, permanent := range permanentProcessErrors {
+		if strings.Contains(err.Error(), permanent) {
+			return &ErrPermanent{Err: err}
+		}
+	}
+	return err
+}
@@ -0,0 +1,79 @@
+package node
+
+import (
+	"bytes"
+	"encoding/hex"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/RockX-SG/frost-dkg-demo/internal/logger"
+	store "github.com/RockX-SG/frost-dkg-demo/internal/storage"
+	"github.com/bloxapp/ssv-spec/types/testingutils"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+)
+
+// TestProcessErrorsInSpec pins the errors classifyProcessError matches to the ones of the
+// ssv-spec version the node is built with, so an upgrade changing them fails here
+func TestProcessErrorsInSpec(t *testing.T) {
+	dir, err := exec.Command("go", "list", "-m", "-f", "{{.Dir}}", "github.com/bloxapp/ssv-spec").Output()
+	require.NoError(t, err)
+	var sources strings.Builder
+	err = filepath.WalkDir(filepath.Join(strings.TrimSpace(string(dir)), "dkg"), func(path string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() || !strings.HasSuffix(path, ".go") || strings.HasSuffix(path, "_test.go") {
+			return err
+		}
+		source, err := os.ReadFile(path)
+		sources.Write(source)
+		return err
+	})
+	require.NoError(t, err)
+
+	errs := append(append([]string{missingRunnerError}, permanentProcessErrors...), retryableProcessErrors...)
+	for _, processErr := range errs {
+		require.Contains(t, sources.String(), `"`+processErr, "ssv-spec has no error %q", processErr)
+	}
+}
+
+func TestHandleConsumeMissingRunner(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	ks := testingutils.Testing4SharesSet()
+	d, _ := newTestDispatcher(ks, 4, 4)
+	db := store.NewMemoryBackend()
+	defer db.Close()
+	storage := store.NewStorage(db)
+	h := New(&logger.Logger{Logger: logrus.New()}, nil)
+	h.WithCeremonyRecorder(NewCeremonyRecorder(testingutils.NewTestingNetwork(), storage, h.logger))
+	r := gin.New()
+	r.POST("/consume", h.HandleConsume(d))
+	consume := func(body []byte) int {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/consume", bytes.NewReader(body)))
+		return w.Code
+	}
+
+	// a message of a ceremony that ended is refused for good
+	ended := testRequestID(1)
+	require.NoError(t, storage.SaveCeremony(&store.CeremonyRecord{
+		RequestID: hex.EncodeToString(ended[:]),
+		Type:      store.CeremonyKeygen,
+		Status:    store.CeremonyRunning,
+		StartedAt: time.Now(),
+	}))
+	_, err := storage.FinishCeremony(ended, store.CeremonyOutcome{Status: store.CeremonyBlamed}, time.Now())
+	require.NoError(t, err)
+	late := encodeTestMessage(t, testProtocol(ks, ended, 2))
+	require.Equal(t, http.StatusUnprocessableEntity, consume(late))
+	require.Equal(t, http.StatusUnprocessableEntity, consume(late))
+
+	// one of a ceremony the node has no record of may arrive before its init
+	require.Equal(t, http.StatusInternalServerError, consume(encodeTestMessage(t, testProtocol(ks, testRequestID(2), 2))))
+}
@@ -0,0 +1,85 @@
+package node
+
+import (
+	"net/http"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+type healthCheck struct {
+	name  string
+	check func() error
+}
+
+// HealthChecker runs the checks behind /healthz and /readyz. Liveness checks cover what the
+// node needs to run at all, readiness checks additionally cover the services it needs to
+// take part in ceremonies.
+type HealthChecker struct {
+	liveness  []healthCheck
+	readiness []healthCheck
+}
+
+func NewHealthChecker() *HealthChecker {
+	return &HealthChecker{}
+}
+
+func (hc *HealthChecker) AddLivenessCheck(name string, check func() error) {
+	hc.liveness = append(hc.liveness, healthCheck{name: name, check: check})
+}
+
+func (hc *HealthChecker) AddReadinessCheck(name string, check func() error) {
+	hc.readiness = append(hc.readiness, healthCheck{name: name, check: check})
+}
+
+func (hc *HealthChecker) HandleHealthz() func(*gin.Context) {
+	return func(c *gin.Context) {
+		respond(c, runChecks(hc.liveness))
+	}
+}
+
+func (hc *HealthChecker) HandleReadyz() func(*gin.Context) {
+	return func(c *gin.Context) {
+		checks := append(append([]healthCheck{}, hc.liveness...), hc.readiness...)
+		respond(c, runChecks(checks))
+	}
+}
+
+// runChecks runs the checks concurrently and returns "ok" or the error of every check by name
+func runChecks(checks []healthCheck) map[string]string {
+	results := make(map[string]string)
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+
+	for _, hcheck := range checks {
+		wg.Add(1)
+		go func(hcheck healthCheck) {
+			defer wg.Done()
+			status := "ok"
+			if err := hcheck.check(); err != nil {
+				status = err.Error()
+			}
+			mu.Lock()
+			results[hcheck.name] = status
+			mu.Unlock()
+		}(hcheck)
+	}
+	wg.Wait()
+	return results
+}
+
+func respond(c *gin.Context, results map[string]string) {
+	for _, status := range results {
+		if status != "ok" {
+			c.JSON(http.StatusServiceUnavailable, gin.H{
+				"status": "unavailable",
+				"checks": results,
+			})
+			return
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"status": "ok",
+		"checks": results,
+	})
+}
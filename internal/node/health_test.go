@@ -0,0 +1,62 @@
+package node
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+)
+
+type healthResponse struct {
+	Status string            `json:"status"`
+	Checks map[string]string `json:"checks"`
+}
+
+// getHealth requests a health endpoint and returns its status code and response
+func getHealth(t *testing.T, r *gin.Engine, path string) (int, *healthResponse) {
+	t.Helper()
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+	resp := &healthResponse{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), resp))
+	return w.Code, resp
+}
+
+func TestHealthChecker(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	var databaseErr, registryErr error
+	health := NewHealthChecker()
+	health.AddLivenessCheck("database", func() error { return databaseErr })
+	health.AddReadinessCheck("registry", func() error { return registryErr })
+	r := gin.New()
+	r.GET("/healthz", health.HandleHealthz())
+	r.GET("/readyz", health.HandleReadyz())
+
+	// every check passes
+	code, resp := getHealth(t, r, "/healthz")
+	require.Equal(t, http.StatusOK, code)
+	require.Equal(t, &healthResponse{Status: "ok", Checks: map[string]string{"database": "ok"}}, resp)
+	code, resp = getHealth(t, r, "/readyz")
+	require.Equal(t, http.StatusOK, code)
+	require.Equal(t, &healthResponse{Status: "ok", Checks: map[string]string{"database": "ok", "registry": "ok"}}, resp)
+
+	// a failing readiness check makes the node unready, but it's still alive
+	registryErr = errors.New("registry unreachable")
+	code, _ = getHealth(t, r, "/healthz")
+	require.Equal(t, http.StatusOK, code)
+	code, resp = getHealth(t, r, "/readyz")
+	require.Equal(t, http.StatusServiceUnavailable, code)
+	require.Equal(t, &healthResponse{Status: "unavailable", Checks: map[string]string{"database": "ok", "registry": "registry unreachable"}}, resp)
+
+	// a failing liveness check fails both
+	databaseErr = errors.New("database closed")
+	for _, path := range []string{"/healthz", "/readyz"} {
+		code, resp = getHealth(t, r, path)
+		require.Equal(t, http.StatusServiceUnavailable, code, path)
+		require.Equal(t, "database closed", resp.Checks["database"], path)
+	}
+}
@@ -0,0 +1,82 @@
+package node
+
+import (
+	"bytes"
+	"crypto/rand"
+	"fmt"
+	"strings"
+
+	"github.com/RockX-SG/frost-dkg-demo/internal/keymanager"
+	"github.com/bloxapp/ssv-spec/dkg"
+	"github.com/bloxapp/ssv-spec/types"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// ErrIdentityMismatch is returned when the node's keys don't belong to the operator it runs as
+type ErrIdentityMismatch struct {
+	OperatorID types.OperatorID
+	Reason     string
+	Hint       string
+}
+
+func (e *ErrIdentityMismatch) Error() string {
+	return fmt.Sprintf("node is not operator %d: %s (%s)", e.OperatorID, e.Reason, e.Hint)
+}
+
+// CheckIdentity verifies that the keystore address is the ETH address the registry has for the
+// operator, and, when the node holds an RSA key, that it can decrypt data encrypted to the
+// operator's registered RSA public key
+func CheckIdentity(operator *dkg.Operator, address common.Address, signer keymanager.Signer) error {
+	if operator.ETHAddress != address {
+		return &ErrIdentityMismatch{
+			OperatorID: operator.OperatorID,
+			Reason:     fmt.Sprintf("keystore address %s is not the registered owner %s", address.Hex(), operator.ETHAddress.Hex()),
+			Hint:       "check the operator ID and the keystore file",
+		}
+	}
+
+	if !signer.HasOperatorKey() {
+		return nil
+	}
+	if operator.EncryptionPubKey == nil {
+		return &ErrIdentityMismatch{
+			OperatorID: operator.OperatorID,
+			Reason:     "the registry has no RSA public key for the operator",
+			Hint:       "check the operator registration",
+		}
+	}
+
+	challenge := make([]byte, 32)
+	if _, err := rand.Read(challenge); err != nil {
+		return err
+	}
+	cipher, err := signer.Encrypt(operator.EncryptionPubKey, challenge)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt identity challenge to the registered RSA key: %v", err)
+	}
+	plaintext, err := signer.DecryptWithOperatorKey(cipher)
+	if err != nil || !bytes.Equal(plaintext, challenge) {
+		return &ErrIdentityMismatch{
+			OperatorID: operator.OperatorID,
+			Reason:     "the node's RSA key can't decrypt data encrypted to the registered RSA public key",
+			Hint:       "check the operator RSA key file",
+		}
+	}
+	return nil
+}
+
+// SelectOperatorID picks the operator ID from the operators owned by the keystore address
+func SelectOperatorID(address common.Address, ids []types.OperatorID) (types.OperatorID, error) {
+	switch len(ids) {
+	case 0:
+		return 0, fmt.Errorf("no operator is registered by %s, set the operator ID explicitly", address.Hex())
+	case 1:
+		return ids[0], nil
+	default:
+		found := make([]string, 0, len(ids))
+		for _, id := range ids {
+			found = append(found, fmt.Sprintf("%d", id))
+		}
+		return 0, fmt.Errorf("%s owns operators %s, set the operator ID explicitly", address.Hex(), strings.Join(found, ", "))
+	}
+}
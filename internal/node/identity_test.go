@@ -0,0 +1,74 @@
+package node
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/RockX-SG/frost-dkg-demo/internal/keymanager"
+	"github.com/RockX-SG/frost-dkg-demo/internal/network"
+	"github.com/bloxapp/ssv-spec/dkg"
+	"github.com/bloxapp/ssv-spec/types"
+	"github.com/bloxapp/ssv-spec/types/testingutils"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckIdentity(t *testing.T) {
+	ks := testingutils.Testing4SharesSet()
+	operator := ks.DKGOperators[1]
+	net := &network.Network{DomainType: types.PrimusTestnet}
+	registered := &dkg.Operator{
+		OperatorID:       1,
+		ETHAddress:       operator.ETHAddress,
+		EncryptionPubKey: &operator.EncryptionKey.PublicKey,
+	}
+
+	// the keystore and RSA key of the registered operator
+	signer := keymanager.NewKeyManager(net, keymanager.NewLocalSigner(operator.SK), operator.EncryptionKey)
+	require.NoError(t, CheckIdentity(registered, operator.ETHAddress, signer))
+
+	// without an RSA key only the owner address is checked
+	withoutRSA := keymanager.NewKeyManager(net, keymanager.NewLocalSigner(operator.SK), nil)
+	require.NoError(t, CheckIdentity(registered, operator.ETHAddress, withoutRSA))
+
+	var mismatch *ErrIdentityMismatch
+	// the keystore of another owner
+	err := CheckIdentity(registered, ks.DKGOperators[2].ETHAddress, signer)
+	require.True(t, errors.As(err, &mismatch))
+	require.Equal(t, types.OperatorID(1), mismatch.OperatorID)
+	require.Contains(t, mismatch.Reason, "is not the registered owner")
+
+	// an RSA key that can't decrypt data encrypted to the registered key
+	otherRSA := keymanager.NewKeyManager(net, keymanager.NewLocalSigner(operator.SK), ks.DKGOperators[2].EncryptionKey)
+	err = CheckIdentity(registered, operator.ETHAddress, otherRSA)
+	require.True(t, errors.As(err, &mismatch))
+	require.Contains(t, mismatch.Reason, "can't decrypt")
+
+	// an operator registered without an RSA key
+	err = CheckIdentity(&dkg.Operator{OperatorID: 1, ETHAddress: operator.ETHAddress}, operator.ETHAddress, signer)
+	require.True(t, errors.As(err, &mismatch))
+	require.Contains(t, mismatch.Reason, "no RSA public key")
+}
+
+func TestSelectOperatorID(t *testing.T) {
+	address := common.HexToAddress("0x535953b5A6040074948cf185EAa7d2aBBD66808f")
+	for _, test := range []struct {
+		name string
+		ids  []types.OperatorID
+		id   types.OperatorID
+		err  string
+	}{
+		{"no operator", nil, 0, "no operator is registered by " + address.Hex()},
+		{"one operator", []types.OperatorID{7}, 7, ""},
+		{"several operators", []types.OperatorID{7, 12, 40}, 0, "owns operators 7, 12, 40"},
+	} {
+		id, err := SelectOperatorID(address, test.ids)
+		require.Equal(t, test.id, id, test.name)
+		if test.err == "" {
+			require.NoError(t, err, test.name)
+		} else {
+			require.Error(t, err, test.name)
+			require.Contains(t, err.Error(), test.err, test.name)
+		}
+	}
+}
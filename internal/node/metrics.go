@@ -0,0 +1,155 @@
+package node
+
+import (
+	"encoding/hex"
+	"sync"
+	"time"
+
+	"github.com/RockX-SG/frost-dkg-demo/internal/messenger"
+	"github.com/bloxapp/ssv-spec/dkg"
+	"github.com/bloxapp/ssv-spec/dkg/frost"
+	"github.com/bloxapp/ssv-spec/types"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	metricsCeremoniesStarted = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "dkg_node_ceremonies_started_total",
+		Help: "Number of keygen and reshare ceremonies this node joined",
+	}, []string{"type"})
+
+	metricsCeremoniesFinished = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "dkg_node_ceremonies_finished_total",
+		Help: "Number of ceremonies that ended on this node, by outcome",
+	}, []string{"outcome"})
+
+	metricsCeremoniesRejected = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "dkg_node_ceremonies_rejected_total",
+		Help: "Number of keygen and reshare ceremonies this node refused to join by its policy",
+	}, []string{"type"})
+
+	metricsActiveCeremonies = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "dkg_node_active_ceremonies",
+		Help: "Number of ceremonies the node is running",
+	})
+
+	metricsMessagesThrottled = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "dkg_node_messages_throttled_total",
+		Help: "Number of messages the node was too busy to take, by the limit they hit",
+	}, []string{"limit"})
+
+	metricsDuplicateMessages = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "dkg_node_duplicate_messages_total",
+		Help: "Number of messages received again that the node had processed already",
+	})
+
+	metricsProcessingTime = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "dkg_node_message_processing_seconds",
+		Help:    "Time spent by the dkg node processing a single message",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"msg_type", "round"})
+
+	metricsProcessErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "dkg_node_process_message_errors_total",
+		Help: "Number of messages the dkg node failed to process",
+	}, []string{"msg_type", "round"})
+)
+
+const (
+	throttledLimit  = "ceremonies"
+	throttledQueue  = "queue"
+	throttledPolicy = "policy"
+
+	ceremonyOutcomeCompleted = "completed"
+	ceremonyOutcomeFailed    = "failed"
+	ceremonyOutcomeTimedOut  = "timed_out"
+
+	// finishedTTL is how long a finished ceremony is remembered to avoid counting it twice
+	finishedTTL = 2 * time.Hour
+)
+
+// messageLabels returns the message type and frost round labels of an incoming message
+func messageLabels(msg *types.SSVMessage) (string, string) {
+	signedMsg := &dkg.SignedMessage{}
+	if err := signedMsg.Decode(msg.Data); err != nil {
+		return "unknown", ""
+	}
+	round := frost.Uninitialized
+	if signedMsg.Message.MsgType == dkg.ProtocolMsgType {
+		protocolMsg := &frost.ProtocolMsg{}
+		if err := protocolMsg.Decode(signedMsg.Message.Data); err == nil {
+			round = protocolMsg.Round
+		}
+	}
+	return messenger.MsgTypeName(signedMsg.Message.MsgType), messenger.RoundName(signedMsg.Message.MsgType, round)
+}
+
+// observeProcessed records the processing time of a message, and counts the ceremonies
+// started by init and reshare messages that the node accepted
+func observeProcessed(msg *types.SSVMessage, start time.Time, err error) {
+	msgType, round := messageLabels(msg)
+	metricsProcessingTime.WithLabelValues(msgType, round).Observe(time.Since(start).Seconds())
+	if err != nil {
+		metricsProcessErrors.WithLabelValues(msgType, round).Inc()
+		return
+	}
+	if msgType == messenger.MsgTypeName(dkg.InitMsgType) || msgType == messenger.MsgTypeName(dkg.ReshareMsgType) {
+		metricsCeremoniesStarted.WithLabelValues(msgType).Inc()
+	}
+}
+
+// InstrumentedNetwork wraps a dkg.Network and counts the ceremonies that finished with an
+// output, a blame or a timeout. A ceremony is only counted once however many times its result is streamed.
+type InstrumentedNetwork struct {
+	wrappedNetwork
+
+	finished map[string]time.Time
+	mu       sync.Mutex
+}
+
+func NewInstrumentedNetwork(network dkg.Network) *InstrumentedNetwork {
+	return &InstrumentedNetwork{
+		wrappedNetwork: wrappedNetwork{network},
+		finished:       make(map[string]time.Time),
+	}
+}
+
+func (n *InstrumentedNetwork) StreamDKGOutput(output map[types.OperatorID]*dkg.SignedOutput) error {
+	for _, o := range output {
+		n.finish(o.Data.RequestID[:], ceremonyOutcomeCompleted)
+		break
+	}
+	return n.Network.StreamDKGOutput(output)
+}
+
+func (n *InstrumentedNetwork) StreamDKGBlame(blame *dkg.BlameOutput) error {
+	if blame.BlameMessage != nil {
+		n.finish(blame.BlameMessage.Message.Identifier[:], ceremonyOutcomeFailed)
+	}
+	return n.Network.StreamDKGBlame(blame)
+}
+
+func (n *InstrumentedNetwork) StreamDKGTimeout(timeout *messenger.TimeoutOutput) error {
+	n.finish(timeout.RequestID[:], ceremonyOutcomeTimedOut)
+	return n.wrappedNetwork.StreamDKGTimeout(timeout)
+}
+
+func (n *InstrumentedNetwork) finish(requestID []byte, outcome string) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	now := time.Now()
+	for k, finishedAt := range n.finished {
+		if now.Sub(finishedAt) > finishedTTL {
+			delete(n.finished, k)
+		}
+	}
+
+	key := hex.EncodeToString(requestID)
+	if _, ok := n.finished[key]; ok {
+		return
+	}
+	n.finished[key] = now
+	metricsCeremoniesFinished.WithLabelValues(outcome).Inc()
+}
@@ -0,0 +1,120 @@
+package node
+
+import (
+	"fmt"
+
+	"github.com/RockX-SG/frost-dkg-demo/internal/messenger"
+	"github.com/RockX-SG/frost-dkg-demo/internal/policy"
+	store "github.com/RockX-SG/frost-dkg-demo/internal/storage"
+	"github.com/bloxapp/ssv-spec/dkg"
+	"github.com/bloxapp/ssv-spec/types"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// RejectionNetwork is a dkg.Network that also streams the policy rejection of a ceremony to
+// its initiator
+type RejectionNetwork interface {
+	dkg.Network
+	StreamDKGRejection(rejection *messenger.RejectionOutput) error
+}
+
+// streamRejection streams the rejection through the network, if it can
+func streamRejection(network dkg.Network, rejection *messenger.RejectionOutput) error {
+	n, ok := network.(RejectionNetwork)
+	if !ok {
+		return fmt.Errorf("network %T can't stream rejections", network)
+	}
+	return n.StreamDKGRejection(rejection)
+}
+
+// WithPolicy has the node evaluate init and reshare messages against the policy before joining
+// their ceremony. The rejections of operator operatorID are streamed through network.
+func (h *ApiHandler) WithPolicy(engine *policy.Engine, operatorID types.OperatorID, network dkg.Network) {
+	h.policy = engine
+	h.operatorID = operatorID
+	h.rejections = network
+}
+
+// checkPolicy returns a *policy.Rejection for init and reshare messages of ceremonies the
+// policy doesn't allow, and an *ErrBusy for the ones over its concurrent ceremonies limit
+func (h *ApiHandler) checkPolicy(node *dkg.Node, msg *types.SSVMessage) error {
+	signedMsg := &dkg.SignedMessage{}
+	if err := signedMsg.Decode(msg.Data); err != nil {
+		return &ErrInvalidMessage{Err: err}
+	}
+	requestID := signedMsg.Message.Identifier
+	req := &policy.Request{Initiator: requestID.GetETHAddress()}
+
+	switch signedMsg.Message.MsgType {
+	case dkg.InitMsgType:
+		init := &dkg.Init{}
+		if err := init.Decode(signedMsg.Message.Data); err != nil {
+			return &ErrInvalidMessage{Err: err}
+		}
+		req.Type = store.CeremonyKeygen
+		req.Operators = init.OperatorIDs
+		req.Threshold = init.Threshold
+		req.WithdrawalCredentials = init.WithdrawalCredentials
+		req.Fork = init.Fork
+	case dkg.ReshareMsgType:
+		reshare := &dkg.Reshare{}
+		if err := reshare.Decode(signedMsg.Message.Data); err != nil {
+			return &ErrInvalidMessage{Err: err}
+		}
+		req.Type = store.CeremonyReshare
+		req.Operators = reshare.OperatorIDs
+		req.OldOperators = reshare.OldOperatorIDs
+		req.Threshold = reshare.Threshold
+	default:
+		return nil
+	}
+
+	err := h.policy.Evaluate(req)
+	switch err := err.(type) {
+	case *policy.Rejection:
+		metricsCeremoniesRejected.WithLabelValues(req.Type).Inc()
+		h.logger.Warnf("checkPolicy: refusing %s ceremony %x from %s: %v", req.Type, requestID[:], req.Initiator.Hex(), err)
+		h.streamRejection(node, signedMsg, err)
+	case *policy.LimitReached:
+		metricsMessagesThrottled.WithLabelValues(throttledPolicy).Inc()
+		return &ErrBusy{Reason: err.Reason, RetryAfter: retryAfterLimit}
+	}
+	return err
+}
+
+// checkInitiatorSignature checks the message is signed by the initiator of its ceremony.
+// types.Signature.ECRecover can't be used, it returns nil for another signer's address.
+func checkInitiatorSignature(signedMsg *dkg.SignedMessage, domain types.DomainType) error {
+	root, err := types.ComputeSigningRoot(signedMsg, types.ComputeSignatureDomain(domain, types.DKGSignatureType))
+	if err != nil {
+		return fmt.Errorf("failed to compute signing root: %w", err)
+	}
+	pub, err := crypto.SigToPub(root, signedMsg.Signature)
+	if err != nil {
+		return fmt.Errorf("failed to recover signer: %w", err)
+	}
+	initiator := signedMsg.Message.Identifier.GetETHAddress()
+	if signer := crypto.PubkeyToAddress(*pub); signer != initiator {
+		return fmt.Errorf("signed by %s instead of %s", signer.Hex(), initiator.Hex())
+	}
+	return nil
+}
+
+// streamRejection tells the initiator why the node refused its ceremony. Only messages the
+// initiator signed are answered, so that nobody can report a rejection of another initiator's
+// ceremony.
+func (h *ApiHandler) streamRejection(node *dkg.Node, signedMsg *dkg.SignedMessage, rejection *policy.Rejection) {
+	requestID := signedMsg.Message.Identifier
+	if err := checkInitiatorSignature(signedMsg, node.GetConfig().SignatureDomainType); err != nil {
+		h.logger.Warnf("checkPolicy: not streaming the rejection of ceremony %x, its message isn't signed by the initiator: %v", requestID[:], err)
+		return
+	}
+	err := streamRejection(h.rejections, &messenger.RejectionOutput{
+		RequestID:  requestID,
+		OperatorID: h.operatorID,
+		Reasons:    rejection.Reasons,
+	})
+	if err != nil {
+		h.logger.Errorf("checkPolicy: failed to stream the rejection of ceremony %x: %v", requestID[:], err)
+	}
+}
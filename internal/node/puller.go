@@ -0,0 +1,56 @@
+package node
+
+import (
+	"context"
+	"time"
+
+	"github.com/RockX-SG/frost-dkg-demo/internal/messenger"
+)
+
+const (
+	pullWait       = 30 * time.Second
+	pullRetryDelay = 5 * time.Second
+)
+
+// RunPullLoop fetches the messages queued for this operator on the messenger and feeds
+// them to the dkg node, until ctx is done. It is used instead of /consume when the node
+// registered in pull mode. Messages that failed to process are left unacknowledged so the
+// messenger hands them out again, except for the ones that failed permanently, like the ones
+// that can't be decoded at all or the ceremonies the node's policy rejects.
+func (h *ApiHandler) RunPullLoop(ctx context.Context, dispatcher *Dispatcher, client *messenger.Client, operatorID string) {
+	for ctx.Err() == nil {
+		deliveries, err := client.PullMessages(ctx, operatorID, pullWait)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			h.logger.Errorf("RunPullLoop: failed to pull messages from messenger: %v", err)
+			select {
+			case <-ctx.Done():
+			case <-time.After(pullRetryDelay):
+			}
+			continue
+		}
+
+		acks := make([]string, 0, len(deliveries))
+		for _, delivery := range deliveries {
+			if err := h.dispatch(dispatcher, delivery.Data); err != nil {
+				if !isPermanent(err) {
+					h.logger.Errorf("RunPullLoop: dkg node failed to process message %s on attempt %d: %v", delivery.ID, delivery.Attempts, err)
+					continue
+				}
+				h.logger.Errorf("RunPullLoop: dropping message %s: %v", delivery.ID, err)
+			} else {
+				h.logger.Infof("RunPullLoop: dkg node processed message %s successfully", delivery.ID)
+			}
+			acks = append(acks, delivery.ID)
+		}
+
+		if len(acks) == 0 {
+			continue
+		}
+		if err := client.AckMessages(operatorID, acks); err != nil {
+			h.logger.Errorf("RunPullLoop: failed to ack %d messages: %v", len(acks), err)
+		}
+	}
+}
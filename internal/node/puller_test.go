@@ -0,0 +1,78 @@
+package node
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/RockX-SG/frost-dkg-demo/internal/logger"
+	"github.com/RockX-SG/frost-dkg-demo/internal/messenger"
+	dkgnetwork "github.com/RockX-SG/frost-dkg-demo/internal/network"
+	"github.com/bloxapp/ssv-spec/types/testingutils"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunPullLoop(t *testing.T) {
+	ks := testingutils.Testing4SharesSet()
+	d, _ := newTestDispatcher(ks, 4, 4)
+	prater, err := dkgnetwork.NewCustom(hex.EncodeToString(testingutils.TestingForkVersion[:]), "", "")
+	require.NoError(t, err)
+	h := New(&logger.Logger{Logger: logrus.New()}, prater)
+
+	deliveries := []*messenger.Delivery{
+		{ID: "init", Data: encodeTestMessage(t, testInit(ks, testRequestID(1))), Attempts: 1},
+		// arrives before the init of its ceremony, and is handed out again
+		{ID: "early", Data: encodeTestMessage(t, testProtocol(ks, testRequestID(2), 2)), Attempts: 1},
+		{ID: "invalid", Data: []byte("not a message"), Attempts: 1},
+	}
+	pulls := make(chan struct{}, 10)
+	acks := make(chan []string, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/subscribers/1/messages":
+			pulls <- struct{}{}
+			if len(pulls) > 1 {
+				// long-polls until the loop stops
+				<-r.Context().Done()
+				return
+			}
+			json.NewEncoder(w).Encode(deliveries)
+		case "/subscribers/1/ack":
+			req := &messenger.AckRequest{}
+			require.NoError(t, json.NewDecoder(r.Body).Decode(req))
+			acks <- req.IDs
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+	go func() {
+		h.RunPullLoop(ctx, d, messenger.NewMessengerClient(srv.URL), "1")
+		close(stopped)
+	}()
+
+	select {
+	case ids := <-acks:
+		require.Equal(t, []string{"init", "invalid"}, ids)
+	case <-time.After(5 * time.Second):
+		t.Fatal("pulled messages weren't acked")
+	}
+	require.Equal(t, 1, d.Active())
+
+	// a pull in progress is abandoned once the loop is stopped
+	require.Eventually(t, func() bool { return len(pulls) == 2 }, 5*time.Second, time.Millisecond)
+	cancel()
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		t.Fatal("pull loop didn't stop")
+	}
+}
@@ -0,0 +1,137 @@
+package node
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/RockX-SG/frost-dkg-demo/internal/messenger"
+	"github.com/bloxapp/ssv-spec/dkg"
+	"github.com/bloxapp/ssv-spec/dkg/frost"
+	"github.com/bloxapp/ssv-spec/types"
+)
+
+// DefaultRoundTimeout is how long a ceremony waits on the messages of a round by default. It
+// is shorter than the round timeout of the frost protocol, which ends a ceremony without
+// streaming anything.
+const DefaultRoundTimeout = 5 * time.Minute
+
+// ceremonyRounds follows the rounds of a ceremony through the messages the node receives and
+// broadcasts, to tell which operators a ceremony that stalled waits on
+type ceremonyRounds struct {
+	mu        sync.Mutex
+	rounds    []*ceremonyRound
+	current   int
+	startedAt time.Time
+}
+
+type ceremonyRound struct {
+	name string
+	// senders are the operators sending a message in the round, of which needed have to
+	senders  []types.OperatorID
+	needed   int
+	received map[types.OperatorID]bool
+}
+
+func newCeremonyRound(name string, senders []types.OperatorID, needed int) *ceremonyRound {
+	return &ceremonyRound{name: name, senders: senders, needed: needed, received: make(map[types.OperatorID]bool)}
+}
+
+// newCeremonyRounds returns the rounds of the ceremony started by an init or reshare message,
+// or nil for other messages
+func newCeremonyRounds(msg *dkg.SignedMessage, now time.Time) *ceremonyRounds {
+	round := func(round frost.ProtocolRound) string {
+		return messenger.RoundName(dkg.ProtocolMsgType, round)
+	}
+	depositData, output := messenger.MsgTypeName(dkg.DepositDataMsgType), messenger.MsgTypeName(dkg.OutputMsgType)
+
+	var rounds []*ceremonyRound
+	switch msg.Message.MsgType {
+	case dkg.InitMsgType:
+		init := &dkg.Init{}
+		if err := init.Decode(msg.Message.Data); err != nil {
+			return nil
+		}
+		operators := init.OperatorIDs
+		rounds = []*ceremonyRound{
+			newCeremonyRound(round(frost.Preparation), operators, len(operators)),
+			newCeremonyRound(round(frost.Round1), operators, len(operators)),
+			newCeremonyRound(round(frost.Round2), operators, len(operators)),
+			// the deposit data is signed once a threshold of the operators signed it
+			newCeremonyRound(depositData, operators, int(init.Threshold)),
+			newCeremonyRound(output, operators, len(operators)),
+		}
+	case dkg.ReshareMsgType:
+		reshare := &dkg.Reshare{}
+		if err := reshare.Decode(msg.Message.Data); err != nil {
+			return nil
+		}
+		operators, oldOperators := reshare.OperatorIDs, reshare.OldOperatorIDs
+		rounds = []*ceremonyRound{
+			newCeremonyRound(round(frost.Preparation), operators, len(operators)),
+			newCeremonyRound(round(frost.Round1), oldOperators, len(oldOperators)),
+			newCeremonyRound(round(frost.Round2), operators, len(operators)),
+			newCeremonyRound(output, operators, len(operators)),
+		}
+	default:
+		return nil
+	}
+	return &ceremonyRounds{rounds: rounds, startedAt: now}
+}
+
+// record marks the sender of the message as done with its round, and moves on to the next
+// rounds the ceremony has all the messages of
+func (c *ceremonyRounds) record(msg *dkg.SignedMessage, now time.Time) {
+	name := messenger.MsgTypeName(msg.Message.MsgType)
+	if msg.Message.MsgType == dkg.ProtocolMsgType {
+		protocolMsg := &frost.ProtocolMsg{}
+		if err := protocolMsg.Decode(msg.Message.Data); err != nil {
+			return
+		}
+		name = messenger.RoundName(msg.Message.MsgType, protocolMsg.Round)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, round := range c.rounds {
+		if round.name != name {
+			continue
+		}
+		for _, sender := range round.senders {
+			if sender == msg.Signer {
+				round.received[sender] = true
+			}
+		}
+	}
+	for c.current < len(c.rounds) && len(c.rounds[c.current].received) >= c.rounds[c.current].needed {
+		c.current++
+		c.startedAt = now
+	}
+}
+
+// deadline returns when the round the ceremony waits on times out
+func (c *ceremonyRounds) deadline(timeout time.Duration) time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.startedAt.Add(timeout)
+}
+
+// waiting returns the round the ceremony waits on and the operators it misses the message of
+func (c *ceremonyRounds) waiting() (string, []types.OperatorID) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.current == len(c.rounds) {
+		return "", nil
+	}
+	round := c.rounds[c.current]
+	missing := make([]types.OperatorID, 0)
+	for _, sender := range round.senders {
+		if !round.received[sender] {
+			missing = append(missing, sender)
+		}
+	}
+	sort.Slice(missing, func(i, j int) bool { return missing[i] < missing[j] })
+	return round.name, missing
+}
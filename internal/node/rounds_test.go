@@ -0,0 +1,90 @@
+package node
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bloxapp/ssv-spec/dkg"
+	"github.com/bloxapp/ssv-spec/dkg/frost"
+	"github.com/bloxapp/ssv-spec/types"
+	"github.com/bloxapp/ssv-spec/types/testingutils"
+	"github.com/stretchr/testify/require"
+)
+
+func roundMessage(t *testing.T, signer types.OperatorID, round frost.ProtocolRound) *dkg.SignedMessage {
+	data, err := (&frost.ProtocolMsg{Round: round}).Encode()
+	require.NoError(t, err)
+	return &dkg.SignedMessage{Signer: signer, Message: &dkg.Message{MsgType: dkg.ProtocolMsgType, Data: data}}
+}
+
+func signedBy(signer types.OperatorID, msgType dkg.MsgType) *dkg.SignedMessage {
+	return &dkg.SignedMessage{Signer: signer, Message: &dkg.Message{MsgType: msgType}}
+}
+
+func requireWaiting(t *testing.T, rounds *ceremonyRounds, round string, missing ...types.OperatorID) {
+	t.Helper()
+	name, got := rounds.waiting()
+	require.Equal(t, round, name)
+	require.Equal(t, missing, got)
+}
+
+func TestCeremonyRounds(t *testing.T) {
+	start := time.Now()
+	rounds := newCeremonyRounds(&dkg.SignedMessage{Message: &dkg.Message{
+		MsgType: dkg.InitMsgType,
+		Data:    testingutils.InitMessageDataBytes([]types.OperatorID{1, 2, 3, 4}, 3, testingutils.TestingWithdrawalCredentials, testingutils.TestingForkVersion),
+	}}, start)
+	require.NotNil(t, rounds)
+	requireWaiting(t, rounds, "Preparation", 1, 2, 3, 4)
+
+	for _, signer := range []types.OperatorID{4, 2, 1} {
+		rounds.record(roundMessage(t, signer, frost.Preparation), start.Add(time.Second))
+	}
+	requireWaiting(t, rounds, "Preparation", 3)
+	require.Equal(t, start.Add(time.Minute), rounds.deadline(time.Minute))
+
+	// messages of later rounds count once the ceremony gets there
+	for _, signer := range []types.OperatorID{1, 2, 3, 4} {
+		rounds.record(roundMessage(t, signer, frost.Round2), start.Add(time.Second))
+	}
+	rounds.record(roundMessage(t, 3, frost.Preparation), start.Add(2*time.Second))
+	requireWaiting(t, rounds, "Round1", 1, 2, 3, 4)
+	require.Equal(t, start.Add(2*time.Second+time.Minute), rounds.deadline(time.Minute))
+	for _, signer := range []types.OperatorID{1, 2, 3, 4} {
+		rounds.record(roundMessage(t, signer, frost.Round1), start.Add(3*time.Second))
+	}
+
+	// the deposit data needs a threshold of the operators
+	requireWaiting(t, rounds, "deposit_data", 1, 2, 3, 4)
+	for _, signer := range []types.OperatorID{1, 3, 4} {
+		rounds.record(signedBy(signer, dkg.DepositDataMsgType), start.Add(4*time.Second))
+	}
+	requireWaiting(t, rounds, "output", 1, 2, 3, 4)
+	for _, signer := range []types.OperatorID{1, 2, 3, 4} {
+		rounds.record(signedBy(signer, dkg.OutputMsgType), start.Add(5*time.Second))
+	}
+	requireWaiting(t, rounds, "")
+}
+
+func TestReshareRounds(t *testing.T) {
+	reshare := &dkg.Reshare{
+		ValidatorPK:    make([]byte, 48),
+		OperatorIDs:    []types.OperatorID{5, 6, 7, 8},
+		OldOperatorIDs: []types.OperatorID{1, 2, 3},
+		Threshold:      3,
+	}
+	data, err := reshare.Encode()
+	require.NoError(t, err)
+	rounds := newCeremonyRounds(&dkg.SignedMessage{Message: &dkg.Message{MsgType: dkg.ReshareMsgType, Data: data}}, time.Now())
+	require.NotNil(t, rounds)
+
+	// the old operators only send the first round
+	for _, signer := range []types.OperatorID{5, 6, 7, 8} {
+		rounds.record(roundMessage(t, signer, frost.Preparation), time.Now())
+	}
+	rounds.record(roundMessage(t, 5, frost.Round1), time.Now())
+	rounds.record(roundMessage(t, 2, frost.Round1), time.Now())
+	requireWaiting(t, rounds, "Round1", 1, 3)
+
+	require.Nil(t, newCeremonyRounds(signedBy(1, dkg.ProtocolMsgType), time.Now()))
+}
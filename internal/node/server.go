@@ -2,28 +2,61 @@ package node
 
 import (
 	"encoding/hex"
+	"errors"
 	"io"
+	"math"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/RockX-SG/frost-dkg-demo/internal/logger"
+	"github.com/RockX-SG/frost-dkg-demo/internal/network"
+	"github.com/RockX-SG/frost-dkg-demo/internal/policy"
 	"github.com/bloxapp/ssv-spec/dkg"
 	"github.com/bloxapp/ssv-spec/types"
 	"github.com/gin-gonic/gin"
 )
 
+// MessageObserver is implemented by network transports that need to see every new incoming
+// message before it is processed
+type MessageObserver interface {
+	Observe(msg *types.SSVMessage) error
+}
+
 type ApiHandler struct {
-	logger *logger.Logger
+	logger   *logger.Logger
+	network  *network.Network
+	observer MessageObserver
+	// ceremonies records the ceremonies started by the messages the node processes
+	ceremonies *CeremonyRecorder
+	// audit appends the messages the node processes to the audit log
+	audit *AuditLog
+	// policy decides which ceremonies the node joins, and rejections tells the initiators of the
+	// ones it refuses why, as operator operatorID
+	policy     *policy.Engine
+	operatorID types.OperatorID
+	rejections dkg.Network
+	// processed answers messages sent again with the outcome they had
+	processed *processedMessages
+}
+
+func New(logger *logger.Logger, network *network.Network) *ApiHandler {
+	return &ApiHandler{logger: logger, network: network, processed: newProcessedMessages(DedupWindow)}
 }
 
-func New(logger *logger.Logger) *ApiHandler {
-	return &ApiHandler{logger: logger}
+func (h *ApiHandler) WithMessageObserver(observer MessageObserver) {
+	h.observer = observer
 }
 
-func (h *ApiHandler) HandleConsume(node *dkg.Node) func(*gin.Context) {
+// HandleConsume processes a message on the node of its ceremony. A message sent again gets
+// the answer it got the first time. Messages that can't be processed answer 400, 403 or 422
+// and shouldn't be sent again, while 429, with a Retry-After header, and 500 may succeed later.
+func (h *ApiHandler) HandleConsume(dispatcher *Dispatcher) func(*gin.Context) {
 	return func(c *gin.Context) {
 		data, err := io.ReadAll(c.Request.Body)
 		if err != nil {
-			h.logger.Errorf("HandleConsume: failed to read request body: %w", err)
+			h.logger.Errorf("HandleConsume: failed to read request body: %v", err)
 			c.JSON(http.StatusBadRequest, gin.H{
 				"message": "failed to load data from request body",
 				"error":   err.Error(),
@@ -31,18 +64,49 @@ func (h *ApiHandler) HandleConsume(node *dkg.Node) func(*gin.Context) {
 			return
 		}
 
-		msg := &types.SSVMessage{}
-		if err = msg.Decode(data); err != nil {
-			h.logger.Errorf("HandleConsume: failed to parse data from request body: %w", err)
-			c.JSON(http.StatusBadRequest, gin.H{
-				"message": "failed to parse data from request body",
-				"error":   err.Error(),
-			})
-			return
-		}
+		if err = h.dispatch(dispatcher, data); err != nil {
+			var errInvalid *ErrInvalidMessage
+			if errors.As(err, &errInvalid) {
+				h.logger.Errorf("HandleConsume: failed to parse data from request body: %v", err)
+				c.JSON(http.StatusBadRequest, gin.H{
+					"message": "failed to parse data from request body",
+					"error":   err.Error(),
+				})
+				return
+			}
+
+			var errBusy *ErrBusy
+			if errors.As(err, &errBusy) {
+				h.logger.Warnf("HandleConsume: %v", err)
+				c.Header("Retry-After", strconv.Itoa(int(math.Ceil(errBusy.RetryAfter.Seconds()))))
+				c.JSON(http.StatusTooManyRequests, gin.H{
+					"message": "node is too busy to process the message",
+					"error":   err.Error(),
+				})
+				return
+			}
+
+			var rejection *policy.Rejection
+			if errors.As(err, &rejection) {
+				c.JSON(http.StatusForbidden, gin.H{
+					"message": "ceremony rejected by node policy",
+					"error":   err.Error(),
+					"reasons": rejection.Reasons,
+				})
+				return
+			}
 
-		if err = node.ProcessMessage(msg); err != nil {
-			h.logger.Errorf("HandleConsume: dkg node failed to process incoming message: %w", err)
+			var errPermanent *ErrPermanent
+			if errors.As(err, &errPermanent) {
+				h.logger.Errorf("HandleConsume: dkg node refused incoming message: %v", err)
+				c.JSON(http.StatusUnprocessableEntity, gin.H{
+					"message": "dkg node refused message",
+					"error":   err.Error(),
+				})
+				return
+			}
+
+			h.logger.Errorf("HandleConsume: dkg node failed to process incoming message: %v", err)
 			c.JSON(http.StatusInternalServerError, gin.H{
 				"message": "dkg node failed to process message",
 				"error":   err.Error(),
@@ -58,12 +122,109 @@ func (h *ApiHandler) HandleConsume(node *dkg.Node) func(*gin.Context) {
 	}
 }
 
+// dispatch decodes a raw SSVMessage and queues it for the dkg node of its ceremony, then waits
+// until it's processed. A message that was processed already isn't processed again, and
+// returns the outcome it had.
+func (h *ApiHandler) dispatch(dispatcher *Dispatcher, data []byte) error {
+	msg := &types.SSVMessage{}
+	if err := msg.Decode(data); err != nil {
+		return &ErrInvalidMessage{Err: err}
+	}
+	signedMsg := &dkg.SignedMessage{}
+	if err := signedMsg.Decode(msg.Data); err != nil {
+		return &ErrInvalidMessage{Err: err}
+	}
+	// a message processed already is answered without taking a node for a ceremony that may
+	// have ended, and again on the queue of the ceremony for copies that arrive together
+	requestID := signedMsg.Message.Identifier
+	if duplicate, outcome := h.processed.lookup(requestID, data); duplicate {
+		h.skipDuplicate(requestID)
+		return outcome
+	}
+	return dispatcher.Dispatch(signedMsg, func(node *dkg.Node) error {
+		duplicate, err := h.processed.process(requestID, data, func() error {
+			return h.processData(node, msg)
+		})
+		if duplicate {
+			h.skipDuplicate(requestID)
+		}
+		return err
+	})
+}
+
+func (h *ApiHandler) skipDuplicate(requestID dkg.RequestID) {
+	metricsDuplicateMessages.Inc()
+	h.logger.Debugf("dispatch: message of ceremony %x was processed already", requestID[:])
+}
+
+// processData hands a message over to the dkg node of its ceremony
+func (h *ApiHandler) processData(node *dkg.Node, msg *types.SSVMessage) error {
+	if err := h.checkNetwork(msg); err != nil {
+		return &ErrInvalidMessage{Err: err}
+	}
+
+	if h.observer != nil {
+		if err := h.observer.Observe(msg); err != nil {
+			return &ErrInvalidMessage{Err: err}
+		}
+	}
+
+	if h.policy != nil {
+		if err := h.checkPolicy(node, msg); err != nil {
+			return err
+		}
+	}
+
+	start := time.Now()
+	process := func() error {
+		return classifyProcessError(node.ProcessMessage(msg))
+	}
+	var err error
+	if h.audit != nil {
+		err = h.audit.Process(msg, process)
+	} else {
+		err = process()
+	}
+	observeProcessed(msg, start, err)
+	if h.ceremonies == nil {
+		return err
+	}
+	if err == nil {
+		h.ceremonies.Start(msg)
+	} else if strings.Contains(err.Error(), missingRunnerError) {
+		signedMsg := &dkg.SignedMessage{}
+		if decodeErr := signedMsg.Decode(msg.Data); decodeErr == nil {
+			err = h.ceremonies.classifyMissingRunner(signedMsg.Message.Identifier, err)
+		}
+	}
+	return err
+}
+
+// checkNetwork rejects init messages for a keygen on another network than the node's. Reshare
+// messages carry no fork version to check: they reshare a validator whose keygen, and deposit
+// data, the network was checked for already, and the reshare signs no deposit data of its own.
+func (h *ApiHandler) checkNetwork(msg *types.SSVMessage) error {
+	signedMsg := &dkg.SignedMessage{}
+	if err := signedMsg.Decode(msg.Data); err != nil {
+		return err
+	}
+	if signedMsg.Message.MsgType != dkg.InitMsgType {
+		return nil
+	}
+
+	init := &dkg.Init{}
+	if err := init.Decode(signedMsg.Message.Data); err != nil {
+		return err
+	}
+	return h.network.CheckForkVersion(init.Fork)
+}
+
 func (h *ApiHandler) HandleGetDKGResults(node *dkg.Node) func(*gin.Context) {
 	return func(c *gin.Context) {
 		vkByte, _ := hex.DecodeString(c.Param("vk"))
 		output, err := node.GetConfig().GetStorage().GetKeyGenOutput(vkByte)
 		if err != nil {
-			h.logger.Errorf("HandleGetDKGResults: failed to get dkg result for vk %s: %w", c.Param("vk"), err)
+			h.logger.Errorf("HandleGetDKGResults: failed to get dkg result for vk %s: %v", c.Param("vk"), err)
 			c.AbortWithError(http.StatusInternalServerError, err)
 			return
 		}
@@ -0,0 +1,85 @@
+package node
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/RockX-SG/frost-dkg-demo/internal/keymanager"
+	"github.com/RockX-SG/frost-dkg-demo/internal/logger"
+	store "github.com/RockX-SG/frost-dkg-demo/internal/storage"
+	"github.com/bloxapp/ssv-spec/dkg"
+	"github.com/bloxapp/ssv-spec/types"
+	"github.com/herumi/bls-eth-go-binary/bls"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var metricsShareCheckFailures = promauto.NewCounter(prometheus.CounterOpts{
+	Name: "dkg_node_share_check_failures_total",
+	Help: "Number of ceremony outputs whose encrypted share this node couldn't decrypt to its share public key",
+})
+
+// ShareCheckingNetwork wraps a dkg.Network and, before a ceremony output is streamed, checks that
+// the node can decrypt its own encrypted share with the operator's RSA key and that the share
+// matches its share public key. A checked share is handed to the signer so it can sign with it.
+// A failed check is logged but doesn't hold up the output.
+type ShareCheckingNetwork struct {
+	wrappedNetwork
+
+	operatorID types.OperatorID
+	signer     keymanager.Signer
+	logger     *logger.Logger
+}
+
+func NewShareCheckingNetwork(network dkg.Network, operatorID types.OperatorID, signer keymanager.Signer, logger *logger.Logger) *ShareCheckingNetwork {
+	return &ShareCheckingNetwork{
+		wrappedNetwork: wrappedNetwork{network},
+		operatorID:     operatorID,
+		signer:         signer,
+		logger:         logger,
+	}
+}
+
+func (n *ShareCheckingNetwork) StreamDKGOutput(output map[types.OperatorID]*dkg.SignedOutput) error {
+	if own, ok := output[n.operatorID]; ok && n.signer.HasOperatorKey() {
+		share, err := n.checkShare(own.Data)
+		if err != nil {
+			metricsShareCheckFailures.Inc()
+			n.logger.Errorf("ShareCheckingNetwork: encrypted share of validator %x is unusable: %v", own.Data.ValidatorPubKey, err)
+		} else if err := n.signer.AddShare(share); err != nil {
+			n.logger.Errorf("ShareCheckingNetwork: failed to add share of validator %x to the signer: %v", own.Data.ValidatorPubKey, err)
+		}
+	}
+	return n.Network.StreamDKGOutput(output)
+}
+
+func (n *ShareCheckingNetwork) checkShare(output *dkg.Output) (*bls.SecretKey, error) {
+	plaintext, err := n.signer.DecryptWithOperatorKey(output.EncryptedShare)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt: %v", err)
+	}
+
+	share := &bls.SecretKey{}
+	if err := share.SetHexString(strings.TrimPrefix(string(plaintext), "0x")); err != nil {
+		return nil, fmt.Errorf("decrypted share is not a BLS secret key: %v", err)
+	}
+	if !bytes.Equal(share.GetPublicKey().Serialize(), output.SharePubKey) {
+		return nil, fmt.Errorf("decrypted share doesn't match share public key %x", output.SharePubKey)
+	}
+	return share, nil
+}
+
+// LoadShares hands the shares stored by earlier ceremonies to the signer, so that it still signs
+// with them after a restart. It returns the number of shares loaded.
+func LoadShares(storage *store.Storage, signer keymanager.Signer) (int, error) {
+	loaded := 0
+	err := storage.ForEachKeyGenOutput(func(output *dkg.KeyGenOutput) error {
+		if err := signer.AddShare(output.Share); err != nil {
+			return fmt.Errorf("failed to add share of validator %x to the signer: %v", output.ValidatorPK, err)
+		}
+		loaded++
+		return nil
+	})
+	return loaded, err
+}
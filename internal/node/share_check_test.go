@@ -0,0 +1,92 @@
+package node
+
+import (
+	"testing"
+
+	"github.com/RockX-SG/frost-dkg-demo/internal/keymanager"
+	"github.com/RockX-SG/frost-dkg-demo/internal/logger"
+	"github.com/RockX-SG/frost-dkg-demo/internal/network"
+	store "github.com/RockX-SG/frost-dkg-demo/internal/storage"
+	"github.com/bloxapp/ssv-spec/dkg"
+	"github.com/bloxapp/ssv-spec/types"
+	"github.com/bloxapp/ssv-spec/types/testingutils"
+	"github.com/herumi/bls-eth-go-binary/bls"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+)
+
+func TestShareCheckingNetwork(t *testing.T) {
+	ks := testingutils.Testing4SharesSet()
+	operator := ks.DKGOperators[1]
+	stream := func(output *dkg.Output) (keymanager.Signer, *testingutils.TestingNetwork) {
+		signer := keymanager.NewKeyManager(&network.Network{DomainType: types.PrimusTestnet}, keymanager.NewLocalSigner(operator.SK), operator.EncryptionKey)
+		inner := testingutils.NewTestingNetwork()
+		n := NewShareCheckingNetwork(inner, 1, signer, &logger.Logger{Logger: logrus.New()})
+		require.NoError(t, n.StreamDKGOutput(map[types.OperatorID]*dkg.SignedOutput{1: {Data: output, Signer: 1}}))
+		require.Len(t, inner.DKGOutputs, 1)
+		return signer, inner
+	}
+	failures := testutil.ToFloat64(metricsShareCheckFailures)
+
+	// a share that decrypts to its share public key is handed to the signer
+	output := *ks.SignedOutputObject(testRequestID(1), 1, nil).Data
+	signer, _ := stream(&output)
+	_, err := signer.SignRoot(&output, types.QBFTSignatureType, output.SharePubKey)
+	require.NoError(t, err)
+	require.Equal(t, failures, testutil.ToFloat64(metricsShareCheckFailures))
+
+	// one that doesn't is counted, and the output is still streamed
+	mismatched := output
+	mismatched.EncryptedShare = testingutils.TestingEncryption(&operator.EncryptionKey.PublicKey, []byte("0x"+ks.Shares[2].SerializeToHexStr()))
+	signer, _ = stream(&mismatched)
+	_, err = signer.SignRoot(&mismatched, types.QBFTSignatureType, mismatched.SharePubKey)
+	require.Error(t, err)
+	_, err = signer.SignRoot(&mismatched, types.QBFTSignatureType, ks.Shares[2].GetPublicKey().Serialize())
+	require.Error(t, err)
+	require.Equal(t, failures+1, testutil.ToFloat64(metricsShareCheckFailures))
+
+	// as is one encrypted to another operator
+	other := output
+	other.EncryptedShare = ks.SignedOutputObject(testRequestID(1), 2, nil).Data.EncryptedShare
+	stream(&other)
+	require.Equal(t, failures+2, testutil.ToFloat64(metricsShareCheckFailures))
+}
+
+func TestLoadSharesAfterRestart(t *testing.T) {
+	ks := testingutils.Testing4SharesSet()
+	operator := ks.DKGOperators[1]
+	dir := t.TempDir()
+	open := func() (*store.Storage, store.Backend) {
+		db, err := store.OpenBadgerBackend(dir)
+		require.NoError(t, err)
+		storage := store.NewStorage(db)
+		_, err = storage.EnableShareEncryption(store.PasswordKEK([]byte("storage password")))
+		require.NoError(t, err)
+		return storage, db
+	}
+
+	// a ceremony stores the share of the node before it restarts
+	storage, db := open()
+	require.NoError(t, storage.SaveKeyGenOutput(&dkg.KeyGenOutput{
+		Share:           ks.Shares[1],
+		ValidatorPK:     ks.ValidatorPK.Serialize(),
+		OperatorPubKeys: map[types.OperatorID]*bls.PublicKey{1: ks.Shares[1].GetPublicKey()},
+		Threshold:       3,
+	}))
+	require.NoError(t, db.Close())
+
+	// the restarted node signs with it once it's loaded
+	signer := keymanager.NewKeyManager(&network.Network{DomainType: types.PrimusTestnet}, keymanager.NewLocalSigner(operator.SK), operator.EncryptionKey)
+	output := ks.SignedOutputObject(testRequestID(1), 1, nil).Data
+	_, err := signer.SignRoot(output, types.QBFTSignatureType, ks.Shares[1].GetPublicKey().Serialize())
+	require.Error(t, err)
+
+	storage, db = open()
+	defer db.Close()
+	loaded, err := LoadShares(storage, signer)
+	require.NoError(t, err)
+	require.Equal(t, 1, loaded)
+	_, err = signer.SignRoot(output, types.QBFTSignatureType, ks.Shares[1].GetPublicKey().Serialize())
+	require.NoError(t, err)
+}
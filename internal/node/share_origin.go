@@ -0,0 +1,43 @@
+package node
+
+import (
+	"github.com/RockX-SG/frost-dkg-demo/internal/logger"
+	store "github.com/RockX-SG/frost-dkg-demo/internal/storage"
+	"github.com/bloxapp/ssv-spec/dkg"
+)
+
+// ShareOriginNetwork wraps a dkg.Network and, when the node broadcasts its output of a
+// ceremony, records the request and ceremony type its stored share originates from. The share
+// is stored before the output is broadcast. Outputs of reshares carry no deposit signature.
+type ShareOriginNetwork struct {
+	wrappedNetwork
+
+	storage *store.Storage
+	logger  *logger.Logger
+}
+
+func NewShareOriginNetwork(network dkg.Network, storage *store.Storage, logger *logger.Logger) *ShareOriginNetwork {
+	return &ShareOriginNetwork{
+		wrappedNetwork: wrappedNetwork{network},
+		storage:        storage,
+		logger:         logger,
+	}
+}
+
+func (n *ShareOriginNetwork) BroadcastDKGMessage(msg *dkg.SignedMessage) error {
+	if msg.Message.MsgType == dkg.OutputMsgType {
+		output := &dkg.SignedOutput{}
+		if err := output.Decode(msg.Message.Data); err != nil {
+			n.logger.Errorf("ShareOriginNetwork: failed to decode own output: %v", err)
+		} else {
+			ceremony := store.CeremonyKeygen
+			if output.Data.DepositDataSignature == nil {
+				ceremony = store.CeremonyReshare
+			}
+			if err := n.storage.SetShareOrigin(output.Data.ValidatorPubKey, output.Data.RequestID, ceremony); err != nil {
+				n.logger.Errorf("ShareOriginNetwork: failed to record the origin of the share of validator %x: %v", output.Data.ValidatorPubKey, err)
+			}
+		}
+	}
+	return n.Network.BroadcastDKGMessage(msg)
+}
@@ -0,0 +1,87 @@
+package node
+
+import (
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	store "github.com/RockX-SG/frost-dkg-demo/internal/storage"
+	"github.com/bloxapp/ssv-spec/types"
+	"github.com/gin-gonic/gin"
+)
+
+// HandleListShares pages through the validator shares held by the node, ordered by validator
+// public key. Query parameters:
+//
+//	limit       page size, up to 1000
+//	after       the next value of the previous page
+//	committee   comma separated operator IDs the committee includes
+//	from, to    creation time range, RFC3339 or YYYY-MM-DD, to is exclusive
+func (h *ApiHandler) HandleListShares(storage *store.Storage) func(*gin.Context) {
+	return func(c *gin.Context) {
+		filter, err := parseShareFilter(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"message": "invalid query",
+				"error":   err.Error(),
+			})
+			return
+		}
+
+		page, err := storage.ListShares(*filter)
+		if err != nil {
+			h.logger.Errorf("HandleListShares: failed to list shares: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"message": "failed to list shares",
+				"error":   err.Error(),
+			})
+			return
+		}
+		c.JSON(http.StatusOK, page)
+	}
+}
+
+func parseShareFilter(c *gin.Context) (*store.ShareFilter, error) {
+	filter := &store.ShareFilter{}
+	var err error
+
+	if limit := c.Query("limit"); limit != "" {
+		if filter.Limit, err = strconv.Atoi(limit); err != nil || filter.Limit <= 0 {
+			return nil, fmt.Errorf("invalid limit %s", limit)
+		}
+	}
+	if after := c.Query("after"); after != "" {
+		if filter.After, err = hex.DecodeString(strings.TrimPrefix(after, "0x")); err != nil {
+			return nil, fmt.Errorf("invalid after %s", after)
+		}
+	}
+	if committee := c.Query("committee"); committee != "" {
+		for _, id := range strings.Split(committee, ",") {
+			operatorID, err := strconv.ParseUint(strings.TrimSpace(id), 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid operator ID %s in committee", id)
+			}
+			filter.Committee = append(filter.Committee, types.OperatorID(operatorID))
+		}
+	}
+	if filter.From, err = parseTime(c.Query("from")); err != nil {
+		return nil, fmt.Errorf("invalid from: %v", err)
+	}
+	if filter.To, err = parseTime(c.Query("to")); err != nil {
+		return nil, fmt.Errorf("invalid to: %v", err)
+	}
+	return filter, nil
+}
+
+func parseTime(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	return time.Parse("2006-01-02", value)
+}
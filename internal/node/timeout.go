@@ -0,0 +1,38 @@
+package node
+
+import (
+	"fmt"
+
+	"github.com/RockX-SG/frost-dkg-demo/internal/messenger"
+	"github.com/bloxapp/ssv-spec/dkg"
+)
+
+// TimeoutNetwork is a dkg.Network that also streams the timeout of a ceremony, as
+// dkg.Network only streams outputs and blames
+type TimeoutNetwork interface {
+	dkg.Network
+	StreamDKGTimeout(timeout *messenger.TimeoutOutput) error
+}
+
+// wrappedNetwork is embedded by the networks that wrap a dkg.Network, and passes the timeouts
+// and rejections they don't handle themselves on to the wrapped network
+type wrappedNetwork struct {
+	dkg.Network
+}
+
+func (n wrappedNetwork) StreamDKGTimeout(timeout *messenger.TimeoutOutput) error {
+	return streamTimeout(n.Network, timeout)
+}
+
+func (n wrappedNetwork) StreamDKGRejection(rejection *messenger.RejectionOutput) error {
+	return streamRejection(n.Network, rejection)
+}
+
+// streamTimeout streams the timeout through the network, if it can
+func streamTimeout(network dkg.Network, timeout *messenger.TimeoutOutput) error {
+	n, ok := network.(TimeoutNetwork)
+	if !ok {
+		return fmt.Errorf("network %T can't stream timeouts", network)
+	}
+	return n.StreamDKGTimeout(timeout)
+}
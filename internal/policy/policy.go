@@ -0,0 +1,301 @@
+package policy
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/RockX-SG/frost-dkg-demo/internal/network"
+	store "github.com/RockX-SG/frost-dkg-demo/internal/storage"
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/bloxapp/ssv-spec/types"
+	"github.com/ethereum/go-ethereum/common"
+	"gopkg.in/yaml.v3"
+)
+
+// QuotaWindow is the window daily quotas are counted over
+const QuotaWindow = 24 * time.Hour
+
+// Policy is what ceremonies a node accepts to join, as written in a policy file. Empty rules
+// don't restrict anything.
+type Policy struct {
+	// AllowedInitiators are the ethereum addresses allowed to request ceremonies
+	AllowedInitiators []string `yaml:"allowed_initiators"`
+	// AllowedWithdrawalCredentials and DeniedWithdrawalCredentials are hex encoded 32 byte
+	// withdrawal credentials of keygens
+	AllowedWithdrawalCredentials []string `yaml:"allowed_withdrawal_credentials"`
+	DeniedWithdrawalCredentials  []string `yaml:"denied_withdrawal_credentials"`
+	// AllowedWithdrawalAddresses and DeniedWithdrawalAddresses are the execution addresses of
+	// 0x01 and 0x02 withdrawal credentials. With allowed addresses, keygens for BLS (0x00)
+	// credentials are rejected.
+	AllowedWithdrawalAddresses []string `yaml:"allowed_withdrawal_addresses"`
+	DeniedWithdrawalAddresses  []string `yaml:"denied_withdrawal_addresses"`
+	// AllowedOperators are the operators the node runs ceremonies with, including the old
+	// operators of a reshare
+	AllowedOperators []types.OperatorID `yaml:"allowed_operators"`
+	// ClusterSizes are the allowed numbers of operators
+	ClusterSizes []int `yaml:"cluster_sizes"`
+	// BFTThreshold requires the threshold of n operators to be n - (n-1)/3, as SSV clusters use
+	BFTThreshold bool `yaml:"bft_threshold"`
+	// Networks are the networks, by name or hex fork version, keygens are allowed for
+	Networks []string `yaml:"networks"`
+	// MaxConcurrentCeremonies bounds the ceremonies running at once
+	MaxConcurrentCeremonies int `yaml:"max_concurrent_ceremonies"`
+	// DailyQuotaPerInitiator bounds the ceremonies an initiator starts in QuotaWindow
+	DailyQuotaPerInitiator int `yaml:"daily_quota_per_initiator"`
+}
+
+// Load reads a policy file
+func Load(path string) (*Policy, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read policy file %s: %v", path, err)
+	}
+	p := &Policy{}
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(p); err != nil {
+		return nil, fmt.Errorf("failed to parse policy file %s: %v", path, err)
+	}
+	return p, nil
+}
+
+// rules is a policy parsed for evaluation
+type rules struct {
+	initiators         map[common.Address]bool
+	allowedCredentials map[string]bool
+	deniedCredentials  map[string]bool
+	allowedAddresses   map[common.Address]bool
+	deniedAddresses    map[common.Address]bool
+	operators          map[types.OperatorID]bool
+	clusterSizes       map[int]bool
+	forks              map[phase0.Version]string
+}
+
+func (p *Policy) compile() (*rules, error) {
+	r := &rules{
+		operators:    make(map[types.OperatorID]bool),
+		clusterSizes: make(map[int]bool),
+		forks:        make(map[phase0.Version]string),
+	}
+	var err error
+	if r.initiators, err = addresses("allowed initiator", p.AllowedInitiators); err != nil {
+		return nil, err
+	}
+	if r.allowedAddresses, err = addresses("allowed withdrawal address", p.AllowedWithdrawalAddresses); err != nil {
+		return nil, err
+	}
+	if r.deniedAddresses, err = addresses("denied withdrawal address", p.DeniedWithdrawalAddresses); err != nil {
+		return nil, err
+	}
+	if r.allowedCredentials, err = credentials("allowed withdrawal credentials", p.AllowedWithdrawalCredentials); err != nil {
+		return nil, err
+	}
+	if r.deniedCredentials, err = credentials("denied withdrawal credentials", p.DeniedWithdrawalCredentials); err != nil {
+		return nil, err
+	}
+	for _, operatorID := range p.AllowedOperators {
+		r.operators[operatorID] = true
+	}
+	for _, size := range p.ClusterSizes {
+		if size < 1 {
+			return nil, fmt.Errorf("invalid cluster size %d", size)
+		}
+		r.clusterSizes[size] = true
+	}
+	for _, name := range p.Networks {
+		if fork, err := hex.DecodeString(strings.TrimPrefix(name, "0x")); err == nil && len(fork) == 4 {
+			r.forks[phase0.Version(*(*[4]byte)(fork))] = name
+			continue
+		}
+		n, err := network.FromName(name)
+		if err != nil {
+			return nil, err
+		}
+		r.forks[n.ForkVersion] = n.Name
+	}
+	if p.MaxConcurrentCeremonies < 0 || p.DailyQuotaPerInitiator < 0 {
+		return nil, fmt.Errorf("ceremony limits can't be negative")
+	}
+	return r, nil
+}
+
+func addresses(rule string, values []string) (map[common.Address]bool, error) {
+	set := make(map[common.Address]bool)
+	for _, value := range values {
+		if !common.IsHexAddress(value) {
+			return nil, fmt.Errorf("invalid %s %s", rule, value)
+		}
+		set[common.HexToAddress(value)] = true
+	}
+	return set, nil
+}
+
+func credentials(rule string, values []string) (map[string]bool, error) {
+	set := make(map[string]bool)
+	for _, value := range values {
+		b, err := hex.DecodeString(strings.TrimPrefix(value, "0x"))
+		if err != nil || len(b) != 32 {
+			return nil, fmt.Errorf("invalid %s %s", rule, value)
+		}
+		set[string(b)] = true
+	}
+	return set, nil
+}
+
+// Request is a ceremony the node is asked to join
+type Request struct {
+	// Type is store.CeremonyKeygen or store.CeremonyReshare
+	Type         string
+	Initiator    common.Address
+	Operators    []types.OperatorID
+	OldOperators []types.OperatorID
+	Threshold    uint16
+	// WithdrawalCredentials and Fork are only set for keygens
+	WithdrawalCredentials []byte
+	Fork                  phase0.Version
+}
+
+// Rejection is returned for a request the policy doesn't allow, with every rule it breaks
+type Rejection struct {
+	Reasons []string
+}
+
+func (r *Rejection) Error() string {
+	return "rejected by node policy: " + strings.Join(r.Reasons, "; ")
+}
+
+// LimitReached is returned for a request over the concurrent ceremonies limit. Unlike a
+// Rejection it's temporary: the request can be sent again once running ceremonies ended.
+type LimitReached struct {
+	Reason string
+}
+
+func (l *LimitReached) Error() string {
+	return "node policy limit reached: " + l.Reason
+}
+
+// Ceremonies counts the ceremonies the node took part in, for the ceremony limits
+type Ceremonies interface {
+	CountCeremonies(match func(*store.CeremonyRecord) bool) (int, error)
+}
+
+// Engine evaluates requests against a policy
+type Engine struct {
+	rules      *rules
+	policy     *Policy
+	ceremonies Ceremonies
+	now        func() time.Time
+}
+
+// NewEngine checks the policy and returns an engine evaluating requests against it
+func NewEngine(p *Policy, ceremonies Ceremonies) (*Engine, error) {
+	r, err := p.compile()
+	if err != nil {
+		return nil, err
+	}
+	return &Engine{rules: r, policy: p, ceremonies: ceremonies, now: time.Now}, nil
+}
+
+// Evaluate returns a *Rejection if the request breaks the policy, a *LimitReached if it's over
+// the concurrent ceremonies limit, or another error if it can't be evaluated
+func (e *Engine) Evaluate(req *Request) error {
+	reasons := make([]string, 0)
+	reject := func(format string, args ...interface{}) {
+		reasons = append(reasons, fmt.Sprintf(format, args...))
+	}
+
+	if len(e.rules.initiators) > 0 && !e.rules.initiators[req.Initiator] {
+		reject("initiator %s isn't allowed", req.Initiator.Hex())
+	}
+	if len(e.rules.operators) > 0 {
+		for _, operatorID := range append(append([]types.OperatorID{}, req.Operators...), req.OldOperators...) {
+			if !e.rules.operators[operatorID] {
+				reject("operator %d isn't allowed", operatorID)
+			}
+		}
+	}
+	n := len(req.Operators)
+	if len(e.rules.clusterSizes) > 0 && !e.rules.clusterSizes[n] {
+		reject("cluster size %d isn't allowed", n)
+	}
+	if e.policy.BFTThreshold && int(req.Threshold) != n-(n-1)/3 {
+		reject("threshold %d of %d operators isn't %d", req.Threshold, n, n-(n-1)/3)
+	}
+	if req.Type == store.CeremonyKeygen {
+		e.evaluateKeygen(req, reject)
+	}
+	if len(reasons) > 0 {
+		return &Rejection{Reasons: reasons}
+	}
+
+	// the limits are checked last, as they read the ceremony records. A daily quota is a
+	// rejection: it frees up hours later, long after the other operators gave up on the
+	// ceremony, so sending the request again is no use.
+	if e.policy.DailyQuotaPerInitiator > 0 {
+		since := e.now().Add(-QuotaWindow)
+		initiator := req.Initiator.Hex()
+		started, err := e.ceremonies.CountCeremonies(func(r *store.CeremonyRecord) bool {
+			return r.Initiator == initiator && !r.StartedAt.Before(since)
+		})
+		if err != nil {
+			return err
+		}
+		if started >= e.policy.DailyQuotaPerInitiator {
+			return &Rejection{Reasons: []string{fmt.Sprintf("initiator %s already started %d ceremonies in the last 24h", initiator, started)}}
+		}
+	}
+	if e.policy.MaxConcurrentCeremonies > 0 {
+		running, err := e.ceremonies.CountCeremonies(func(r *store.CeremonyRecord) bool {
+			return r.Status == store.CeremonyRunning
+		})
+		if err != nil {
+			return err
+		}
+		if running >= e.policy.MaxConcurrentCeremonies {
+			return &LimitReached{Reason: fmt.Sprintf("%d ceremonies are already running", running)}
+		}
+	}
+	return nil
+}
+
+func (e *Engine) evaluateKeygen(req *Request, reject func(format string, args ...interface{})) {
+	if len(e.rules.forks) > 0 {
+		if _, ok := e.rules.forks[req.Fork]; !ok {
+			reject("network with fork version %s isn't allowed", hex.EncodeToString(req.Fork[:]))
+		}
+	}
+
+	credentials := string(req.WithdrawalCredentials)
+	if len(e.rules.allowedCredentials) > 0 && !e.rules.allowedCredentials[credentials] {
+		reject("withdrawal credentials %x aren't allowed", req.WithdrawalCredentials)
+	}
+	if e.rules.deniedCredentials[credentials] {
+		reject("withdrawal credentials %x are denied", req.WithdrawalCredentials)
+	}
+
+	if len(e.rules.allowedAddresses) == 0 && len(e.rules.deniedAddresses) == 0 {
+		return
+	}
+	address, ok := withdrawalAddress(req.WithdrawalCredentials)
+	switch {
+	case !ok && len(e.rules.allowedAddresses) > 0:
+		reject("withdrawal credentials %x have no withdrawal address", req.WithdrawalCredentials)
+	case !ok:
+	case len(e.rules.allowedAddresses) > 0 && !e.rules.allowedAddresses[address]:
+		reject("withdrawal address %s isn't allowed", address.Hex())
+	case e.rules.deniedAddresses[address]:
+		reject("withdrawal address %s is denied", address.Hex())
+	}
+}
+
+// withdrawalAddress returns the execution address of 0x01 and 0x02 withdrawal credentials
+func withdrawalAddress(credentials []byte) (common.Address, bool) {
+	if len(credentials) != 32 || (credentials[0] != 0x01 && credentials[0] != 0x02) {
+		return common.Address{}, false
+	}
+	return common.BytesToAddress(credentials[12:]), true
+}
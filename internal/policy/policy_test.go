@@ -0,0 +1,210 @@
+package policy
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/RockX-SG/frost-dkg-demo/internal/network"
+	store "github.com/RockX-SG/frost-dkg-demo/internal/storage"
+	"github.com/bloxapp/ssv-spec/dkg"
+	"github.com/bloxapp/ssv-spec/types"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	initiator = common.HexToAddress("0x2d618a45796936b1b7aeb87d01ee70e09254487d")
+	other     = common.HexToAddress("0x00000000000000000000000000000000000000aa")
+)
+
+func credentials01(address common.Address) []byte {
+	credentials := make([]byte, 32)
+	credentials[0] = 0x01
+	copy(credentials[12:], address[:])
+	return credentials
+}
+
+func keygen() *Request {
+	hoodi, _ := network.FromName("hoodi")
+	return &Request{
+		Type:                  store.CeremonyKeygen,
+		Initiator:             initiator,
+		Operators:             []types.OperatorID{1, 2, 3, 4},
+		Threshold:             3,
+		WithdrawalCredentials: credentials01(initiator),
+		Fork:                  hoodi.ForkVersion,
+	}
+}
+
+func newTestStorage(t *testing.T) *store.Storage {
+	s := store.NewStorage(store.NewMemoryBackend())
+	_, err := s.Migrate(false)
+	require.NoError(t, err)
+	return s
+}
+
+func newEngine(t *testing.T, p *Policy) *Engine {
+	t.Helper()
+	engine, err := NewEngine(p, newTestStorage(t))
+	require.NoError(t, err)
+	return engine
+}
+
+func requireRejected(t *testing.T, err error, reasons int) {
+	t.Helper()
+	rejection, ok := err.(*Rejection)
+	require.True(t, ok, "expected a rejection, got %v", err)
+	require.Len(t, rejection.Reasons, reasons, rejection.Reasons)
+}
+
+func TestLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "policy.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(`
+allowed_initiators:
+  - "0x2d618a45796936b1b7aeb87d01ee70e09254487d"
+allowed_operators: [1, 2, 3, 4]
+cluster_sizes: [4, 7]
+bft_threshold: true
+networks: [hoodi, "0x10000910"]
+max_concurrent_ceremonies: 2
+daily_quota_per_initiator: 10
+`), 0600))
+	p, err := Load(path)
+	require.NoError(t, err)
+	require.Equal(t, []types.OperatorID{1, 2, 3, 4}, p.AllowedOperators)
+	require.Equal(t, 10, p.DailyQuotaPerInitiator)
+	_, err = NewEngine(p, newTestStorage(t))
+	require.NoError(t, err)
+
+	require.NoError(t, os.WriteFile(path, []byte("allowed_initiator: []\n"), 0600))
+	_, err = Load(path)
+	require.Error(t, err)
+}
+
+func TestInvalidPolicy(t *testing.T) {
+	for name, p := range map[string]*Policy{
+		"initiator":    {AllowedInitiators: []string{"0x1234"}},
+		"credentials":  {DeniedWithdrawalCredentials: []string{"0x01"}},
+		"address":      {AllowedWithdrawalAddresses: []string{"nope"}},
+		"cluster size": {ClusterSizes: []int{0}},
+		"network":      {Networks: []string{"goerli-ish"}},
+		"limits":       {DailyQuotaPerInitiator: -1},
+	} {
+		_, err := NewEngine(p, newTestStorage(t))
+		require.Error(t, err, name)
+	}
+}
+
+func TestEvaluate(t *testing.T) {
+	require.NoError(t, newEngine(t, &Policy{}).Evaluate(keygen()))
+
+	engine := newEngine(t, &Policy{
+		AllowedInitiators: []string{initiator.Hex()},
+		AllowedOperators:  []types.OperatorID{1, 2, 3, 4, 5, 6, 7},
+		ClusterSizes:      []int{4, 7},
+		BFTThreshold:      true,
+		Networks:          []string{"hoodi"},
+	})
+	require.NoError(t, engine.Evaluate(keygen()))
+
+	req := keygen()
+	req.Initiator = other
+	requireRejected(t, engine.Evaluate(req), 1)
+
+	// every broken rule is reported
+	req = keygen()
+	req.Operators = []types.OperatorID{1, 2, 8}
+	req.Threshold = 2
+	mainnet, _ := network.FromName("mainnet")
+	req.Fork = mainnet.ForkVersion
+	requireRejected(t, engine.Evaluate(req), 4)
+
+	// reshares check the old operators too, and aren't bound to a network
+	reshare := &Request{
+		Type:         store.CeremonyReshare,
+		Initiator:    initiator,
+		Operators:    []types.OperatorID{4, 5, 6, 7},
+		OldOperators: []types.OperatorID{1, 2, 3, 9},
+		Threshold:    3,
+	}
+	requireRejected(t, engine.Evaluate(reshare), 1)
+	reshare.OldOperators = []types.OperatorID{1, 2, 3, 4}
+	require.NoError(t, engine.Evaluate(reshare))
+}
+
+func TestEvaluateWithdrawal(t *testing.T) {
+	engine := newEngine(t, &Policy{
+		AllowedWithdrawalAddresses: []string{initiator.Hex()},
+		DeniedWithdrawalAddresses:  []string{other.Hex()},
+	})
+	require.NoError(t, engine.Evaluate(keygen()))
+
+	req := keygen()
+	req.WithdrawalCredentials = credentials01(other)
+	requireRejected(t, engine.Evaluate(req), 1)
+
+	// BLS credentials have no address to allow
+	req.WithdrawalCredentials = make([]byte, 32)
+	requireRejected(t, engine.Evaluate(req), 1)
+
+	denied := credentials01(other)
+	engine = newEngine(t, &Policy{
+		DeniedWithdrawalAddresses:   []string{other.Hex()},
+		DeniedWithdrawalCredentials: []string{common.Bytes2Hex(denied)},
+	})
+	req.WithdrawalCredentials = make([]byte, 32)
+	require.NoError(t, engine.Evaluate(req))
+	req.WithdrawalCredentials = denied
+	requireRejected(t, engine.Evaluate(req), 2)
+
+	engine = newEngine(t, &Policy{AllowedWithdrawalCredentials: []string{common.Bytes2Hex(credentials01(initiator))}})
+	require.NoError(t, engine.Evaluate(keygen()))
+	requireRejected(t, engine.Evaluate(req), 1)
+}
+
+func TestEvaluateLimits(t *testing.T) {
+	s := newTestStorage(t)
+	engine, err := NewEngine(&Policy{MaxConcurrentCeremonies: 2, DailyQuotaPerInitiator: 3}, s)
+	require.NoError(t, err)
+	now := time.Now()
+	engine.now = func() time.Time { return now }
+
+	record := func(id byte, initiator common.Address, status string, startedAt time.Time) {
+		requestID := dkg.RequestID{}
+		requestID[23] = id
+		require.NoError(t, s.SaveCeremony(&store.CeremonyRecord{
+			RequestID: common.Bytes2Hex(requestID[:]),
+			Type:      store.CeremonyKeygen,
+			Initiator: initiator.Hex(),
+			Status:    store.CeremonyRunning,
+			StartedAt: startedAt,
+		}))
+		if status != store.CeremonyRunning {
+			_, err := s.FinishCeremony(requestID, store.CeremonyOutcome{Status: status}, startedAt)
+			require.NoError(t, err)
+		}
+	}
+
+	record(1, initiator, store.CeremonySucceeded, now.Add(-25*time.Hour))
+	record(2, initiator, store.CeremonySucceeded, now.Add(-time.Hour))
+	record(3, initiator, store.CeremonyRunning, now.Add(-time.Minute))
+	record(4, other, store.CeremonyBlamed, now.Add(-time.Minute))
+	require.NoError(t, engine.Evaluate(keygen()))
+
+	// the quota counts the ceremonies of the initiator in the last 24h
+	record(5, initiator, store.CeremonyTimedOut, now.Add(-2*time.Hour))
+	requireRejected(t, engine.Evaluate(keygen()), 1)
+	req := keygen()
+	req.Initiator = other
+	require.NoError(t, engine.Evaluate(req))
+
+	// the concurrency limit is temporary, the quota isn't
+	record(6, other, store.CeremonyRunning, now)
+	err = engine.Evaluate(req)
+	limit, ok := err.(*LimitReached)
+	require.True(t, ok, "expected the limit to be reached, got %v", err)
+	require.Equal(t, "2 ceremonies are already running", limit.Reason)
+	requireRejected(t, engine.Evaluate(keygen()), 1)
+}
@@ -0,0 +1,39 @@
+package secrets
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+)
+
+// FileSource reads the secret from a file, which must not be accessible by group or others
+type FileSource struct {
+	Path string
+}
+
+func (s *FileSource) Secret() ([]byte, error) {
+	info, err := os.Stat(s.Path)
+	if err != nil {
+		return nil, err
+	}
+	if !info.Mode().IsRegular() {
+		return nil, fmt.Errorf("secret file %s is not a regular file", s.Path)
+	}
+	if perm := info.Mode().Perm(); perm&0077 != 0 {
+		return nil, fmt.Errorf("secret file %s has permissions %#o, it must only be accessible by its owner (chmod 600)", s.Path, perm)
+	}
+
+	data, err := os.ReadFile(s.Path)
+	if err != nil {
+		return nil, err
+	}
+	secret := bytes.TrimRight(data, "\r\n")
+	if len(secret) < len(data) {
+		Zero(data[len(secret):])
+	}
+	return secret, nil
+}
+
+func (s *FileSource) String() string {
+	return "file:" + s.Path
+}
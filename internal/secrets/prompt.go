@@ -0,0 +1,33 @@
+package secrets
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"golang.org/x/term"
+)
+
+// PromptSource asks for the secret on the terminal, without echoing it
+type PromptSource struct {
+	Prompt string
+}
+
+func (s *PromptSource) Secret() ([]byte, error) {
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		return nil, errors.New("can't prompt for a secret, stdin is not a terminal")
+	}
+
+	fmt.Fprint(os.Stderr, s.Prompt)
+	secret, err := term.ReadPassword(fd)
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read secret: %v", err)
+	}
+	return secret, nil
+}
+
+func (s *PromptSource) String() string {
+	return "prompt"
+}
@@ -0,0 +1,71 @@
+package secrets
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Source provides a secret such as a keystore password. The caller owns the returned bytes
+// and should Zero them once the secret is used.
+type Source interface {
+	Secret() ([]byte, error)
+	// String describes the source without revealing the secret
+	String() string
+}
+
+// Parse builds a source from its spec, one of
+//
+//	env:NAME            the environment variable NAME
+//	file:/path          a file only readable by its owner, without the trailing newline
+//	prompt             asked on the terminal
+//	vault:path#field    the field of a secret in a Vault KV store, see VaultSource
+func Parse(spec, prompt string) (Source, error) {
+	kind, arg, _ := strings.Cut(spec, ":")
+	switch kind {
+	case "env":
+		if arg == "" {
+			return nil, fmt.Errorf("secret source %s: missing variable name", spec)
+		}
+		return &EnvSource{Name: arg}, nil
+	case "file":
+		if arg == "" {
+			return nil, fmt.Errorf("secret source %s: missing file path", spec)
+		}
+		return &FileSource{Path: arg}, nil
+	case "prompt":
+		return &PromptSource{Prompt: prompt}, nil
+	case "vault":
+		source, err := NewVaultSourceFromEnv(arg)
+		if err != nil {
+			return nil, err
+		}
+		return source, nil
+	default:
+		return nil, fmt.Errorf("invalid secret source %s: has to be env:NAME, file:PATH, prompt or vault:PATH#FIELD", spec)
+	}
+}
+
+// Zero overwrites a secret in memory
+func Zero(secret []byte) {
+	for i := range secret {
+		secret[i] = 0
+	}
+}
+
+// EnvSource reads the secret from an environment variable
+type EnvSource struct {
+	Name string
+}
+
+func (s *EnvSource) Secret() ([]byte, error) {
+	value, ok := os.LookupEnv(s.Name)
+	if !ok {
+		return nil, fmt.Errorf("environment variable %s is not set", s.Name)
+	}
+	return []byte(value), nil
+}
+
+func (s *EnvSource) String() string {
+	return "env:" + s.Name
+}
@@ -0,0 +1,121 @@
+package secrets
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	t.Setenv("VAULT_ADDR", "http://127.0.0.1:8200")
+
+	for spec, expected := range map[string]string{
+		"env:KEYSTORE_PASSWORD":        "env:KEYSTORE_PASSWORD",
+		"file:/run/secrets/keystore":   "file:/run/secrets/keystore",
+		"prompt":                       "prompt",
+		"vault:secret/data/dkg#passwd": "vault:secret/data/dkg#passwd",
+	} {
+		source, err := Parse(spec, "password: ")
+		require.NoError(t, err, spec)
+		require.Equal(t, expected, source.String())
+	}
+
+	for _, spec := range []string{"", "env:", "file:", "vault:secret/data/dkg", "plain:password"} {
+		_, err := Parse(spec, "password: ")
+		require.Error(t, err, spec)
+	}
+}
+
+func TestEnvSource(t *testing.T) {
+	t.Setenv("TEST_SECRET", "s3cret")
+	secret, err := (&EnvSource{Name: "TEST_SECRET"}).Secret()
+	require.NoError(t, err)
+	require.Equal(t, "s3cret", string(secret))
+
+	_, err = (&EnvSource{Name: "TEST_SECRET_NOT_SET"}).Secret()
+	require.Error(t, err)
+}
+
+func TestFileSource(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "password")
+	require.NoError(t, os.WriteFile(path, []byte("s3cret\n"), 0600))
+
+	secret, err := (&FileSource{Path: path}).Secret()
+	require.NoError(t, err)
+	require.Equal(t, "s3cret", string(secret))
+
+	require.NoError(t, os.Chmod(path, 0644))
+	_, err = (&FileSource{Path: path}).Secret()
+	require.Error(t, err)
+
+	_, err = (&FileSource{Path: filepath.Join(t.TempDir(), "missing")}).Secret()
+	require.Error(t, err)
+}
+
+// vaultStandIn serves secrets like a Vault KV engine, the v2 ones below secret/data/
+func vaultStandIn(t *testing.T, token string) *httptest.Server {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != token {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		switch r.URL.Path {
+		case "/v1/secret/data/dkg/node-1":
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{
+				"data": map[string]interface{}{
+					"data":     map[string]string{"password": "v2-s3cret"},
+					"metadata": map[string]interface{}{"version": 3},
+				},
+			})
+		case "/v1/kv/dkg/node-1":
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{
+				"data": map[string]string{"password": "v1-s3cret"},
+			})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestVaultSource(t *testing.T) {
+	srv := vaultStandIn(t, "root-token")
+	t.Setenv("VAULT_ADDR", srv.URL)
+	t.Setenv("VAULT_TOKEN", "root-token")
+
+	for spec, expected := range map[string]string{
+		"secret/data/dkg/node-1#password": "v2-s3cret",
+		"kv/dkg/node-1#password":          "v1-s3cret",
+	} {
+		source, err := NewVaultSourceFromEnv(spec)
+		require.NoError(t, err)
+		secret, err := source.Secret()
+		require.NoError(t, err, spec)
+		require.Equal(t, expected, string(secret))
+	}
+
+	for _, spec := range []string{"secret/data/dkg/node-1#missing", "secret/data/dkg/node-2#password"} {
+		source, err := NewVaultSourceFromEnv(spec)
+		require.NoError(t, err)
+		_, err = source.Secret()
+		require.Error(t, err, spec)
+	}
+
+	t.Setenv("VAULT_TOKEN", "wrong-token")
+	source, err := NewVaultSourceFromEnv("secret/data/dkg/node-1#password")
+	require.NoError(t, err)
+	_, err = source.Secret()
+	require.Error(t, err)
+}
+
+func TestZero(t *testing.T) {
+	secret := []byte("s3cret")
+	Zero(secret)
+	require.Equal(t, make([]byte, 6), secret)
+}
@@ -0,0 +1,128 @@
+package secrets
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+const vaultTimeout = 10 * time.Second
+
+// VaultSource reads the secret from a field of a secret in a HashiCorp Vault compatible KV store.
+// Path is the api path of the secret below /v1/, e.g. secret/data/dkg/node-1 for a KV v2 engine
+// mounted at secret, or secret/dkg/node-1 for KV v1.
+type VaultSource struct {
+	Addr      string
+	Token     string
+	Namespace string
+	Path      string
+	Field     string
+	client    *http.Client
+}
+
+// NewVaultSourceFromEnv builds a Vault source for a path#field from the standard Vault
+// environment variables VAULT_ADDR, VAULT_TOKEN, VAULT_NAMESPACE and VAULT_CACERT
+func NewVaultSourceFromEnv(pathAndField string) (*VaultSource, error) {
+	path, field, _ := strings.Cut(pathAndField, "#")
+	if path == "" || field == "" {
+		return nil, fmt.Errorf("vault secret %s has to be a path and a field, e.g. secret/data/dkg#password", pathAndField)
+	}
+	addr := os.Getenv("VAULT_ADDR")
+	if addr == "" {
+		return nil, errors.New("VAULT_ADDR is required for vault secrets")
+	}
+
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	if caFile := os.Getenv("VAULT_CACERT"); caFile != "" {
+		pem, err := os.ReadFile(caFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read VAULT_CACERT: %v", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificate found in %s", caFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	return &VaultSource{
+		Addr:      addr,
+		Token:     os.Getenv("VAULT_TOKEN"),
+		Namespace: os.Getenv("VAULT_NAMESPACE"),
+		Path:      path,
+		Field:     field,
+		client: &http.Client{
+			Timeout:   vaultTimeout,
+			Transport: &http.Transport{TLSClientConfig: tlsConfig},
+		},
+	}, nil
+}
+
+func (s *VaultSource) Secret() ([]byte, error) {
+	req, err := http.NewRequest(http.MethodGet, fmt.Sprintf("%s/v1/%s", strings.TrimSuffix(s.Addr, "/"), strings.TrimPrefix(s.Path, "/")), nil)
+	if err != nil {
+		return nil, err
+	}
+	if s.Token != "" {
+		req.Header.Set("X-Vault-Token", s.Token)
+	}
+	if s.Namespace != "" {
+		req.Header.Set("X-Vault-Namespace", s.Namespace)
+	}
+
+	client := s.client
+	if client == nil {
+		client = &http.Client{Timeout: vaultTimeout}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("vault: %v", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	defer Zero(body)
+	if err != nil {
+		return nil, fmt.Errorf("vault: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("vault: reading %s failed with status %d", s.Path, resp.StatusCode)
+	}
+
+	// KV v1 returns the fields in data, KV v2 in data.data
+	secret := struct {
+		Data map[string]json.RawMessage `json:"data"`
+	}{}
+	if err := json.Unmarshal(body, &secret); err != nil {
+		return nil, fmt.Errorf("vault: failed to parse the response: %v", err)
+	}
+	fields := secret.Data
+	if nested, ok := fields["data"]; ok {
+		if _, isField := fields[s.Field]; !isField {
+			fields = make(map[string]json.RawMessage)
+			if err := json.Unmarshal(nested, &fields); err != nil {
+				return nil, fmt.Errorf("vault: failed to parse the response: %v", err)
+			}
+		}
+	}
+
+	raw, ok := fields[s.Field]
+	if !ok {
+		return nil, fmt.Errorf("vault: secret %s has no field %s", s.Path, s.Field)
+	}
+	var value string
+	if err := json.Unmarshal(raw, &value); err != nil {
+		return nil, fmt.Errorf("vault: field %s of %s is not a string", s.Field, s.Path)
+	}
+	return []byte(value), nil
+}
+
+func (s *VaultSource) String() string {
+	return fmt.Sprintf("vault:%s#%s", s.Path, s.Field)
+}
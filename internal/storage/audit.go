@@ -0,0 +1,269 @@
+package storage
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/bloxapp/ssv-spec/dkg"
+	"github.com/bloxapp/ssv-spec/types"
+)
+
+// Directions of audited messages
+const (
+	AuditReceived = "received"
+	AuditSent     = "sent"
+)
+
+// ErrAuditChainBroken is returned when the audit log doesn't verify
+var ErrAuditChainBroken = errors.New("audit log chain is broken")
+
+// AuditEntry records a ceremony message the node received or sent. Entries are numbered from 1
+// and chained: Hash is the SHA-256 of the entry with an empty Hash, which includes the Hash of
+// the previous entry as PrevHash, so changing, removing or reordering entries without
+// rehashing the rest of the log shows. The chain isn't signed: whoever can write the database
+// can rewrite the log from an entry on, which only shows against a head recorded elsewhere.
+type AuditEntry struct {
+	Seq       uint64    `json:"seq"`
+	Timestamp time.Time `json:"timestamp"`
+	// Direction is AuditReceived or AuditSent
+	Direction string           `json:"direction"`
+	RequestID string           `json:"request_id"`
+	Signer    types.OperatorID `json:"signer"`
+	MsgType   string           `json:"msg_type"`
+	Round     string           `json:"round,omitempty"`
+	// MsgHash is the SHA-256 of the encoded signed message
+	MsgHash string `json:"msg_hash"`
+	// Error is why a message couldn't be sent
+	Error    string `json:"error,omitempty"`
+	PrevHash string `json:"prev_hash"`
+	Hash     string `json:"hash"`
+}
+
+// AuditHead is the last entry of the audit log, empty for an empty log
+type AuditHead struct {
+	Seq  uint64 `json:"seq"`
+	Hash string `json:"hash"`
+}
+
+func (e *AuditEntry) hash() (string, error) {
+	unhashed := *e
+	unhashed.Hash = ""
+	encoded, err := json.Marshal(&unhashed)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(encoded)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// AppendAudit numbers the entry, chains it to the last entry and appends it to the audit log
+func (s *Storage) AppendAudit(entry *AuditEntry) error {
+	// appends are serialized so concurrent ones don't chain to the same head
+	s.auditMu.Lock()
+	defer s.auditMu.Unlock()
+
+	return s.db.Update(func(tx Tx) error {
+		head, err := auditHead(tx)
+		if err != nil {
+			return err
+		}
+		entry.Seq = head.Seq + 1
+		entry.Timestamp = entry.Timestamp.UTC()
+		entry.PrevHash = head.Hash
+		if entry.Hash, err = entry.hash(); err != nil {
+			return err
+		}
+
+		value, err := json.Marshal(entry)
+		if err != nil {
+			return err
+		}
+		if err := tx.Set(auditKey(entry.Seq), value); err != nil {
+			return err
+		}
+		if err := tx.Set(auditIndexKey(entry.RequestID, entry.Seq), []byte{}); err != nil {
+			return err
+		}
+		value, err = json.Marshal(&AuditHead{Seq: entry.Seq, Hash: entry.Hash})
+		if err != nil {
+			return err
+		}
+		return tx.Set([]byte(keyAuditHead), value)
+	})
+}
+
+// AuditLog returns the audit entries of a ceremony in the order they were appended, read
+// through the index of the ceremony's entries
+func (s *Storage) AuditLog(requestID dkg.RequestID) ([]*AuditEntry, error) {
+	id := hex.EncodeToString(requestID[:])
+	prefix := auditIndexPrefix(id)
+	entries := make([]*AuditEntry, 0)
+	err := s.db.View(func(tx Tx) error {
+		return tx.Iterate(prefix, nil, func(key, value []byte) (bool, error) {
+			seq, err := strconv.ParseUint(string(key[len(prefix):]), 16, 64)
+			if err != nil {
+				return false, fmt.Errorf("invalid audit index key %s", key)
+			}
+			entry, err := auditEntry(tx, seq)
+			if err != nil {
+				return false, err
+			}
+			if entry == nil || entry.RequestID != id {
+				return false, fmt.Errorf("%w: index of ceremony %s lists entry %d, which isn't in the log", ErrAuditChainBroken, id, seq)
+			}
+			entries = append(entries, entry)
+			return true, nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// VerifyAuditEntries checks that the entries match their hashes and chain to the entries before
+// and after them in the log, and returns the head of the log. It only reads the neighbours of
+// the entries, where VerifyAuditLog reads the whole log. The error wraps ErrAuditChainBroken
+// when the log was tampered with around the entries.
+func (s *Storage) VerifyAuditEntries(entries []*AuditEntry) (*AuditHead, error) {
+	var head *AuditHead
+	err := s.db.View(func(tx Tx) error {
+		var err error
+		if head, err = auditHead(tx); err != nil {
+			return err
+		}
+		for _, entry := range entries {
+			if err := verifyAuditEntry(entry); err != nil {
+				return err
+			}
+			if entry.Seq > head.Seq {
+				return fmt.Errorf("%w: entry %d is after the head, entry %d", ErrAuditChainBroken, entry.Seq, head.Seq)
+			}
+			if entry.Seq > 1 {
+				prev, err := auditEntry(tx, entry.Seq-1)
+				if err != nil {
+					return err
+				}
+				if prev == nil || verifyAuditEntry(prev) != nil || prev.Hash != entry.PrevHash {
+					return fmt.Errorf("%w: entry %d doesn't chain to entry %d", ErrAuditChainBroken, entry.Seq, entry.Seq-1)
+				}
+			} else if entry.PrevHash != "" {
+				return fmt.Errorf("%w: entry %d doesn't chain to entry %d", ErrAuditChainBroken, entry.Seq, entry.Seq-1)
+			}
+			if entry.Seq == head.Seq {
+				if entry.Hash != head.Hash {
+					return fmt.Errorf("%w: entry %d doesn't match the head", ErrAuditChainBroken, entry.Seq)
+				}
+				continue
+			}
+			next, err := auditEntry(tx, entry.Seq+1)
+			if err != nil {
+				return err
+			}
+			if next == nil || next.PrevHash != entry.Hash {
+				return fmt.Errorf("%w: entry %d doesn't chain to entry %d", ErrAuditChainBroken, entry.Seq+1, entry.Seq)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return head, nil
+}
+
+// VerifyAuditLog checks the whole chain, from the first entry to the recorded head, and returns
+// the head. The error wraps ErrAuditChainBroken when the log was tampered with.
+func (s *Storage) VerifyAuditLog() (*AuditHead, error) {
+	var head *AuditHead
+	err := s.db.View(func(tx Tx) error {
+		var err error
+		if head, err = auditHead(tx); err != nil {
+			return err
+		}
+
+		last := &AuditHead{}
+		err = forEachAuditEntry(tx, func(entry *AuditEntry) error {
+			if entry.Seq != last.Seq+1 {
+				return fmt.Errorf("%w: entry %d follows entry %d", ErrAuditChainBroken, entry.Seq, last.Seq)
+			}
+			if entry.PrevHash != last.Hash {
+				return fmt.Errorf("%w: entry %d doesn't chain to entry %d", ErrAuditChainBroken, entry.Seq, last.Seq)
+			}
+			if err := verifyAuditEntry(entry); err != nil {
+				return err
+			}
+			last = &AuditHead{Seq: entry.Seq, Hash: entry.Hash}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		if *last != *head {
+			return fmt.Errorf("%w: the log ends at entry %d but its head is entry %d", ErrAuditChainBroken, last.Seq, head.Seq)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return head, nil
+}
+
+// verifyAuditEntry checks that the entry matches its hash
+func verifyAuditEntry(entry *AuditEntry) error {
+	hash, err := entry.hash()
+	if err != nil {
+		return err
+	}
+	if entry.Hash != hash {
+		return fmt.Errorf("%w: entry %d doesn't match its hash", ErrAuditChainBroken, entry.Seq)
+	}
+	return nil
+}
+
+// auditEntry returns the audit entry with the sequence number, nil if there's none
+func auditEntry(tx Tx, seq uint64) (*AuditEntry, error) {
+	value, err := tx.Get(auditKey(seq))
+	if err == ErrNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	entry := &AuditEntry{}
+	if err := json.Unmarshal(value, entry); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal audit entry %d: %v", seq, err)
+	}
+	return entry, nil
+}
+
+func forEachAuditEntry(tx Tx, fn func(entry *AuditEntry) error) error {
+	return tx.Iterate([]byte(prefixAudit), nil, func(key, value []byte) (bool, error) {
+		entry := &AuditEntry{}
+		if err := json.Unmarshal(value, entry); err != nil {
+			return false, fmt.Errorf("failed to unmarshal audit entry %s: %v", key, err)
+		}
+		return true, fn(entry)
+	})
+}
+
+func auditHead(tx Tx) (*AuditHead, error) {
+	head := &AuditHead{}
+	value, err := tx.Get([]byte(keyAuditHead))
+	if err == ErrNotFound {
+		return head, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(value, head); err != nil {
+		return nil, err
+	}
+	return head, nil
+}
@@ -0,0 +1,154 @@
+package storage
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/bloxapp/ssv-spec/dkg"
+	"github.com/stretchr/testify/require"
+)
+
+func appendTestAudit(t *testing.T, s *Storage, requestID dkg.RequestID, direction string) *AuditEntry {
+	t.Helper()
+	entry := &AuditEntry{
+		Timestamp: time.Now(),
+		Direction: direction,
+		RequestID: hex.EncodeToString(requestID[:]),
+		Signer:    1,
+		MsgType:   "protocol",
+		Round:     "round 1",
+		MsgHash:   hex.EncodeToString(make([]byte, 32)),
+	}
+	require.NoError(t, s.AppendAudit(entry))
+	return entry
+}
+
+func TestAuditLog(t *testing.T) {
+	s := newTestStorage(t)
+	head, err := s.VerifyAuditLog()
+	require.NoError(t, err)
+	require.Equal(t, &AuditHead{}, head)
+
+	first, _ := testCeremony(1, CeremonyKeygen, time.Now())
+	second, _ := testCeremony(2, CeremonyKeygen, time.Now())
+	a := appendTestAudit(t, s, first, AuditReceived)
+	appendTestAudit(t, s, second, AuditReceived)
+	c := appendTestAudit(t, s, first, AuditSent)
+	require.Equal(t, uint64(1), a.Seq)
+	require.Empty(t, a.PrevHash)
+	require.Equal(t, uint64(3), c.Seq)
+
+	entries, err := s.AuditLog(first)
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	require.Equal(t, a.Hash, entries[0].Hash)
+	require.Equal(t, AuditSent, entries[1].Direction)
+	head, err = s.VerifyAuditEntries(entries)
+	require.NoError(t, err)
+	require.Equal(t, &AuditHead{Seq: 3, Hash: c.Hash}, head)
+
+	head, err = s.VerifyAuditLog()
+	require.NoError(t, err)
+	require.Equal(t, &AuditHead{Seq: 3, Hash: c.Hash}, head)
+}
+
+func TestAuditLogTampering(t *testing.T) {
+	tamper := map[string]func(t *testing.T, s *Storage){
+		"changed entry": func(t *testing.T, s *Storage) {
+			require.NoError(t, s.db.Update(func(tx Tx) error {
+				value, err := tx.Get(auditKey(2))
+				require.NoError(t, err)
+				entry := &AuditEntry{}
+				require.NoError(t, json.Unmarshal(value, entry))
+				entry.Direction = AuditSent
+				value, err = json.Marshal(entry)
+				require.NoError(t, err)
+				return tx.Set(auditKey(2), value)
+			}))
+		},
+		"removed entry": func(t *testing.T, s *Storage) {
+			require.NoError(t, s.db.Update(func(tx Tx) error {
+				return tx.Delete(auditKey(2))
+			}))
+		},
+		"truncated log": func(t *testing.T, s *Storage) {
+			require.NoError(t, s.db.Update(func(tx Tx) error {
+				return tx.Delete(auditKey(3))
+			}))
+		},
+		"rehashed entry": func(t *testing.T, s *Storage) {
+			// an entry changed along with its own hash no longer chains to the next one
+			require.NoError(t, s.db.Update(func(tx Tx) error {
+				value, err := tx.Get(auditKey(2))
+				require.NoError(t, err)
+				entry := &AuditEntry{}
+				require.NoError(t, json.Unmarshal(value, entry))
+				entry.Signer = 4
+				entry.Hash, err = entry.hash()
+				require.NoError(t, err)
+				value, err = json.Marshal(entry)
+				require.NoError(t, err)
+				return tx.Set(auditKey(2), value)
+			}))
+		},
+	}
+
+	for name, fn := range tamper {
+		fn := fn
+		t.Run(name, func(t *testing.T) {
+			s := newTestStorage(t)
+			requestID, _ := testCeremony(1, CeremonyKeygen, time.Now())
+			other, _ := testCeremony(2, CeremonyKeygen, time.Now())
+			appendTestAudit(t, s, requestID, AuditReceived)
+			appendTestAudit(t, s, other, AuditReceived)
+			appendTestAudit(t, s, requestID, AuditReceived)
+			_, err := s.VerifyAuditLog()
+			require.NoError(t, err)
+			entries, err := s.AuditLog(requestID)
+			require.NoError(t, err)
+			_, err = s.VerifyAuditEntries(entries)
+			require.NoError(t, err)
+
+			fn(t, s)
+			_, err = s.VerifyAuditLog()
+			require.True(t, errors.Is(err, ErrAuditChainBroken), err)
+			// tampering with the entry of another ceremony shows in the neighbours of the
+			// entries of this one
+			if entries, err = s.AuditLog(requestID); err == nil {
+				_, err = s.VerifyAuditEntries(entries)
+			}
+			require.True(t, errors.Is(err, ErrAuditChainBroken), err)
+		})
+	}
+}
+
+func TestMigrateAuditIndex(t *testing.T) {
+	s := newTestStorage(t)
+	requestID, _ := testCeremony(1, CeremonyKeygen, time.Now())
+	appendTestAudit(t, s, requestID, AuditReceived)
+	appendTestAudit(t, s, requestID, AuditSent)
+	// drop the index, as the entries of databases before the index have none
+	require.NoError(t, s.db.Update(func(tx Tx) error {
+		for seq := uint64(1); seq <= 2; seq++ {
+			if err := tx.Delete(auditIndexKey(hex.EncodeToString(requestID[:]), seq)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}))
+	entries, err := s.AuditLog(requestID)
+	require.NoError(t, err)
+	require.Empty(t, entries)
+
+	require.NoError(t, s.db.Update(func(tx Tx) error {
+		indexed, err := migrateAuditIndex(tx)
+		require.Equal(t, 2, indexed)
+		return err
+	}))
+	entries, err = s.AuditLog(requestID)
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+}
@@ -0,0 +1,73 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+)
+
+// Storage backends
+const (
+	BackendBadger = "badger"
+	BackendSQLite = "sqlite"
+	BackendMemory = "memory"
+)
+
+var (
+	// ErrNotFound is returned by Tx.Get when the key isn't in the database
+	ErrNotFound = errors.New("key not found")
+	// ErrClosed is returned by the transactions of a closed backend
+	ErrClosed = errors.New("database is closed")
+	// ErrLocked is returned when opening a backend whose database another process holds
+	ErrLocked = errors.New("database is in use by another process")
+
+	errReadOnly = errors.New("transaction is read-only")
+)
+
+// Backend is a key-value database the storage keeps its records in, with keys ordered bytewise
+type Backend interface {
+	// View runs fn in a read-only transaction
+	View(fn func(tx Tx) error) error
+	// Update runs fn in a read-write transaction, which is committed if fn returns nil and
+	// discarded otherwise
+	Update(fn func(tx Tx) error) error
+	Close() error
+}
+
+// Tx is a transaction of a Backend. It's only valid until the function it's passed to returns,
+// and can't be used concurrently.
+type Tx interface {
+	// Get returns the value of the key, or ErrNotFound
+	Get(key []byte) ([]byte, error)
+	Set(key, value []byte) error
+	Delete(key []byte) error
+	// Iterate calls fn in key order with the records whose key has the prefix, starting at the
+	// first key not before start when it isn't nil, until fn returns false. Key and value are
+	// only valid until fn returns. fn may set or delete the key it's called with.
+	Iterate(prefix, start []byte, fn func(key, value []byte) (bool, error)) error
+}
+
+// OpenBackend opens the backend of the given kind keeping its data in dataDir
+func OpenBackend(kind, dataDir string) (Backend, error) {
+	switch kind {
+	case BackendBadger:
+		return OpenBadgerBackend(dataDir)
+	case BackendSQLite:
+		return OpenSQLiteBackend(filepath.Join(dataDir, SQLiteFile))
+	case BackendMemory:
+		return NewMemoryBackend(), nil
+	default:
+		return nil, fmt.Errorf("unknown storage backend %q", kind)
+	}
+}
+
+// iterateAfter iterates like Tx.Iterate, but skips the start key itself, to continue a listing
+// after the last key of the previous page
+func iterateAfter(tx Tx, prefix, after []byte, fn func(key, value []byte) (bool, error)) error {
+	return tx.Iterate(prefix, after, func(key, value []byte) (bool, error) {
+		if after != nil && string(key) == string(after) {
+			return true, nil
+		}
+		return fn(key, value)
+	})
+}
@@ -0,0 +1,102 @@
+package storage
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/dgraph-io/badger/v3"
+)
+
+// badgerBackend keeps the records in a badger database directory
+type badgerBackend struct {
+	db *badger.DB
+}
+
+// OpenBadgerBackend opens the badger database in dir, creating it if needed
+func OpenBadgerBackend(dir string) (Backend, error) {
+	db, err := badger.Open(badger.DefaultOptions(dir))
+	if err != nil {
+		// badger doesn't tell its lock error apart from the others
+		if badgerDirLocked(dir) {
+			return nil, fmt.Errorf("%w: %v", ErrLocked, err)
+		}
+		return nil, err
+	}
+	return &badgerBackend{db: db}, nil
+}
+
+// NewBadgerBackend wraps an open badger database
+func NewBadgerBackend(db *badger.DB) Backend {
+	return &badgerBackend{db: db}
+}
+
+func (b *badgerBackend) View(fn func(tx Tx) error) error {
+	if b.db.IsClosed() {
+		return ErrClosed
+	}
+	return b.db.View(func(txn *badger.Txn) error {
+		return fn(&badgerTx{txn: txn})
+	})
+}
+
+func (b *badgerBackend) Update(fn func(tx Tx) error) error {
+	if b.db.IsClosed() {
+		return ErrClosed
+	}
+	return b.db.Update(func(txn *badger.Txn) error {
+		return fn(&badgerTx{txn: txn})
+	})
+}
+
+func (b *badgerBackend) Close() error {
+	return b.db.Close()
+}
+
+type badgerTx struct {
+	txn *badger.Txn
+}
+
+func (t *badgerTx) Get(key []byte) ([]byte, error) {
+	item, err := t.txn.Get(key)
+	if err == badger.ErrKeyNotFound {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return item.ValueCopy(nil)
+}
+
+func (t *badgerTx) Set(key, value []byte) error {
+	return t.txn.Set(key, value)
+}
+
+func (t *badgerTx) Delete(key []byte) error {
+	return t.txn.Delete(key)
+}
+
+func (t *badgerTx) Iterate(prefix, start []byte, fn func(key, value []byte) (bool, error)) error {
+	opts := badger.DefaultIteratorOptions
+	opts.Prefix = prefix
+	it := t.txn.NewIterator(opts)
+	defer it.Close()
+
+	it.Rewind()
+	if start != nil && bytes.Compare(start, prefix) > 0 {
+		it.Seek(start)
+	}
+	for ; it.Valid(); it.Next() {
+		item := it.Item()
+		// copied, as fn may write the key in the transaction
+		key := item.KeyCopy(nil)
+		value, err := item.ValueCopy(nil)
+		if err != nil {
+			return err
+		}
+		next, err := fn(key, value)
+		if err != nil || !next {
+			return err
+		}
+	}
+	return nil
+}
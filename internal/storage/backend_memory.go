@@ -0,0 +1,125 @@
+package storage
+
+import (
+	"bytes"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// memoryBackend keeps the records in memory, for tests and tools. Update transactions are
+// serialized and their writes only applied on commit.
+type memoryBackend struct {
+	mu      sync.RWMutex
+	records map[string][]byte
+	closed  bool
+}
+
+// NewMemoryBackend returns an empty in-memory backend
+func NewMemoryBackend() Backend {
+	return &memoryBackend{records: make(map[string][]byte)}
+}
+
+func (b *memoryBackend) View(fn func(tx Tx) error) error {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	if b.closed {
+		return ErrClosed
+	}
+	return fn(&memoryTx{backend: b})
+}
+
+func (b *memoryBackend) Update(fn func(tx Tx) error) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return ErrClosed
+	}
+	tx := &memoryTx{backend: b, writes: make(map[string][]byte)}
+	if err := fn(tx); err != nil {
+		return err
+	}
+	for key, value := range tx.writes {
+		if value == nil {
+			delete(b.records, key)
+		} else {
+			b.records[key] = value
+		}
+	}
+	return nil
+}
+
+func (b *memoryBackend) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.closed = true
+	b.records = nil
+	return nil
+}
+
+// memoryTx reads through its pending writes, where nil marks a deleted key
+type memoryTx struct {
+	backend *memoryBackend
+	writes  map[string][]byte
+}
+
+func (t *memoryTx) Get(key []byte) ([]byte, error) {
+	value, ok := t.writes[string(key)]
+	if !ok {
+		value, ok = t.backend.records[string(key)]
+	}
+	if !ok || value == nil {
+		return nil, ErrNotFound
+	}
+	return append([]byte{}, value...), nil
+}
+
+func (t *memoryTx) Set(key, value []byte) error {
+	if t.writes == nil {
+		return errReadOnly
+	}
+	t.writes[string(key)] = append([]byte{}, value...)
+	return nil
+}
+
+func (t *memoryTx) Delete(key []byte) error {
+	if t.writes == nil {
+		return errReadOnly
+	}
+	t.writes[string(key)] = nil
+	return nil
+}
+
+func (t *memoryTx) Iterate(prefix, start []byte, fn func(key, value []byte) (bool, error)) error {
+	// the keys are listed up front, so fn can write to the transaction
+	keys := make([]string, 0)
+	for key := range t.backend.records {
+		if _, written := t.writes[key]; !written && strings.HasPrefix(key, string(prefix)) {
+			keys = append(keys, key)
+		}
+	}
+	for key, value := range t.writes {
+		if value != nil && strings.HasPrefix(key, string(prefix)) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		if start != nil && bytes.Compare([]byte(key), start) < 0 {
+			continue
+		}
+		value, err := t.Get([]byte(key))
+		if err == ErrNotFound {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		next, err := fn([]byte(key), value)
+		if err != nil || !next {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,185 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"sync/atomic"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// SQLiteFile is the name of the SQLite database in the data dir
+const SQLiteFile = "dkg_node.db"
+
+// sqliteBatch is how many records an iteration reads at a time
+const sqliteBatch = 256
+
+// sqliteBackend keeps the records in a single SQLite file, in WAL mode so standard tools can
+// read it while the node runs. Writes go through a single connection that takes the write lock
+// when its transaction begins.
+type sqliteBackend struct {
+	reader *sql.DB
+	writer *sql.DB
+	closed atomic.Bool
+}
+
+// OpenSQLiteBackend opens the SQLite database at path, creating it if needed
+func OpenSQLiteBackend(path string) (Backend, error) {
+	dsn := fmt.Sprintf("file:%s?_journal_mode=WAL&_busy_timeout=5000&_synchronous=FULL", path)
+	writer, err := sql.Open("sqlite3", dsn+"&_txlock=immediate")
+	if err != nil {
+		return nil, err
+	}
+	writer.SetMaxOpenConns(1)
+	if _, err := writer.Exec(`CREATE TABLE IF NOT EXISTS records (
+		key BLOB NOT NULL PRIMARY KEY,
+		value BLOB NOT NULL
+	) WITHOUT ROWID`); err != nil {
+		writer.Close()
+		var sqliteErr sqlite3.Error
+		if errors.As(err, &sqliteErr) && (sqliteErr.Code == sqlite3.ErrBusy || sqliteErr.Code == sqlite3.ErrLocked) {
+			return nil, fmt.Errorf("failed to open %s: %w: %v", path, ErrLocked, err)
+		}
+		return nil, fmt.Errorf("failed to open %s: %v", path, err)
+	}
+	reader, err := sql.Open("sqlite3", dsn+"&_txlock=deferred")
+	if err != nil {
+		writer.Close()
+		return nil, err
+	}
+	return &sqliteBackend{reader: reader, writer: writer}, nil
+}
+
+func (b *sqliteBackend) View(fn func(tx Tx) error) error {
+	return b.run(b.reader, false, fn)
+}
+
+func (b *sqliteBackend) Update(fn func(tx Tx) error) error {
+	return b.run(b.writer, true, fn)
+}
+
+func (b *sqliteBackend) run(db *sql.DB, writable bool, fn func(tx Tx) error) error {
+	if b.closed.Load() {
+		return ErrClosed
+	}
+	txn, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	if err := fn(&sqliteTx{txn: txn, writable: writable}); err != nil {
+		txn.Rollback()
+		return err
+	}
+	return txn.Commit()
+}
+
+func (b *sqliteBackend) Close() error {
+	b.closed.Store(true)
+	readerErr := b.reader.Close()
+	if err := b.writer.Close(); err != nil {
+		return err
+	}
+	return readerErr
+}
+
+type sqliteTx struct {
+	txn      *sql.Tx
+	writable bool
+}
+
+func (t *sqliteTx) Get(key []byte) ([]byte, error) {
+	var value []byte
+	err := t.txn.QueryRow(`SELECT value FROM records WHERE key = ?`, key).Scan(&value)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	return value, err
+}
+
+func (t *sqliteTx) Set(key, value []byte) error {
+	if !t.writable {
+		return errReadOnly
+	}
+	if value == nil {
+		value = []byte{}
+	}
+	_, err := t.txn.Exec(`INSERT INTO records (key, value) VALUES (?, ?)
+		ON CONFLICT (key) DO UPDATE SET value = excluded.value`, key, value)
+	return err
+}
+
+func (t *sqliteTx) Delete(key []byte) error {
+	if !t.writable {
+		return errReadOnly
+	}
+	_, err := t.txn.Exec(`DELETE FROM records WHERE key = ?`, key)
+	return err
+}
+
+// Iterate reads the records in batches, so fn runs without an open query and can write
+func (t *sqliteTx) Iterate(prefix, start []byte, fn func(key, value []byte) (bool, error)) error {
+	from := prefix
+	if start != nil && string(start) > string(prefix) {
+		from = start
+	}
+	from = append([]byte{}, from...)
+	end := prefixEnd(prefix)
+	inclusive := true
+
+	for {
+		batch, err := t.batch(from, inclusive, end)
+		if err != nil {
+			return err
+		}
+		for _, record := range batch {
+			next, err := fn(record[0], record[1])
+			if err != nil || !next {
+				return err
+			}
+		}
+		if len(batch) < sqliteBatch {
+			return nil
+		}
+		from, inclusive = batch[len(batch)-1][0], false
+	}
+}
+
+func (t *sqliteTx) batch(from []byte, inclusive bool, end []byte) ([][2][]byte, error) {
+	query := `SELECT key, value FROM records WHERE key > ?`
+	if inclusive {
+		query = `SELECT key, value FROM records WHERE key >= ?`
+	}
+	args := []interface{}{from}
+	if end != nil {
+		query += ` AND key < ?`
+		args = append(args, end)
+	}
+	rows, err := t.txn.Query(query+` ORDER BY key LIMIT ?`, append(args, sqliteBatch)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	batch := make([][2][]byte, 0, sqliteBatch)
+	for rows.Next() {
+		var key, value []byte
+		if err := rows.Scan(&key, &value); err != nil {
+			return nil, err
+		}
+		batch = append(batch, [2][]byte{key, value})
+	}
+	return batch, rows.Err()
+}
+
+// prefixEnd returns the first key after every key with the prefix, nil if there's none
+func prefixEnd(prefix []byte) []byte {
+	end := append([]byte{}, prefix...)
+	for i := len(end) - 1; i >= 0; i-- {
+		if end[i] < 0xff {
+			end[i]++
+			return end[:i+1]
+		}
+	}
+	return nil
+}
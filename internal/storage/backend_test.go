@@ -0,0 +1,285 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/dgraph-io/badger/v3"
+	"github.com/stretchr/testify/require"
+)
+
+// testBackends opens every backend, keeping its data in dir
+var testBackends = []struct {
+	name string
+	open func(t *testing.T, dir string) Backend
+}{
+	{name: BackendBadger, open: func(t *testing.T, dir string) Backend {
+		db, err := badger.Open(badger.DefaultOptions(dir).WithLoggingLevel(badger.ERROR))
+		require.NoError(t, err)
+		return NewBadgerBackend(db)
+	}},
+	{name: BackendSQLite, open: func(t *testing.T, dir string) Backend {
+		db, err := OpenSQLiteBackend(filepath.Join(dir, SQLiteFile))
+		require.NoError(t, err)
+		return db
+	}},
+	{name: BackendMemory, open: func(t *testing.T, dir string) Backend {
+		return NewMemoryBackend()
+	}},
+}
+
+// TestBackendConformance runs the same checks against every backend
+func TestBackendConformance(t *testing.T) {
+	for _, backend := range testBackends {
+		backend := backend
+		t.Run(backend.name, func(t *testing.T) {
+			open := func(t *testing.T) Backend {
+				db := backend.open(t, t.TempDir())
+				t.Cleanup(func() { db.Close() })
+				return db
+			}
+			t.Run("get set delete", func(t *testing.T) { testBackendGetSetDelete(t, open(t)) })
+			t.Run("transactions", func(t *testing.T) { testBackendTransactions(t, open(t)) })
+			t.Run("iterate", func(t *testing.T) { testBackendIterate(t, open(t)) })
+			t.Run("write while iterating", func(t *testing.T) { testBackendWriteWhileIterating(t, open(t)) })
+			t.Run("close", func(t *testing.T) { testBackendClose(t, open(t)) })
+			if backend.name != BackendMemory {
+				t.Run("persistence", func(t *testing.T) {
+					dir := t.TempDir()
+					db := backend.open(t, dir)
+					set(t, db, "keygen/a", "1")
+					require.NoError(t, db.Close())
+					db = backend.open(t, dir)
+					defer db.Close()
+					require.Equal(t, "1", get(t, db, "keygen/a"))
+				})
+			}
+		})
+	}
+}
+
+func set(t *testing.T, db Backend, key, value string) {
+	t.Helper()
+	require.NoError(t, db.Update(func(tx Tx) error {
+		return tx.Set([]byte(key), []byte(value))
+	}))
+}
+
+func get(t *testing.T, db Backend, key string) string {
+	t.Helper()
+	var value []byte
+	require.NoError(t, db.View(func(tx Tx) error {
+		var err error
+		value, err = tx.Get([]byte(key))
+		return err
+	}))
+	return string(value)
+}
+
+func keys(t *testing.T, db Backend, prefix, start string) []string {
+	t.Helper()
+	var startKey []byte
+	if start != "" {
+		startKey = []byte(start)
+	}
+	found := make([]string, 0)
+	require.NoError(t, db.View(func(tx Tx) error {
+		return tx.Iterate([]byte(prefix), startKey, func(key, value []byte) (bool, error) {
+			found = append(found, string(key))
+			return true, nil
+		})
+	}))
+	return found
+}
+
+func testBackendGetSetDelete(t *testing.T, db Backend) {
+	require.NoError(t, db.View(func(tx Tx) error {
+		_, err := tx.Get([]byte("missing"))
+		require.ErrorIs(t, err, ErrNotFound)
+		return nil
+	}))
+
+	set(t, db, "a", "1")
+	require.Equal(t, "1", get(t, db, "a"))
+	set(t, db, "a", "2")
+	require.Equal(t, "2", get(t, db, "a"))
+	set(t, db, "empty", "")
+	require.Equal(t, "", get(t, db, "empty"))
+
+	require.NoError(t, db.Update(func(tx Tx) error {
+		return tx.Delete([]byte("a"))
+	}))
+	require.NoError(t, db.View(func(tx Tx) error {
+		_, err := tx.Get([]byte("a"))
+		require.ErrorIs(t, err, ErrNotFound)
+		return nil
+	}))
+	// deleting a missing key isn't an error
+	require.NoError(t, db.Update(func(tx Tx) error {
+		return tx.Delete([]byte("a"))
+	}))
+}
+
+func testBackendTransactions(t *testing.T, db Backend) {
+	set(t, db, "a", "1")
+
+	// a failed transaction is discarded
+	failed := errors.New("failed")
+	err := db.Update(func(tx Tx) error {
+		require.NoError(t, tx.Set([]byte("a"), []byte("2")))
+		require.NoError(t, tx.Set([]byte("b"), []byte("2")))
+		require.NoError(t, tx.Delete([]byte("a")))
+		return failed
+	})
+	require.ErrorIs(t, err, failed)
+	require.Equal(t, "1", get(t, db, "a"))
+	require.Equal(t, []string{"a"}, keys(t, db, "", ""))
+
+	// a transaction reads its own writes
+	require.NoError(t, db.Update(func(tx Tx) error {
+		require.NoError(t, tx.Set([]byte("b"), []byte("2")))
+		require.NoError(t, tx.Delete([]byte("a")))
+		value, err := tx.Get([]byte("b"))
+		require.NoError(t, err)
+		require.Equal(t, "2", string(value))
+		_, err = tx.Get([]byte("a"))
+		require.ErrorIs(t, err, ErrNotFound)
+		return nil
+	}))
+	require.Equal(t, []string{"b"}, keys(t, db, "", ""))
+
+	// read-only transactions can't write
+	require.Error(t, db.View(func(tx Tx) error {
+		return tx.Set([]byte("c"), []byte("3"))
+	}))
+}
+
+func testBackendIterate(t *testing.T, db Backend) {
+	require.NoError(t, db.Update(func(tx Tx) error {
+		// more records than a batch of the sqlite backend
+		for i := 0; i < 600; i++ {
+			if err := tx.Set([]byte(fmt.Sprintf("keygen/%04d", i)), []byte{byte(i)}); err != nil {
+				return err
+			}
+		}
+		for _, key := range []string{"ceremonies/1", "keygen", "keygen0", "meta/\xff"} {
+			if err := tx.Set([]byte(key), []byte(key)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}))
+
+	found := keys(t, db, "keygen/", "")
+	require.Len(t, found, 600)
+	for i, key := range found {
+		require.Equal(t, fmt.Sprintf("keygen/%04d", i), key)
+	}
+	require.Len(t, keys(t, db, "", ""), 604)
+	require.Equal(t, []string{"meta/\xff"}, keys(t, db, "meta/", ""))
+	require.Empty(t, keys(t, db, "operators/", ""))
+
+	// from a start key, present or not, and before or after the prefix
+	require.Equal(t, []string{"keygen/0598", "keygen/0599"}, keys(t, db, "keygen/", "keygen/0598"))
+	require.Equal(t, []string{"keygen/0599"}, keys(t, db, "keygen/", "keygen/0598x"))
+	require.Len(t, keys(t, db, "keygen/", "a"), 600)
+	require.Empty(t, keys(t, db, "keygen/", "z"))
+
+	// until fn returns false
+	count := 0
+	require.NoError(t, db.View(func(tx Tx) error {
+		return tx.Iterate([]byte("keygen/"), nil, func(key, value []byte) (bool, error) {
+			count++
+			return count < 300, nil
+		})
+	}))
+	require.Equal(t, 300, count)
+
+	// errors of fn are returned
+	failed := errors.New("failed")
+	require.ErrorIs(t, db.View(func(tx Tx) error {
+		return tx.Iterate(nil, nil, func(key, value []byte) (bool, error) {
+			return true, failed
+		})
+	}), failed)
+}
+
+func testBackendWriteWhileIterating(t *testing.T, db Backend) {
+	require.NoError(t, db.Update(func(tx Tx) error {
+		for i := 0; i < 300; i++ {
+			if err := tx.Set([]byte(fmt.Sprintf("operators/%03d", i)), []byte("old")); err != nil {
+				return err
+			}
+		}
+		return nil
+	}))
+
+	// updating and deleting the current key
+	require.NoError(t, db.Update(func(tx Tx) error {
+		i := 0
+		return tx.Iterate([]byte("operators/"), nil, func(key, value []byte) (bool, error) {
+			require.Equal(t, "old", string(value))
+			i++
+			if i%2 == 0 {
+				return true, tx.Delete(key)
+			}
+			return true, tx.Set(key, []byte("new"))
+		})
+	}))
+	found := keys(t, db, "operators/", "")
+	require.Len(t, found, 150)
+	for _, key := range found {
+		require.Equal(t, "new", get(t, db, key))
+	}
+}
+
+func testBackendClose(t *testing.T, db Backend) {
+	set(t, db, "a", "1")
+	require.NoError(t, db.Close())
+	require.ErrorIs(t, db.View(func(tx Tx) error { return nil }), ErrClosed)
+	require.ErrorIs(t, db.Update(func(tx Tx) error { return nil }), ErrClosed)
+}
+
+// TestStorageBackends runs the storage itself over every backend
+func TestStorageBackends(t *testing.T) {
+	for _, backend := range testBackends {
+		backend := backend
+		t.Run(backend.name, func(t *testing.T) {
+			db := backend.open(t, t.TempDir())
+			defer db.Close()
+			s := NewStorage(db)
+
+			report, err := s.Migrate(false)
+			require.NoError(t, err)
+			require.Equal(t, SchemaVersion, report.To)
+			_, err = s.EnableShareEncryption(PasswordKEK([]byte("storage password")))
+			require.NoError(t, err)
+
+			output := testKeyGenOutput(1)
+			require.NoError(t, s.SaveKeyGenOutput(output))
+			stored, err := s.GetKeyGenOutput(output.ValidatorPK)
+			require.NoError(t, err)
+			require.True(t, stored.Share.IsEqual(output.Share))
+			checked, err := s.VerifyShares(1)
+			require.NoError(t, err)
+			require.Equal(t, 1, checked)
+			require.NoError(t, s.Ping())
+		})
+	}
+}
+
+func TestOpenBadgerBackendLocked(t *testing.T) {
+	dir := t.TempDir()
+	db, err := OpenBadgerBackend(dir)
+	require.NoError(t, err)
+	defer db.Close()
+
+	_, err = OpenBadgerBackend(dir)
+	require.ErrorIs(t, err, ErrLocked)
+
+	_, err = OpenBadgerBackend(filepath.Join(dir, "MANIFEST"))
+	require.Error(t, err)
+	require.NotErrorIs(t, err, ErrLocked)
+}
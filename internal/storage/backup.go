@@ -0,0 +1,205 @@
+package storage
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/bloxapp/ssv-spec/types"
+	"github.com/dgraph-io/badger/v3"
+)
+
+// Stats counts the records of the database by namespace
+type Stats struct {
+	KeyGenOutputs int `json:"keygen_outputs"`
+	Operators     int `json:"operators"`
+	Ceremonies    int `json:"ceremonies"`
+	AuditEntries  int `json:"audit_entries"`
+	Total         int `json:"total"`
+}
+
+// Backup writes a full backup of the database to w, as a sequence of length prefixed keys and
+// values in key order, and returns the counts of the records it holds. It reads the database
+// in a single transaction, so it can run while the node is running.
+func (s *Storage) Backup(w io.Writer) (*Stats, error) {
+	out := bufio.NewWriter(w)
+	stats := &Stats{}
+	err := s.db.View(func(tx Tx) error {
+		return tx.Iterate(nil, nil, func(key, value []byte) (bool, error) {
+			for _, field := range [][]byte{key, value} {
+				if err := writeField(out, field); err != nil {
+					return false, err
+				}
+			}
+			stats.count(key)
+			return true, nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return stats, out.Flush()
+}
+
+// Restore loads a backup written by Backup into the database
+func (s *Storage) Restore(r io.Reader) error {
+	in := bufio.NewReader(r)
+	for {
+		records := make([][2][]byte, 0, restoreBatch)
+		for len(records) < restoreBatch {
+			key, err := readField(in)
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return err
+			}
+			value, err := readField(in)
+			if err == io.EOF {
+				return io.ErrUnexpectedEOF
+			}
+			if err != nil {
+				return err
+			}
+			records = append(records, [2][]byte{key, value})
+		}
+		if len(records) == 0 {
+			return nil
+		}
+		if err := s.db.Update(func(tx Tx) error {
+			for _, record := range records {
+				if err := tx.Set(record[0], record[1]); err != nil {
+					return err
+				}
+			}
+			return nil
+		}); err != nil {
+			return err
+		}
+	}
+}
+
+// RestoreBadgerBackup loads a backup in badger's backup format, as written by nodes before the
+// storage backends, into the database. The backup is loaded into a temporary in-memory badger
+// database and its records are copied from there, so it restores into any backend.
+func (s *Storage) RestoreBadgerBackup(r io.Reader) (err error) {
+	db, err := badger.Open(badger.DefaultOptions("").WithInMemory(true).WithLoggingLevel(badger.ERROR))
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	// badger allocates the record lengths it reads, and panics on a corrupted backup
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("not a badger backup: %v", r)
+		}
+	}()
+	if err := db.Load(r, restoreBatch); err != nil {
+		return err
+	}
+
+	records := &bytes.Buffer{}
+	if _, err := NewStorage(NewBadgerBackend(db)).Backup(records); err != nil {
+		return err
+	}
+	return s.Restore(records)
+}
+
+// restoreBatch is how many records Restore writes per transaction
+const restoreBatch = 256
+
+// maxBackupField bounds the length of a key or value read from a backup
+const maxBackupField = 16 << 20
+
+func writeField(w *bufio.Writer, field []byte) error {
+	length := make([]byte, binary.MaxVarintLen64)
+	if _, err := w.Write(length[:binary.PutUvarint(length, uint64(len(field)))]); err != nil {
+		return err
+	}
+	_, err := w.Write(field)
+	return err
+}
+
+func readField(r *bufio.Reader) ([]byte, error) {
+	length, err := binary.ReadUvarint(r)
+	if err != nil {
+		return nil, err
+	}
+	if length > maxBackupField {
+		return nil, fmt.Errorf("backup record of %d bytes is too large", length)
+	}
+	field := make([]byte, length)
+	if _, err := io.ReadFull(r, field); err != nil {
+		if err == io.EOF {
+			return nil, io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	return field, nil
+}
+
+// Stats counts the records of the database
+func (s *Storage) Stats() (*Stats, error) {
+	stats := &Stats{}
+	err := s.db.View(func(tx Tx) error {
+		return tx.Iterate(nil, nil, func(key, value []byte) (bool, error) {
+			stats.count(key)
+			return true, nil
+		})
+	})
+	return stats, err
+}
+
+func (stats *Stats) count(key []byte) {
+	switch {
+	case bytes.HasPrefix(key, []byte(prefixKeyGen)):
+		stats.KeyGenOutputs++
+	case bytes.HasPrefix(key, []byte(prefixOperators)):
+		stats.Operators++
+	case bytes.HasPrefix(key, []byte(prefixCeremonies)):
+		stats.Ceremonies++
+	case bytes.HasPrefix(key, []byte(prefixAudit)):
+		stats.AuditEntries++
+	}
+	stats.Total++
+}
+
+// VerifyShares decodes every keygen output and checks that its share matches the share public
+// key of the operator, of any operator in the committee if operatorID is 0. Share encryption
+// has to be enabled. It returns the number of shares checked.
+func (s *Storage) VerifyShares(operatorID types.OperatorID) (int, error) {
+	if s.shares == nil {
+		return 0, errors.New("share encryption isn't enabled")
+	}
+	checked := 0
+	err := s.db.View(func(tx Tx) error {
+		return forEachKeyGenOutput(tx, func(key []byte, kgo *KeyGenOutput) error {
+			if kgo.EncryptedShare != "" {
+				if err := kgo.decryptShare(s.shares); err != nil {
+					return err
+				}
+			}
+			output, err := kgo.output()
+			if err != nil {
+				return fmt.Errorf("failed to decode keygen output of validator %s: %v", kgo.ValidatorPK, err)
+			}
+
+			sharePK := output.Share.GetPublicKey()
+			matched := false
+			for id, pk := range output.OperatorPubKeys {
+				if (operatorID == 0 || id == operatorID) && pk.IsEqual(sharePK) {
+					matched = true
+				}
+			}
+			if !matched {
+				return fmt.Errorf("share of validator %s doesn't match its share public key", kgo.ValidatorPK)
+			}
+			checked++
+			return nil
+		})
+	})
+	return checked, err
+}
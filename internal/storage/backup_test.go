@@ -0,0 +1,75 @@
+package storage
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/bloxapp/ssv-spec/types"
+	"github.com/dgraph-io/badger/v3"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBackupRestore(t *testing.T) {
+	s := newTestStorage(t)
+	kek := PasswordKEK([]byte("storage password"))
+	_, err := s.EnableShareEncryption(kek)
+	require.NoError(t, err)
+	output := testKeyGenOutput(1)
+	require.NoError(t, s.SaveKeyGenOutput(output))
+	require.NoError(t, s.SaveKeyGenOutput(randomKeyGenOutput(1, 2, 3, 4)))
+
+	backup := &bytes.Buffer{}
+	counts, err := s.Backup(backup)
+	require.NoError(t, err)
+
+	restored := newTestStorage(t)
+	require.NoError(t, restored.Restore(backup))
+	stats, err := restored.Stats()
+	require.NoError(t, err)
+	require.Equal(t, 2, stats.KeyGenOutputs)
+	require.Equal(t, 3, stats.Total)
+	require.Equal(t, counts, stats)
+
+	_, err = restored.VerifyShares(1)
+	require.Error(t, err, "share encryption isn't enabled")
+	_, err = restored.EnableShareEncryption(kek)
+	require.NoError(t, err)
+	checked, err := restored.VerifyShares(1)
+	require.NoError(t, err)
+	require.Equal(t, 2, checked)
+
+	// the share of operator 1 doesn't match the share public key of operator 2
+	_, err = restored.VerifyShares(types.OperatorID(2))
+	require.Error(t, err)
+}
+
+// TestRestoreBadgerBackup restores a backup in badger's backup format, as written by nodes
+// before the storage backends
+func TestRestoreBadgerBackup(t *testing.T) {
+	types.InitBLS()
+	db, err := badger.Open(badger.DefaultOptions("").WithInMemory(true).WithLoggingLevel(badger.ERROR))
+	require.NoError(t, err)
+	defer db.Close()
+	s := NewStorage(NewBadgerBackend(db))
+	require.NoError(t, s.SaveKeyGenOutput(randomKeyGenOutput(1, 2, 3, 4)))
+	require.NoError(t, s.SaveKeyGenOutput(randomKeyGenOutput(1, 2, 3, 4)))
+
+	backup := &bytes.Buffer{}
+	_, err = db.Backup(backup, 0)
+	require.NoError(t, err)
+
+	restored := newTestStorage(t)
+	require.NoError(t, restored.RestoreBadgerBackup(backup))
+	stats, err := restored.Stats()
+	require.NoError(t, err)
+	require.Equal(t, 2, stats.KeyGenOutputs)
+	checked, err := restored.VerifyShares(1)
+	require.Error(t, err, "share encryption isn't enabled")
+	require.Zero(t, checked)
+
+	// a backup in the current format isn't a badger backup
+	current := &bytes.Buffer{}
+	_, err = s.Backup(current)
+	require.NoError(t, err)
+	require.Error(t, newTestStorage(t).RestoreBadgerBackup(current))
+}
@@ -0,0 +1,277 @@
+package storage
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/bloxapp/ssv-spec/dkg"
+	"github.com/bloxapp/ssv-spec/types"
+)
+
+// Ceremony statuses
+const (
+	CeremonyRunning   = "running"
+	CeremonySucceeded = "success"
+	CeremonyBlamed    = "blame"
+	CeremonyTimedOut  = "timeout"
+)
+
+var (
+	ErrCeremonyNotFound = errors.New("ceremony not found")
+	// ErrCeremonyExists is returned when saving a ceremony that was recorded already
+	ErrCeremonyExists = errors.New("ceremony already recorded")
+)
+
+// CeremonyRecord is what the node knows of a ceremony it took part in
+type CeremonyRecord struct {
+	RequestID string `json:"request_id"`
+	// Type is CeremonyKeygen or CeremonyReshare
+	Type string `json:"type"`
+	// Initiator is the ethereum address the request ID was derived from
+	Initiator             string             `json:"initiator"`
+	Operators             []types.OperatorID `json:"operators"`
+	OldOperators          []types.OperatorID `json:"old_operators,omitempty"`
+	Threshold             uint16             `json:"threshold"`
+	WithdrawalCredentials string             `json:"withdrawal_credentials,omitempty"`
+	ForkVersion           string             `json:"fork_version,omitempty"`
+	// ValidatorPK is the validator being reshared, or the one generated once a keygen succeeds
+	ValidatorPK string           `json:"validator_pk,omitempty"`
+	Status      string           `json:"status"`
+	Blame       *CeremonyBlame   `json:"blame,omitempty"`
+	Timeout     *CeremonyTimeout `json:"timeout,omitempty"`
+	StartedAt   time.Time        `json:"started_at"`
+	FinishedAt  *time.Time       `json:"finished_at,omitempty"`
+}
+
+// CeremonyBlame is the blame a ceremony failed with
+type CeremonyBlame struct {
+	Type     string           `json:"type"`
+	Operator types.OperatorID `json:"operator"`
+	// Valid is false when the node found the blame itself to be unfounded
+	Valid bool `json:"valid"`
+}
+
+// CeremonyTimeout is the round a ceremony timed out in
+type CeremonyTimeout struct {
+	Round string `json:"round"`
+	// Missing are the operators whose message of the round never arrived
+	Missing []types.OperatorID `json:"missing"`
+	// Deadline is the deadline that expired, of the round or of the whole ceremony
+	Deadline string `json:"deadline"`
+}
+
+// CeremonyOutcome is how a ceremony finished: its final status, and the validator it generated,
+// the blame it failed with or the round it timed out in
+type CeremonyOutcome struct {
+	Status      string
+	ValidatorPK string
+	Blame       *CeremonyBlame
+	Timeout     *CeremonyTimeout
+}
+
+// finish moves a running ceremony to the outcome. It returns false if the ceremony already
+// finished.
+func (r *CeremonyRecord) finish(outcome CeremonyOutcome, at time.Time) bool {
+	if r.Status != CeremonyRunning {
+		return false
+	}
+	r.Status = outcome.Status
+	finishedAt := at.UTC()
+	r.FinishedAt = &finishedAt
+	if outcome.ValidatorPK != "" {
+		r.ValidatorPK = outcome.ValidatorPK
+	}
+	r.Blame = outcome.Blame
+	r.Timeout = outcome.Timeout
+	return true
+}
+
+// CeremonyPage is a page of ceremonies ordered by request ID. Next continues the listing and
+// is empty on the last page.
+type CeremonyPage struct {
+	Ceremonies []*CeremonyRecord `json:"ceremonies"`
+	Next       string            `json:"next,omitempty"`
+}
+
+// CeremonyFilter selects the ceremonies to list, zero fields don't filter
+type CeremonyFilter struct {
+	Type   string
+	Status string
+	// After continues a listing after this request ID
+	After *dkg.RequestID
+	Limit int
+}
+
+func (f *CeremonyFilter) match(r *CeremonyRecord) bool {
+	return (f.Type == "" || f.Type == r.Type) && (f.Status == "" || f.Status == r.Status)
+}
+
+// SaveCeremony records a ceremony that started, or returns ErrCeremonyExists if it was recorded
+// already, so an init or reshare message processed again doesn't reset its record
+func (s *Storage) SaveCeremony(record *CeremonyRecord) error {
+	requestID, err := ParseRequestID(record.RequestID)
+	if err != nil {
+		return err
+	}
+	if record.Status != CeremonyRunning {
+		return fmt.Errorf("ceremony %s is %s, only running ceremonies are saved", record.RequestID, record.Status)
+	}
+	key := ceremonyKey(requestID)
+	return s.db.Update(func(tx Tx) error {
+		if _, err := tx.Get(key); err != ErrNotFound {
+			if err != nil {
+				return err
+			}
+			return ErrCeremonyExists
+		}
+		return setCeremony(tx, key, record)
+	})
+}
+
+// GetCeremony returns the record of a ceremony, or ErrCeremonyNotFound
+func (s *Storage) GetCeremony(requestID dkg.RequestID) (*CeremonyRecord, error) {
+	var record *CeremonyRecord
+	err := s.db.View(func(tx Tx) error {
+		var err error
+		record, err = getCeremony(tx, ceremonyKey(requestID))
+		return err
+	})
+	return record, err
+}
+
+// FinishCeremony moves a running ceremony to its outcome. It returns false if the ceremony
+// already finished.
+func (s *Storage) FinishCeremony(requestID dkg.RequestID, outcome CeremonyOutcome, at time.Time) (bool, error) {
+	switch outcome.Status {
+	case CeremonySucceeded, CeremonyBlamed, CeremonyTimedOut:
+	default:
+		return false, fmt.Errorf("%q isn't a final ceremony status", outcome.Status)
+	}
+	key := ceremonyKey(requestID)
+	finished := false
+	err := s.db.Update(func(tx Tx) error {
+		record, err := getCeremony(tx, key)
+		if err != nil {
+			return err
+		}
+		if finished = record.finish(outcome, at); !finished {
+			return nil
+		}
+		return setCeremony(tx, key, record)
+	})
+	return finished, err
+}
+
+// TimeoutCeremonies finishes the ceremonies still running that started before startedBefore
+// as timed out, and returns how many it finished
+func (s *Storage) TimeoutCeremonies(startedBefore time.Time) (int, error) {
+	timedOut := 0
+	now := time.Now()
+	err := s.db.Update(func(tx Tx) error {
+		return forEachCeremony(tx, nil, func(key []byte, record *CeremonyRecord) (bool, error) {
+			if record.StartedAt.Before(startedBefore) && record.finish(CeremonyOutcome{Status: CeremonyTimedOut}, now) {
+				timedOut++
+				return true, setCeremony(tx, key, record)
+			}
+			return true, nil
+		})
+	})
+	return timedOut, err
+}
+
+// CountCeremonies returns how many ceremonies match fn
+func (s *Storage) CountCeremonies(match func(*CeremonyRecord) bool) (int, error) {
+	count := 0
+	err := s.db.View(func(tx Tx) error {
+		return forEachCeremony(tx, nil, func(key []byte, record *CeremonyRecord) (bool, error) {
+			if match(record) {
+				count++
+			}
+			return true, nil
+		})
+	})
+	return count, err
+}
+
+// ListCeremonies returns a page of the ceremonies matching the filter
+func (s *Storage) ListCeremonies(filter CeremonyFilter) (*CeremonyPage, error) {
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = DefaultPageLimit
+	}
+	if limit > MaxPageLimit {
+		limit = MaxPageLimit
+	}
+
+	var after []byte
+	if filter.After != nil {
+		after = ceremonyKey(*filter.After)
+	}
+	page := &CeremonyPage{Ceremonies: make([]*CeremonyRecord, 0)}
+	err := s.db.View(func(tx Tx) error {
+		return forEachCeremony(tx, after, func(key []byte, record *CeremonyRecord) (bool, error) {
+			if !filter.match(record) {
+				return true, nil
+			}
+			if len(page.Ceremonies) == limit {
+				page.Next = page.Ceremonies[limit-1].RequestID
+				return false, nil
+			}
+			page.Ceremonies = append(page.Ceremonies, record)
+			return true, nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return page, nil
+}
+
+// forEachCeremony calls fn with the ceremonies after the key, until fn returns false
+func forEachCeremony(tx Tx, after []byte, fn func(key []byte, record *CeremonyRecord) (bool, error)) error {
+	return iterateAfter(tx, []byte(prefixCeremonies), after, func(key, value []byte) (bool, error) {
+		record := &CeremonyRecord{}
+		if err := json.Unmarshal(value, record); err != nil {
+			return false, fmt.Errorf("failed to unmarshal ceremony %s: %v", key, err)
+		}
+		return fn(key, record)
+	})
+}
+
+func getCeremony(tx Tx, key []byte) (*CeremonyRecord, error) {
+	value, err := tx.Get(key)
+	if err == ErrNotFound {
+		return nil, ErrCeremonyNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	record := &CeremonyRecord{}
+	if err := json.Unmarshal(value, record); err != nil {
+		return nil, err
+	}
+	return record, nil
+}
+
+func setCeremony(tx Tx, key []byte, record *CeremonyRecord) error {
+	value, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	return tx.Set(key, value)
+}
+
+// ParseRequestID parses a hex encoded request ID, with or without 0x prefix
+func ParseRequestID(requestID string) (dkg.RequestID, error) {
+	id := dkg.RequestID{}
+	b, err := hex.DecodeString(strings.TrimPrefix(requestID, "0x"))
+	if err != nil || len(b) != len(id) {
+		return id, fmt.Errorf("invalid request ID %s", requestID)
+	}
+	copy(id[:], b)
+	return id, nil
+}
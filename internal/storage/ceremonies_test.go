@@ -0,0 +1,90 @@
+package storage
+
+import (
+	"encoding/hex"
+	"testing"
+	"time"
+
+	"github.com/bloxapp/ssv-spec/dkg"
+	"github.com/bloxapp/ssv-spec/types"
+	"github.com/stretchr/testify/require"
+)
+
+func testCeremony(index byte, ceremonyType string, startedAt time.Time) (dkg.RequestID, *CeremonyRecord) {
+	requestID := dkg.RequestID{}
+	requestID[len(requestID)-1] = index
+	return requestID, &CeremonyRecord{
+		RequestID: hex.EncodeToString(requestID[:]),
+		Type:      ceremonyType,
+		Operators: []types.OperatorID{1, 2, 3, 4},
+		Threshold: 3,
+		Status:    CeremonyRunning,
+		StartedAt: startedAt,
+	}
+}
+
+func TestCeremonyRecords(t *testing.T) {
+	s := newTestStorage(t)
+
+	_, err := s.GetCeremony(dkg.RequestID{})
+	require.ErrorIs(t, err, ErrCeremonyNotFound)
+
+	now := time.Now()
+	keygenID, keygen := testCeremony(1, CeremonyKeygen, now)
+	reshareID, reshare := testCeremony(2, CeremonyReshare, now.Add(-2*time.Hour))
+	staleID, stale := testCeremony(3, CeremonyKeygen, now.Add(-2*time.Hour))
+	for _, record := range []*CeremonyRecord{keygen, reshare, stale} {
+		require.NoError(t, s.SaveCeremony(record))
+	}
+
+	// a ceremony is only recorded and finished once
+	_, replayed := testCeremony(2, CeremonyReshare, now)
+	require.ErrorIs(t, s.SaveCeremony(replayed), ErrCeremonyExists)
+	finished, err := s.FinishCeremony(reshareID, CeremonyOutcome{Status: CeremonySucceeded}, now)
+	require.NoError(t, err)
+	require.True(t, finished)
+	finished, err = s.FinishCeremony(reshareID, CeremonyOutcome{Status: CeremonyBlamed, Blame: &CeremonyBlame{Operator: 2}}, now)
+	require.NoError(t, err)
+	require.False(t, finished)
+	require.ErrorIs(t, s.SaveCeremony(replayed), ErrCeremonyExists)
+
+	_, err = s.FinishCeremony(keygenID, CeremonyOutcome{Status: CeremonyRunning}, now)
+	require.Error(t, err)
+	_, err = s.FinishCeremony(dkg.RequestID{}, CeremonyOutcome{Status: CeremonySucceeded}, now)
+	require.ErrorIs(t, err, ErrCeremonyNotFound)
+
+	timedOut, err := s.TimeoutCeremonies(now.Add(-time.Hour))
+	require.NoError(t, err)
+	require.Equal(t, 1, timedOut)
+
+	stored, err := s.GetCeremony(staleID)
+	require.NoError(t, err)
+	require.Equal(t, CeremonyTimedOut, stored.Status)
+	require.NotNil(t, stored.FinishedAt)
+	stored, err = s.GetCeremony(reshareID)
+	require.NoError(t, err)
+	require.Equal(t, CeremonySucceeded, stored.Status)
+	require.Nil(t, stored.Blame)
+	require.Equal(t, reshare.StartedAt.Unix(), stored.StartedAt.Unix())
+	stored, err = s.GetCeremony(keygenID)
+	require.NoError(t, err)
+	require.Equal(t, CeremonyRunning, stored.Status)
+	require.Equal(t, []types.OperatorID{1, 2, 3, 4}, stored.Operators)
+
+	page, err := s.ListCeremonies(CeremonyFilter{Type: CeremonyKeygen, Limit: 1})
+	require.NoError(t, err)
+	require.Len(t, page.Ceremonies, 1)
+	require.Equal(t, keygen.RequestID, page.Ceremonies[0].RequestID)
+	require.Equal(t, keygen.RequestID, page.Next)
+
+	page, err = s.ListCeremonies(CeremonyFilter{Type: CeremonyKeygen, After: &keygenID})
+	require.NoError(t, err)
+	require.Len(t, page.Ceremonies, 1)
+	require.Equal(t, stale.RequestID, page.Ceremonies[0].RequestID)
+	require.Empty(t, page.Next)
+
+	page, err = s.ListCeremonies(CeremonyFilter{Status: CeremonySucceeded})
+	require.NoError(t, err)
+	require.Len(t, page.Ceremonies, 1)
+	require.Equal(t, reshare.RequestID, page.Ceremonies[0].RequestID)
+}
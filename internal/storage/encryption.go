@@ -0,0 +1,194 @@
+package storage
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"golang.org/x/crypto/hkdf"
+	"golang.org/x/crypto/scrypt"
+)
+
+const (
+	// KEKPassword derives the key encryption key from a storage password
+	KEKPassword = "password"
+	// KEKKeystore derives the key encryption key from the operator's keystore key
+	KEKKeystore = "keystore"
+
+	keyLen   = 32
+	hkdfInfo = "frost-dkg-node share encryption"
+)
+
+// KEK is the secret the key encryption key, which wraps the data key shares are encrypted
+// with, is derived from. Zero it once the storage is opened.
+type KEK struct {
+	kind   string
+	secret []byte
+}
+
+// PasswordKEK derives the key encryption key from a storage password with scrypt
+func PasswordKEK(password []byte) *KEK {
+	return &KEK{kind: KEKPassword, secret: append([]byte{}, password...)}
+}
+
+// KeystoreKEK derives the key encryption key from the operator's ethereum key with HKDF
+func KeystoreKEK(key *ecdsa.PrivateKey) *KEK {
+	return &KEK{kind: KEKKeystore, secret: crypto.FromECDSA(key)}
+}
+
+// Zero overwrites the secret in memory
+func (k *KEK) Zero() {
+	for i := range k.secret {
+		k.secret[i] = 0
+	}
+}
+
+func (k *KEK) derive(salt []byte) ([]byte, error) {
+	switch k.kind {
+	case KEKPassword:
+		return scrypt.Key(k.secret, salt, 1<<15, 8, 1, keyLen)
+	case KEKKeystore:
+		key := make([]byte, keyLen)
+		if _, err := io.ReadFull(hkdf.New(sha256.New, k.secret, salt, []byte(hkdfInfo)), key); err != nil {
+			return nil, err
+		}
+		return key, nil
+	default:
+		return nil, fmt.Errorf("unknown key encryption key %s", k.kind)
+	}
+}
+
+// dataKeyRecord is the data key as stored in the database, wrapped with the key encryption key
+type dataKeyRecord struct {
+	KEK        string `json:"kek"`
+	Salt       string `json:"salt"`
+	WrappedKey string `json:"wrapped_key"`
+}
+
+func newDataKeyRecord(kek *KEK, dataKey []byte) (*dataKeyRecord, error) {
+	salt, err := randomBytes(keyLen)
+	if err != nil {
+		return nil, err
+	}
+	wrappingKey, err := kek.derive(salt)
+	if err != nil {
+		return nil, err
+	}
+	wrapped, err := seal(wrappingKey, dataKey, []byte(metaDataKey))
+	if err != nil {
+		return nil, err
+	}
+	return &dataKeyRecord{
+		KEK:        kek.kind,
+		Salt:       hex.EncodeToString(salt),
+		WrappedKey: hex.EncodeToString(wrapped),
+	}, nil
+}
+
+func (r *dataKeyRecord) unwrap(kek *KEK) ([]byte, error) {
+	if r.KEK != kek.kind {
+		return nil, fmt.Errorf("shares are encrypted with a key derived from the %s, but the node is configured to use the %s", describeKEK(r.KEK), describeKEK(kek.kind))
+	}
+	salt, err := hex.DecodeString(r.Salt)
+	if err != nil {
+		return nil, err
+	}
+	wrapped, err := hex.DecodeString(r.WrappedKey)
+	if err != nil {
+		return nil, err
+	}
+	wrappingKey, err := kek.derive(salt)
+	if err != nil {
+		return nil, err
+	}
+	dataKey, err := open(wrappingKey, wrapped, []byte(metaDataKey))
+	if err != nil {
+		return nil, fmt.Errorf("failed to unwrap the share data key, the %s is wrong", describeKEK(kek.kind))
+	}
+	return dataKey, nil
+}
+
+func (r *dataKeyRecord) encode() ([]byte, error) {
+	return json.Marshal(r)
+}
+
+func describeKEK(kind string) string {
+	if kind == KEKKeystore {
+		return "operator keystore"
+	}
+	return "storage password"
+}
+
+// shareCipher encrypts shares with the data key, bound to the validator public key
+type shareCipher struct {
+	dataKey []byte
+}
+
+func (c *shareCipher) encrypt(validatorPK string, share []byte) (string, error) {
+	sealed, err := seal(c.dataKey, share, []byte(validatorPK))
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(sealed), nil
+}
+
+func (c *shareCipher) decrypt(validatorPK string, encrypted string) ([]byte, error) {
+	sealed, err := hex.DecodeString(encrypted)
+	if err != nil {
+		return nil, err
+	}
+	share, err := open(c.dataKey, sealed, []byte(validatorPK))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt share of validator %s", validatorPK)
+	}
+	return share, nil
+}
+
+// seal encrypts with AES-256-GCM, prefixing the nonce to the ciphertext
+func seal(key, plaintext, additionalData []byte) ([]byte, error) {
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce, err := randomBytes(aead.NonceSize())
+	if err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, plaintext, additionalData), nil
+}
+
+func open(key, sealed, additionalData []byte) ([]byte, error) {
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < aead.NonceSize() {
+		return nil, errors.New("ciphertext is too short")
+	}
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	return aead.Open(nil, nonce, ciphertext, additionalData)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func randomBytes(n int) ([]byte, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
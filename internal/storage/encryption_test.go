@@ -0,0 +1,117 @@
+package storage
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/bloxapp/ssv-spec/dkg"
+	"github.com/bloxapp/ssv-spec/types"
+	"github.com/bloxapp/ssv-spec/types/testingutils"
+	"github.com/herumi/bls-eth-go-binary/bls"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestStorage(t *testing.T) *Storage {
+	t.Helper()
+	types.InitBLS()
+	db := NewMemoryBackend()
+	t.Cleanup(func() { db.Close() })
+	return NewStorage(db)
+}
+
+func testKeyGenOutput(id types.OperatorID) *dkg.KeyGenOutput {
+	ks := testingutils.Testing4SharesSet()
+	return &dkg.KeyGenOutput{
+		Share:           ks.Shares[id],
+		ValidatorPK:     ks.ValidatorPK.Serialize(),
+		OperatorPubKeys: map[types.OperatorID]*bls.PublicKey{id: ks.Shares[id].GetPublicKey()},
+		Threshold:       3,
+	}
+}
+
+// rawKeyGenOutput returns the keygen output as stored in the database
+func rawKeyGenOutput(t *testing.T, s *Storage, pk []byte) []byte {
+	t.Helper()
+	var value []byte
+	require.NoError(t, s.db.View(func(tx Tx) error {
+		var err error
+		value, err = tx.Get(keyGenKey(pk))
+		return err
+	}))
+	return value
+}
+
+func TestShareEncryptionMigratesPlaintext(t *testing.T) {
+	s := newTestStorage(t)
+	output := testKeyGenOutput(1)
+	plaintextShare := []byte(output.Share.SerializeToHexStr())
+
+	// a database written before shares were encrypted
+	require.NoError(t, s.SaveKeyGenOutput(output))
+	require.True(t, bytes.Contains(rawKeyGenOutput(t, s, output.ValidatorPK), plaintextShare))
+
+	migrated, err := s.EnableShareEncryption(PasswordKEK([]byte("storage password")))
+	require.NoError(t, err)
+	require.Equal(t, 1, migrated)
+	require.False(t, bytes.Contains(rawKeyGenOutput(t, s, output.ValidatorPK), plaintextShare))
+
+	stored, err := s.GetKeyGenOutput(output.ValidatorPK)
+	require.NoError(t, err)
+	require.True(t, stored.Share.IsEqual(output.Share))
+
+	// enabling again doesn't migrate anything
+	migrated, err = s.EnableShareEncryption(PasswordKEK([]byte("storage password")))
+	require.NoError(t, err)
+	require.Equal(t, 0, migrated)
+}
+
+func TestShareEncryptionWrongKEK(t *testing.T) {
+	s := newTestStorage(t)
+	_, err := s.EnableShareEncryption(PasswordKEK([]byte("storage password")))
+	require.NoError(t, err)
+	require.NoError(t, s.SaveKeyGenOutput(testKeyGenOutput(1)))
+
+	reopened := NewStorage(s.db)
+	_, err = reopened.EnableShareEncryption(PasswordKEK([]byte("wrong password")))
+	require.Error(t, err)
+
+	_, err = reopened.EnableShareEncryption(KeystoreKEK(testingutils.Testing4SharesSet().DKGOperators[1].SK))
+	require.Error(t, err)
+
+	_, err = reopened.GetKeyGenOutput(testKeyGenOutput(1).ValidatorPK)
+	require.Error(t, err)
+}
+
+func TestShareEncryptionRotation(t *testing.T) {
+	s := newTestStorage(t)
+	keystoreKEK := KeystoreKEK(testingutils.Testing4SharesSet().DKGOperators[1].SK)
+	_, err := s.EnableShareEncryption(keystoreKEK)
+	require.NoError(t, err)
+	output := testKeyGenOutput(1)
+	require.NoError(t, s.SaveKeyGenOutput(output))
+	before := rawKeyGenOutput(t, s, output.ValidatorPK)
+
+	// a new key encryption key rewraps the data key only
+	passwordKEK := PasswordKEK([]byte("new storage password"))
+	require.NoError(t, s.RotateShareKEK(passwordKEK))
+	require.Equal(t, before, rawKeyGenOutput(t, s, output.ValidatorPK))
+
+	_, err = NewStorage(s.db).EnableShareEncryption(keystoreKEK)
+	require.Error(t, err)
+	reopened := NewStorage(s.db)
+	_, err = reopened.EnableShareEncryption(passwordKEK)
+	require.NoError(t, err)
+
+	// a new data key re-encrypts the shares
+	rotated, err := reopened.RotateShareDataKey(passwordKEK)
+	require.NoError(t, err)
+	require.Equal(t, 1, rotated)
+	require.NotEqual(t, before, rawKeyGenOutput(t, s, output.ValidatorPK))
+
+	reopened = NewStorage(s.db)
+	_, err = reopened.EnableShareEncryption(passwordKEK)
+	require.NoError(t, err)
+	stored, err := reopened.GetKeyGenOutput(output.ValidatorPK)
+	require.NoError(t, err)
+	require.True(t, stored.Share.IsEqual(output.Share))
+}
@@ -16,6 +16,7 @@ import (
 
 	"github.com/bloxapp/ssv-spec/dkg"
 	"github.com/bloxapp/ssv-spec/types"
+	"github.com/ethereum/go-ethereum/common"
 )
 
 func FetchOperatorByID(operatorID types.OperatorID) (*dkg.Operator, error) {
@@ -31,14 +32,12 @@ func FetchOperatorByID(operatorID types.OperatorID) (*dkg.Operator, error) {
 
 	publicKey, err := ParsePublicKeyFromBase64(operator.PublicKey)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("registry returned an invalid public key for operator %d: %v", operatorID, err)
 	}
 
-	fmt.Println("owner", operator.Owner)
-
 	return &dkg.Operator{
 		OperatorID:       operatorID,
-		ETHAddress:       ethAddressFromHex(operator.Owner[2:]),
+		ETHAddress:       common.HexToAddress(operator.Owner),
 		EncryptionPubKey: publicKey,
 	}, nil
 }
@@ -49,18 +48,103 @@ type operatorResponse struct {
 	PublicKey string `json:"public_key"`
 }
 
+// validate checks that the registry returned the requested operator with an owner and a key
+func (o *operatorResponse) validate(operatorID types.OperatorID) error {
+	if types.OperatorID(o.ID) != operatorID {
+		return fmt.Errorf("registry returned operator %d for operator %d", o.ID, operatorID)
+	}
+	if !common.IsHexAddress(o.Owner) || common.HexToAddress(o.Owner) == (common.Address{}) {
+		return fmt.Errorf("registry returned an invalid owner address %q for operator %d", o.Owner, operatorID)
+	}
+	if o.PublicKey == "" {
+		return fmt.Errorf("registry returned no public key for operator %d", operatorID)
+	}
+	return nil
+}
+
 func GetOperatorFromRegistryByID(operatorID types.OperatorID) (*operatorResponse, error) {
 	var operator = new(operatorResponse)
-	respBody, err := getResponse(fmt.Sprintf("https://api.ssv.network/api/v3/prater/operators/%d", operatorID))
+
+	start := time.Now()
+	respBody, err := getResponse(fmt.Sprintf("%s/operators/%d", RegistryURL, operatorID))
+	observeRegistryFetch(start, err)
 	if err != nil {
 		return nil, err
 	}
 	if err := json.Unmarshal(respBody, operator); err != nil {
+		return nil, fmt.Errorf("failed to parse registry response for operator %d: %v", operatorID, err)
+	}
+	if err := operator.validate(operatorID); err != nil {
 		return nil, err
 	}
 	return operator, nil
 }
 
+type ownedOperatorsResponse struct {
+	Operators []*operatorResponse `json:"operators"`
+}
+
+// FindOperatorIDsByOwner returns the IDs of the operators registered by the given owner address
+func FindOperatorIDsByOwner(owner common.Address) ([]types.OperatorID, error) {
+	ids := make([]types.OperatorID, 0)
+	if isUsingHardcodedOperators() {
+		for operatorID, operator := range DKGOperators {
+			if operator.ETHAddress == owner {
+				ids = append(ids, operatorID)
+			}
+		}
+		return ids, nil
+	}
+
+	start := time.Now()
+	respBody, err := getResponse(fmt.Sprintf("%s/operators/owned_by/%s?page=1&perPage=100", RegistryURL, owner.Hex()))
+	observeRegistryFetch(start, err)
+	if err != nil {
+		return nil, err
+	}
+	owned := &ownedOperatorsResponse{}
+	if err := json.Unmarshal(respBody, owned); err != nil {
+		return nil, err
+	}
+	for _, operator := range owned.Operators {
+		ids = append(ids, types.OperatorID(operator.ID))
+	}
+	return ids, nil
+}
+
+// HardcodedEncryptionKey returns the RSA key of a hardcoded test operator, when the node uses them
+func HardcodedEncryptionKey(operatorID types.OperatorID) (*rsa.PrivateKey, bool) {
+	if !isUsingHardcodedOperators() {
+		return nil, false
+	}
+	operator, ok := DKGOperators[operatorID]
+	if !ok {
+		return nil, false
+	}
+	return operator.EncryptionKey, true
+}
+
+// CheckRegistryReachable fetches the given operator from the registry with a short timeout.
+// It always succeeds when the node uses hardcoded operators.
+func CheckRegistryReachable(operatorID types.OperatorID) error {
+	if isUsingHardcodedOperators() {
+		return nil
+	}
+
+	cl := getHttpClient()
+	cl.Timeout = 5 * time.Second
+	resp, err := cl.Get(fmt.Sprintf("%s/operators/%d", RegistryURL, operatorID))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("registry responded with status %s", resp.Status)
+	}
+	return nil
+}
+
 func isUsingHardcodedOperators() bool {
 	isHardcoded := os.Getenv("USE_HARDCODED_OPERATORS")
 	if isHardcoded == "" {
@@ -76,7 +160,15 @@ func getResponse(url string) ([]byte, error) {
 		return nil, err
 	}
 	defer resp.Body.Close()
-	return io.ReadAll(resp.Body)
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("registry responded with status %s", resp.Status)
+	}
+	return body, nil
 }
 
 func ParsePublicKeyFromBase64(base64Key string) (*rsa.PublicKey, error) {
@@ -98,7 +190,11 @@ func ParsePublicKeyFromBase64(base64Key string) (*rsa.PublicKey, error) {
 		return nil, err
 	}
 
-	return publicKey.(*rsa.PublicKey), nil
+	rsaKey, ok := publicKey.(*rsa.PublicKey)
+	if !ok {
+		return nil, errors.New("public key is not an RSA key")
+	}
+	return rsaKey, nil
 }
 
 func getHttpClient() *http.Client {
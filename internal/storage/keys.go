@@ -0,0 +1,53 @@
+package storage
+
+import (
+	"encoding/hex"
+	"fmt"
+
+	"github.com/bloxapp/ssv-spec/dkg"
+	"github.com/bloxapp/ssv-spec/types"
+)
+
+// Every record lives in a namespace, the prefix of its key
+const (
+	prefixOperators  = "operators/"
+	prefixKeyGen     = "keygen/"
+	prefixCeremonies = "ceremonies/"
+	prefixAudit      = "audit/"
+	prefixAuditIndex = "audit_index/"
+	prefixMeta       = "meta/"
+
+	keySchemaVersion = prefixMeta + "schema_version"
+	metaDataKey      = prefixMeta + "share_data_key"
+	keyAuditHead     = prefixMeta + "audit_head"
+)
+
+// operatorKey is the key of a cached registry operator
+func operatorKey(operatorID types.OperatorID) []byte {
+	return []byte(fmt.Sprintf("%s%d", prefixOperators, operatorID))
+}
+
+// keyGenKey is the key of the keygen output of a validator
+func keyGenKey(validatorPK []byte) []byte {
+	return []byte(prefixKeyGen + hex.EncodeToString(validatorPK))
+}
+
+// ceremonyKey is the key of the record of a ceremony
+func ceremonyKey(requestID dkg.RequestID) []byte {
+	return []byte(prefixCeremonies + hex.EncodeToString(requestID[:]))
+}
+
+// auditKey is the key of an audit log entry, ordered by sequence number
+func auditKey(seq uint64) []byte {
+	return []byte(fmt.Sprintf("%s%016x", prefixAudit, seq))
+}
+
+// auditIndexPrefix is the prefix of the index keys of the audit entries of a ceremony
+func auditIndexPrefix(requestID string) []byte {
+	return []byte(prefixAuditIndex + requestID + "/")
+}
+
+// auditIndexKey indexes an audit entry under its ceremony, ordered by sequence number
+func auditIndexKey(requestID string, seq uint64) []byte {
+	return []byte(fmt.Sprintf("%s%016x", auditIndexPrefix(requestID), seq))
+}
@@ -0,0 +1,46 @@
+package storage
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var metricsRegistryFetch = promauto.NewHistogramVec(prometheus.HistogramOpts{
+	Name:    "dkg_node_registry_fetch_seconds",
+	Help:    "Latency of operator lookups against the operator registry",
+	Buckets: prometheus.DefBuckets,
+}, []string{"status"})
+
+var metricsOperatorRefreshFailures = promauto.NewCounter(prometheus.CounterOpts{
+	Name: "dkg_node_operator_refresh_failures_total",
+	Help: "Number of times a stale cached operator was used because the registry couldn't be reached",
+})
+
+var metricsOperatorKeyChanges = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "dkg_node_operator_key_changes_total",
+	Help: "Number of times the registry returned another RSA key for an operator than the cached one",
+}, []string{"operator_id"})
+
+func observeRegistryFetch(start time.Time, err error) {
+	status := "success"
+	if err != nil {
+		status = "error"
+	}
+	metricsRegistryFetch.WithLabelValues(status).Observe(time.Since(start).Seconds())
+}
+
+// RegisterMetrics registers a gauge reporting the number of validator shares held in the database
+func (s *Storage) RegisterMetrics() {
+	promauto.NewGaugeFunc(prometheus.GaugeOpts{
+		Name: "dkg_node_stored_shares",
+		Help: "Number of validator key shares stored by the node",
+	}, func() float64 {
+		count, err := s.CountKeyGenOutputs()
+		if err != nil {
+			return -1
+		}
+		return float64(count)
+	})
+}
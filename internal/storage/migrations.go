@@ -0,0 +1,199 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// SchemaVersion is the version of the storage schema this node reads and writes
+const SchemaVersion = 3
+
+// migration upgrades the database from version-1 to version. It returns the number of
+// records it changed.
+type migration struct {
+	version int
+	name    string
+	migrate func(tx Tx) (int, error)
+}
+
+var migrations = []migration{
+	{version: 1, name: "move records into namespaces", migrate: migrateNamespaces},
+	{version: 2, name: "record when cached operators were fetched", migrate: migrateOperatorCache},
+	{version: 3, name: "index the audit log by ceremony", migrate: migrateAuditIndex},
+}
+
+// MigrationReport describes the migrations applied to a database, or that would be applied in a dry run
+type MigrationReport struct {
+	From    int
+	To      int
+	DryRun  bool
+	Applied []AppliedMigration
+}
+
+type AppliedMigration struct {
+	Version int
+	Name    string
+	Changes int
+}
+
+func (r *MigrationReport) String() string {
+	if r.From == r.To {
+		return fmt.Sprintf("storage schema is up to date at version %d", r.To)
+	}
+	verb := "migrated"
+	if r.DryRun {
+		verb = "would migrate"
+	}
+	out := fmt.Sprintf("%s storage schema from version %d to %d", verb, r.From, r.To)
+	for _, m := range r.Applied {
+		out += fmt.Sprintf("\n  %d: %s (%d records)", m.Version, m.Name, m.Changes)
+	}
+	return out
+}
+
+// errDryRun discards the transaction of a dry run
+var errDryRun = errors.New("dry run")
+
+// Migrate upgrades the database to SchemaVersion. All migrations run in one transaction, which
+// a dry run discards instead of committing.
+func (s *Storage) Migrate(dryRun bool) (*MigrationReport, error) {
+	var report *MigrationReport
+	err := s.db.Update(func(tx Tx) error {
+		from, err := schemaVersion(tx)
+		if err != nil {
+			return err
+		}
+		if from > SchemaVersion {
+			return fmt.Errorf("database schema version %d is newer than version %d this node supports", from, SchemaVersion)
+		}
+
+		report = &MigrationReport{From: from, To: SchemaVersion, DryRun: dryRun}
+		for _, m := range migrations {
+			if m.version <= from {
+				continue
+			}
+			changes, err := m.migrate(tx)
+			if err != nil {
+				return fmt.Errorf("migration %d (%s) failed: %v", m.version, m.name, err)
+			}
+			if err := tx.Set([]byte(keySchemaVersion), []byte(strconv.Itoa(m.version))); err != nil {
+				return err
+			}
+			report.Applied = append(report.Applied, AppliedMigration{Version: m.version, Name: m.name, Changes: changes})
+		}
+		if dryRun {
+			return errDryRun
+		}
+		return nil
+	})
+	if err != nil && err != errDryRun {
+		return nil, err
+	}
+	return report, nil
+}
+
+// SchemaVersion returns the schema version of the database, 0 for databases written before versioning
+func (s *Storage) SchemaVersion() (int, error) {
+	version := 0
+	err := s.db.View(func(tx Tx) error {
+		var err error
+		version, err = schemaVersion(tx)
+		return err
+	})
+	return version, err
+}
+
+func schemaVersion(tx Tx) (int, error) {
+	value, err := tx.Get([]byte(keySchemaVersion))
+	if err == ErrNotFound {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	version, err := strconv.Atoi(string(value))
+	if err != nil {
+		return 0, fmt.Errorf("invalid schema version %q", value)
+	}
+	return version, nil
+}
+
+// migrateNamespaces moves the records of unversioned databases, operators under operator/<id>
+// and keygen outputs under the raw validator public key, into their namespaces
+func migrateNamespaces(tx Tx) (int, error) {
+	// the moved records are written under keys the iteration may not have reached yet, so
+	// they're collected first
+	type move struct {
+		from, to, value []byte
+	}
+	moves := make([]move, 0)
+	err := tx.Iterate(nil, nil, func(key, value []byte) (bool, error) {
+		var to []byte
+		switch {
+		case bytes.HasPrefix(key, []byte("operator/")):
+			to = append([]byte(prefixOperators), key[len("operator/"):]...)
+		case len(key) == 48 && !namespaced(key):
+			to = []byte(prefixKeyGen + hex.EncodeToString(key))
+		default:
+			return true, nil
+		}
+		moves = append(moves, move{from: append([]byte{}, key...), to: to, value: append([]byte{}, value...)})
+		return true, nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	for _, m := range moves {
+		if err := tx.Set(m.to, m.value); err != nil {
+			return 0, err
+		}
+		if err := tx.Delete(m.from); err != nil {
+			return 0, err
+		}
+	}
+	return len(moves), nil
+}
+
+func namespaced(key []byte) bool {
+	for _, prefix := range []string{prefixOperators, prefixKeyGen, prefixCeremonies, prefixAudit, prefixAuditIndex, prefixMeta} {
+		if bytes.HasPrefix(key, []byte(prefix)) {
+			return true
+		}
+	}
+	return false
+}
+
+// migrateOperatorCache wraps the cached operators into cache entries. Their fetch time is
+// unknown and left zero, so they're fetched again on next use.
+func migrateOperatorCache(tx Tx) (int, error) {
+	type entry struct {
+		Operator  json.RawMessage `json:"operator"`
+		FetchedAt time.Time       `json:"fetched_at"`
+	}
+	migrated := 0
+	err := tx.Iterate([]byte(prefixOperators), nil, func(key, operator []byte) (bool, error) {
+		value, err := json.Marshal(&entry{Operator: operator})
+		if err != nil {
+			return false, err
+		}
+		migrated++
+		return true, tx.Set(key, value)
+	})
+	return migrated, err
+}
+
+// migrateAuditIndex indexes the entries of the audit log under their ceremony
+func migrateAuditIndex(tx Tx) (int, error) {
+	indexed := 0
+	err := forEachAuditEntry(tx, func(entry *AuditEntry) error {
+		indexed++
+		return tx.Set(auditIndexKey(entry.RequestID, entry.Seq), []byte{})
+	})
+	return indexed, err
+}
@@ -0,0 +1,97 @@
+package storage
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/bloxapp/ssv-spec/dkg"
+	"github.com/bloxapp/ssv-spec/types/testingutils"
+	"github.com/stretchr/testify/require"
+)
+
+// writeLegacyRecords writes an operator and a keygen output the way unversioned databases did
+func writeLegacyRecords(t *testing.T, s *Storage, output *dkg.KeyGenOutput) {
+	t.Helper()
+	ks := testingutils.Testing4SharesSet()
+	operator, err := json.Marshal(&dkg.Operator{OperatorID: 1, ETHAddress: ks.DKGOperators[1].ETHAddress, EncryptionPubKey: &ks.DKGOperators[1].EncryptionKey.PublicKey})
+	require.NoError(t, err)
+	value, err := (&KeyGenOutput{}).Encode(output)
+	require.NoError(t, err)
+
+	require.NoError(t, s.db.Update(func(tx Tx) error {
+		if err := tx.Set([]byte("operator/1"), operator); err != nil {
+			return err
+		}
+		return tx.Set(output.ValidatorPK, value)
+	}))
+}
+
+func hasKey(t *testing.T, s *Storage, key []byte) bool {
+	t.Helper()
+	err := s.db.View(func(tx Tx) error {
+		_, err := tx.Get(key)
+		return err
+	})
+	if err == ErrNotFound {
+		return false
+	}
+	require.NoError(t, err)
+	return true
+}
+
+func TestMigrateLegacyDatabase(t *testing.T) {
+	s := newTestStorage(t)
+	output := testKeyGenOutput(1)
+	writeLegacyRecords(t, s, output)
+
+	report, err := s.Migrate(true)
+	require.NoError(t, err)
+	require.Equal(t, 0, report.From)
+	require.Equal(t, SchemaVersion, report.To)
+	require.Equal(t, 2, report.Applied[0].Changes)
+	require.Equal(t, 1, report.Applied[1].Changes)
+
+	// a dry run leaves the database untouched
+	version, err := s.SchemaVersion()
+	require.NoError(t, err)
+	require.Equal(t, 0, version)
+	require.True(t, hasKey(t, s, output.ValidatorPK))
+
+	report, err = s.Migrate(false)
+	require.NoError(t, err)
+	require.Len(t, report.Applied, len(migrations))
+
+	version, err = s.SchemaVersion()
+	require.NoError(t, err)
+	require.Equal(t, SchemaVersion, version)
+	require.False(t, hasKey(t, s, output.ValidatorPK))
+	require.False(t, hasKey(t, s, []byte("operator/1")))
+
+	// the cached operator is kept, but is stale
+	cached, err := s.cachedOperator(1)
+	require.NoError(t, err)
+	require.Equal(t, testingutils.Testing4SharesSet().DKGOperators[1].ETHAddress, cached.Operator.ETHAddress)
+	require.True(t, cached.FetchedAt.IsZero())
+
+	stored, err := s.GetKeyGenOutput(output.ValidatorPK)
+	require.NoError(t, err)
+	require.True(t, stored.Share.IsEqual(output.Share))
+
+	count, err := s.CountKeyGenOutputs()
+	require.NoError(t, err)
+	require.Equal(t, 1, count)
+
+	// migrating again is a no-op
+	report, err = s.Migrate(false)
+	require.NoError(t, err)
+	require.Empty(t, report.Applied)
+}
+
+func TestMigrateRejectsNewerSchema(t *testing.T) {
+	s := newTestStorage(t)
+	require.NoError(t, s.db.Update(func(tx Tx) error {
+		return tx.Set([]byte(keySchemaVersion), []byte("99"))
+	}))
+	_, err := s.Migrate(false)
+	require.Error(t, err)
+}
@@ -0,0 +1,168 @@
+package storage
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/bloxapp/ssv-spec/dkg"
+	"github.com/bloxapp/ssv-spec/types"
+)
+
+// DefaultOperatorCacheTTL is how long a cached registry operator is used by default
+const DefaultOperatorCacheTTL = time.Hour
+
+// ErrOperatorKeyChanged is returned for an operator the registry returned another RSA key for
+// than the pinned one, until an admin accepts the registry key
+var ErrOperatorKeyChanged = errors.New("the registry returned another RSA key than the pinned one")
+
+// cachedOperator is a registry operator as cached in the database. The RSA key of Operator is
+// pinned: KeyChange holds the operator as returned by the registry with another key, until an
+// admin accepts it.
+type cachedOperator struct {
+	Operator  *dkg.Operator `json:"operator"`
+	FetchedAt time.Time     `json:"fetched_at"`
+	KeyChange *dkg.Operator `json:"key_change,omitempty"`
+}
+
+// SetOperatorCacheTTL sets how long a cached registry operator is used before it's fetched again
+func (s *Storage) SetOperatorCacheTTL(ttl time.Duration) {
+	s.operatorTTL = ttl
+}
+
+// OnOperatorKeyChange sets a function called when the registry returns another RSA key for an
+// operator than the pinned one. The pinned key is kept, and the operator is refused until the
+// registry key is accepted with RefreshOperator.
+func (s *Storage) OnOperatorKeyChange(fn func(operatorID types.OperatorID)) {
+	s.onOperatorKeyChange = fn
+}
+
+// GetDKGOperator returns the operator from the cache, fetching it from the registry when it
+// isn't cached or the cached entry is older than the cache TTL. If the registry fails, a stale
+// cached entry is used. It returns ErrOperatorKeyChanged while the registry key of the operator
+// differs from the pinned one.
+func (s *Storage) GetDKGOperator(operatorID types.OperatorID) (bool, *dkg.Operator, error) {
+	cached, err := s.cachedOperator(operatorID)
+	if err != nil {
+		return false, nil, err
+	}
+	if cached != nil && cached.KeyChange != nil {
+		return false, nil, fmt.Errorf("operator %d: %w", operatorID, ErrOperatorKeyChanged)
+	}
+	if cached != nil && time.Since(cached.FetchedAt) < s.operatorTTL {
+		return true, cached.Operator, nil
+	}
+
+	operator, _, err := s.RefreshOperator(operatorID, false)
+	if err != nil {
+		if errors.Is(err, ErrOperatorKeyChanged) {
+			return false, nil, err
+		}
+		if cached != nil {
+			metricsOperatorRefreshFailures.Inc()
+			return true, cached.Operator, nil
+		}
+		return false, nil, err
+	}
+	return true, operator, nil
+}
+
+// RefreshOperator fetches the operator from the registry and caches it. It returns true if the
+// registry returned another RSA key than the pinned one, which only replaces the pinned key if
+// acceptKeyChange is set. Otherwise the pinned key is kept and ErrOperatorKeyChanged returned.
+func (s *Storage) RefreshOperator(operatorID types.OperatorID, acceptKeyChange bool) (*dkg.Operator, bool, error) {
+	operator, err := FetchOperatorByID(operatorID)
+	if err != nil {
+		return nil, false, err
+	}
+
+	cached, err := s.cachedOperator(operatorID)
+	if err != nil {
+		return nil, false, err
+	}
+	keyChanged := cached != nil && !cached.Operator.EncryptionPubKey.Equal(operator.EncryptionPubKey)
+	if keyChanged && !acceptKeyChange {
+		if cached.KeyChange == nil || !cached.KeyChange.EncryptionPubKey.Equal(operator.EncryptionPubKey) {
+			metricsOperatorKeyChanges.WithLabelValues(fmt.Sprint(operatorID)).Inc()
+			if s.onOperatorKeyChange != nil {
+				s.onOperatorKeyChange(operatorID)
+			}
+		}
+		cached.KeyChange = operator
+		if err := s.saveCachedOperator(operatorID, cached); err != nil {
+			return nil, false, err
+		}
+		return nil, true, fmt.Errorf("operator %d: %w", operatorID, ErrOperatorKeyChanged)
+	}
+
+	if err := s.saveCachedOperator(operatorID, &cachedOperator{Operator: operator, FetchedAt: time.Now().UTC()}); err != nil {
+		return nil, false, err
+	}
+	return operator, keyChanged, nil
+}
+
+// InvalidateOperator marks the cached operator stale, so it's fetched on next use. Its RSA key
+// stays pinned.
+func (s *Storage) InvalidateOperator(operatorID types.OperatorID) error {
+	cached, err := s.cachedOperator(operatorID)
+	if err != nil || cached == nil {
+		return err
+	}
+	cached.FetchedAt = time.Time{}
+	return s.saveCachedOperator(operatorID, cached)
+}
+
+// InvalidateOperators marks every cached operator stale and returns how many were cached
+func (s *Storage) InvalidateOperators() (int, error) {
+	invalidated := 0
+	err := s.db.Update(func(tx Tx) error {
+		return tx.Iterate([]byte(prefixOperators), nil, func(key, value []byte) (bool, error) {
+			cached := &cachedOperator{}
+			if err := json.Unmarshal(value, cached); err != nil {
+				return false, err
+			}
+			cached.FetchedAt = time.Time{}
+			value, err := json.Marshal(cached)
+			if err != nil {
+				return false, err
+			}
+			invalidated++
+			return true, tx.Set(key, value)
+		})
+	})
+	return invalidated, err
+}
+
+func (s *Storage) saveCachedOperator(operatorID types.OperatorID, cached *cachedOperator) error {
+	value, err := json.Marshal(cached)
+	if err != nil {
+		return fmt.Errorf("failed to marshal operator :: %s", err.Error())
+	}
+	return s.db.Update(func(tx Tx) error {
+		return tx.Set(operatorKey(operatorID), value)
+	})
+}
+
+func (s *Storage) cachedOperator(operatorID types.OperatorID) (*cachedOperator, error) {
+	var cached *cachedOperator
+	err := s.db.View(func(tx Tx) error {
+		value, err := tx.Get(operatorKey(operatorID))
+		if err == ErrNotFound {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		cached = &cachedOperator{}
+		return json.Unmarshal(value, cached)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if cached != nil && (cached.Operator == nil || cached.Operator.EncryptionPubKey == nil) {
+		// unusable, fetch it again
+		return nil, nil
+	}
+	return cached, nil
+}
@@ -0,0 +1,188 @@
+package storage
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bloxapp/ssv-spec/types"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/require"
+)
+
+// testRegistry serves operator 1 with the current key and counts the requests
+type testRegistry struct {
+	mu       sync.Mutex
+	response operatorResponse
+	status   int
+	requests int
+}
+
+func newTestRegistry(t *testing.T) *testRegistry {
+	t.Helper()
+	registry := &testRegistry{status: http.StatusOK}
+	registry.setKey(t)
+	registry.response.ID = 1
+	registry.response.Owner = "0x2d618a45796936b1b7aeb87d01ee70e09254487d"
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		registry.mu.Lock()
+		defer registry.mu.Unlock()
+		registry.requests++
+		w.WriteHeader(registry.status)
+		json.NewEncoder(w).Encode(&registry.response)
+	}))
+	t.Cleanup(srv.Close)
+
+	registryURL := RegistryURL
+	RegistryURL = srv.URL
+	t.Cleanup(func() { RegistryURL = registryURL })
+	t.Setenv("USE_HARDCODED_OPERATORS", "false")
+	return registry
+}
+
+// setKey makes the registry return a new RSA key
+func (r *testRegistry) setKey(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	require.NoError(t, err)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.response.PublicKey = base64.StdEncoding.EncodeToString(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+}
+
+func (r *testRegistry) set(fn func(r *testRegistry)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	fn(r)
+}
+
+func (r *testRegistry) count() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.requests
+}
+
+func TestOperatorCacheTTL(t *testing.T) {
+	registry := newTestRegistry(t)
+	s := newTestStorage(t)
+
+	found, operator, err := s.GetDKGOperator(1)
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Equal(t, common.HexToAddress("0x2d618a45796936b1b7aeb87d01ee70e09254487d"), operator.ETHAddress)
+
+	// cached
+	_, _, err = s.GetDKGOperator(1)
+	require.NoError(t, err)
+	require.Equal(t, 1, registry.count())
+
+	// expired
+	s.SetOperatorCacheTTL(time.Nanosecond)
+	_, _, err = s.GetDKGOperator(1)
+	require.NoError(t, err)
+	require.Equal(t, 2, registry.count())
+
+	// a failing registry leaves the stale entry in use
+	registry.set(func(r *testRegistry) { r.status = http.StatusInternalServerError })
+	_, cached, err := s.GetDKGOperator(1)
+	require.NoError(t, err)
+	require.True(t, cached.EncryptionPubKey.Equal(operator.EncryptionPubKey))
+
+	// nor when it was invalidated, which keeps its key pinned
+	require.NoError(t, s.InvalidateOperator(1))
+	_, cached, err = s.GetDKGOperator(1)
+	require.NoError(t, err)
+	require.True(t, cached.EncryptionPubKey.Equal(operator.EncryptionPubKey))
+
+	// but not when nothing is cached
+	found, _, err = newTestStorage(t).GetDKGOperator(1)
+	require.ErrorContains(t, err, "500")
+	require.False(t, found)
+}
+
+func TestOperatorRegistryValidation(t *testing.T) {
+	registry := newTestRegistry(t)
+	s := newTestStorage(t)
+
+	registry.set(func(r *testRegistry) { r.response.ID = 2 })
+	_, _, err := s.GetDKGOperator(1)
+	require.ErrorContains(t, err, "registry returned operator 2 for operator 1")
+
+	registry.set(func(r *testRegistry) {
+		r.response.ID = 1
+		r.response.Owner = "0x0000000000000000000000000000000000000000"
+	})
+	_, _, err = s.GetDKGOperator(1)
+	require.ErrorContains(t, err, "invalid owner address")
+
+	registry.set(func(r *testRegistry) {
+		r.response.Owner = "0x2d618a45796936b1b7aeb87d01ee70e09254487d"
+		r.response.PublicKey = "bm90IGEga2V5"
+	})
+	_, _, err = s.GetDKGOperator(1)
+	require.ErrorContains(t, err, "invalid public key")
+
+	cached, err := s.cachedOperator(1)
+	require.NoError(t, err)
+	require.Nil(t, cached)
+}
+
+func TestOperatorKeyChange(t *testing.T) {
+	registry := newTestRegistry(t)
+	s := newTestStorage(t)
+	changed := make([]types.OperatorID, 0)
+	s.OnOperatorKeyChange(func(operatorID types.OperatorID) { changed = append(changed, operatorID) })
+
+	_, pinned, err := s.GetDKGOperator(1)
+	require.NoError(t, err)
+	_, keyChanged, err := s.RefreshOperator(1, false)
+	require.NoError(t, err)
+	require.False(t, keyChanged)
+	require.Empty(t, changed)
+
+	// another registry key doesn't replace the pinned one, and the operator is refused
+	registry.setKey(t)
+	s.SetOperatorCacheTTL(time.Nanosecond)
+	found, _, err := s.GetDKGOperator(1)
+	require.ErrorIs(t, err, ErrOperatorKeyChanged)
+	require.False(t, found)
+	require.Equal(t, []types.OperatorID{1}, changed)
+
+	_, keyChanged, err = s.RefreshOperator(1, false)
+	require.ErrorIs(t, err, ErrOperatorKeyChanged)
+	require.True(t, keyChanged)
+	require.Equal(t, []types.OperatorID{1}, changed)
+
+	// even once invalidated or when the registry fails
+	_, err = s.InvalidateOperators()
+	require.NoError(t, err)
+	registry.set(func(r *testRegistry) { r.status = http.StatusInternalServerError })
+	_, _, err = s.GetDKGOperator(1)
+	require.ErrorIs(t, err, ErrOperatorKeyChanged)
+	entry, err := s.cachedOperator(1)
+	require.NoError(t, err)
+	require.True(t, entry.Operator.EncryptionPubKey.Equal(pinned.EncryptionPubKey))
+
+	// until the registry key is accepted
+	registry.set(func(r *testRegistry) { r.status = http.StatusOK })
+	accepted, keyChanged, err := s.RefreshOperator(1, true)
+	require.NoError(t, err)
+	require.True(t, keyChanged)
+	require.False(t, accepted.EncryptionPubKey.Equal(pinned.EncryptionPubKey))
+
+	s.SetOperatorCacheTTL(DefaultOperatorCacheTTL)
+	found, cached, err := s.GetDKGOperator(1)
+	require.NoError(t, err)
+	require.True(t, found)
+	require.True(t, cached.EncryptionPubKey.Equal(accepted.EncryptionPubKey))
+}
@@ -0,0 +1,172 @@
+package storage
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+
+	"github.com/herumi/bls-eth-go-binary/bls"
+)
+
+// EnableShareEncryption unwraps the data key shares are encrypted with, creating it on first
+// use, and encrypts any share still stored in plaintext. It returns the number of shares
+// encrypted by this call.
+func (s *Storage) EnableShareEncryption(kek *KEK) (int, error) {
+	record, err := s.loadDataKeyRecord()
+	if err != nil {
+		return 0, err
+	}
+
+	var dataKey []byte
+	if record == nil {
+		if dataKey, err = randomBytes(keyLen); err != nil {
+			return 0, err
+		}
+		if record, err = newDataKeyRecord(kek, dataKey); err != nil {
+			return 0, err
+		}
+	} else if dataKey, err = record.unwrap(kek); err != nil {
+		return 0, err
+	}
+	shares := &shareCipher{dataKey: dataKey}
+
+	migrated := 0
+	err = s.db.Update(func(tx Tx) error {
+		if err := setDataKeyRecord(tx, record); err != nil {
+			return err
+		}
+		return forEachKeyGenOutput(tx, func(key []byte, kgo *KeyGenOutput) error {
+			if kgo.EncryptedShare != "" {
+				return nil
+			}
+			if err := kgo.encryptShare(shares); err != nil {
+				return err
+			}
+			migrated++
+			return setKeyGenOutput(tx, key, kgo)
+		})
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	s.shares = shares
+	return migrated, nil
+}
+
+// RotateShareKEK wraps the data key with a key encryption key derived from a new secret. The
+// shares themselves aren't touched.
+func (s *Storage) RotateShareKEK(kek *KEK) error {
+	if s.shares == nil {
+		return errors.New("share encryption isn't enabled")
+	}
+	record, err := newDataKeyRecord(kek, s.shares.dataKey)
+	if err != nil {
+		return err
+	}
+	return s.db.Update(func(tx Tx) error {
+		return setDataKeyRecord(tx, record)
+	})
+}
+
+// RotateShareDataKey re-encrypts every share with a new data key, wrapped with kek, and returns
+// the number of shares re-encrypted
+func (s *Storage) RotateShareDataKey(kek *KEK) (int, error) {
+	if s.shares == nil {
+		return 0, errors.New("share encryption isn't enabled")
+	}
+	dataKey, err := randomBytes(keyLen)
+	if err != nil {
+		return 0, err
+	}
+	record, err := newDataKeyRecord(kek, dataKey)
+	if err != nil {
+		return 0, err
+	}
+	shares := &shareCipher{dataKey: dataKey}
+
+	rotated := 0
+	err = s.db.Update(func(tx Tx) error {
+		err := forEachKeyGenOutput(tx, func(key []byte, kgo *KeyGenOutput) error {
+			if err := kgo.decryptShare(s.shares); err != nil {
+				return err
+			}
+			if err := kgo.encryptShare(shares); err != nil {
+				return err
+			}
+			rotated++
+			return setKeyGenOutput(tx, key, kgo)
+		})
+		if err != nil {
+			return err
+		}
+		return setDataKeyRecord(tx, record)
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	s.shares = shares
+	return rotated, nil
+}
+
+func (s *Storage) loadDataKeyRecord() (*dataKeyRecord, error) {
+	var record *dataKeyRecord
+	err := s.db.View(func(tx Tx) error {
+		value, err := tx.Get([]byte(metaDataKey))
+		if err == ErrNotFound {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		record = &dataKeyRecord{}
+		return json.Unmarshal(value, record)
+	})
+	return record, err
+}
+
+func setDataKeyRecord(tx Tx, record *dataKeyRecord) error {
+	value, err := record.encode()
+	if err != nil {
+		return err
+	}
+	return tx.Set([]byte(metaDataKey), value)
+}
+
+func setKeyGenOutput(tx Tx, key []byte, kgo *KeyGenOutput) error {
+	value, err := json.Marshal(kgo)
+	if err != nil {
+		return err
+	}
+	return tx.Set(key, value)
+}
+
+// encryptShare replaces the plaintext share with its encryption
+func (o *KeyGenOutput) encryptShare(shares *shareCipher) error {
+	share := &bls.SecretKey{}
+	if err := share.DeserializeHexStr(o.Share); err != nil {
+		return err
+	}
+	encrypted, err := shares.encrypt(o.ValidatorPK, share.Serialize())
+	if err != nil {
+		return err
+	}
+	o.EncryptedShare = encrypted
+	o.Share = ""
+	return nil
+}
+
+// decryptShare replaces the encrypted share with the plaintext share
+func (o *KeyGenOutput) decryptShare(shares *shareCipher) error {
+	plaintext, err := shares.decrypt(o.ValidatorPK, o.EncryptedShare)
+	if err != nil {
+		return err
+	}
+	o.Share = hex.EncodeToString(plaintext)
+	o.EncryptedShare = ""
+	for i := range plaintext {
+		plaintext[i] = 0
+	}
+	return nil
+}
@@ -0,0 +1,149 @@
+package storage
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/bloxapp/ssv-spec/dkg"
+	"github.com/bloxapp/ssv-spec/types"
+)
+
+// Ceremony types a share can originate from
+const (
+	CeremonyKeygen  = "keygen"
+	CeremonyReshare = "reshare"
+)
+
+// Page sizes of listings
+const (
+	DefaultPageLimit = 100
+	MaxPageLimit     = 1000
+)
+
+// ShareInfo describes a stored validator share, without the share itself
+type ShareInfo struct {
+	ValidatorPK string             `json:"validator_pk"`
+	Threshold   uint64             `json:"threshold"`
+	Committee   []types.OperatorID `json:"committee"`
+	RequestID   string             `json:"request_id,omitempty"`
+	Ceremony    string             `json:"ceremony,omitempty"`
+	CreatedAt   *time.Time         `json:"created_at,omitempty"`
+}
+
+// SharePage is a page of shares ordered by validator public key. Next continues the listing
+// and is empty on the last page.
+type SharePage struct {
+	Shares []*ShareInfo `json:"shares"`
+	Next   string       `json:"next,omitempty"`
+}
+
+// ShareFilter selects the shares to list, zero fields don't filter
+type ShareFilter struct {
+	// Committee selects shares whose committee includes all these operators
+	Committee []types.OperatorID
+	// From and To select shares created in [From, To). Shares stored before creation
+	// times were recorded have none and don't match.
+	From time.Time
+	To   time.Time
+	// After continues a listing after this validator public key
+	After []byte
+	Limit int
+}
+
+func (f *ShareFilter) match(kgo *KeyGenOutput) bool {
+	for _, operatorID := range f.Committee {
+		if _, ok := kgo.OperatorPubKeys[operatorID]; !ok {
+			return false
+		}
+	}
+	if !f.From.IsZero() || !f.To.IsZero() {
+		if kgo.CreatedAt.IsZero() {
+			return false
+		}
+		if !f.From.IsZero() && kgo.CreatedAt.Before(f.From) {
+			return false
+		}
+		if !f.To.IsZero() && !kgo.CreatedAt.Before(f.To) {
+			return false
+		}
+	}
+	return true
+}
+
+// ListShares returns a page of the shares matching the filter
+func (s *Storage) ListShares(filter ShareFilter) (*SharePage, error) {
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = DefaultPageLimit
+	}
+	if limit > MaxPageLimit {
+		limit = MaxPageLimit
+	}
+
+	page := &SharePage{Shares: make([]*ShareInfo, 0)}
+	var after []byte
+	if filter.After != nil {
+		after = keyGenKey(filter.After)
+	}
+	err := s.db.View(func(tx Tx) error {
+		return iterateAfter(tx, []byte(prefixKeyGen), after, func(key, value []byte) (bool, error) {
+			kgo := &KeyGenOutput{}
+			if err := json.Unmarshal(value, kgo); err != nil {
+				return false, fmt.Errorf("failed to unmarshal keygen output %s: %v", key, err)
+			}
+			if !filter.match(kgo) {
+				return true, nil
+			}
+			if len(page.Shares) == limit {
+				page.Next = page.Shares[limit-1].ValidatorPK
+				return false, nil
+			}
+			page.Shares = append(page.Shares, kgo.info())
+			return true, nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return page, nil
+}
+
+// SetShareOrigin records the ceremony the stored share of a validator originates from
+func (s *Storage) SetShareOrigin(validatorPK []byte, requestID dkg.RequestID, ceremony string) error {
+	key := keyGenKey(validatorPK)
+	return s.db.Update(func(tx Tx) error {
+		value, err := tx.Get(key)
+		if err != nil {
+			return err
+		}
+		kgo := &KeyGenOutput{}
+		if err := json.Unmarshal(value, kgo); err != nil {
+			return err
+		}
+		kgo.RequestID = hex.EncodeToString(requestID[:])
+		kgo.Ceremony = ceremony
+		return setKeyGenOutput(tx, key, kgo)
+	})
+}
+
+func (o *KeyGenOutput) info() *ShareInfo {
+	info := &ShareInfo{
+		ValidatorPK: o.ValidatorPK,
+		Threshold:   o.Threshold,
+		Committee:   make([]types.OperatorID, 0, len(o.OperatorPubKeys)),
+		RequestID:   o.RequestID,
+		Ceremony:    o.Ceremony,
+	}
+	for operatorID := range o.OperatorPubKeys {
+		info.Committee = append(info.Committee, operatorID)
+	}
+	sort.Slice(info.Committee, func(i, j int) bool { return info.Committee[i] < info.Committee[j] })
+	if !o.CreatedAt.IsZero() {
+		createdAt := o.CreatedAt
+		info.CreatedAt = &createdAt
+	}
+	return info
+}
@@ -0,0 +1,95 @@
+package storage
+
+import (
+	"encoding/hex"
+	"testing"
+	"time"
+
+	"github.com/bloxapp/ssv-spec/dkg"
+	"github.com/bloxapp/ssv-spec/types"
+	"github.com/herumi/bls-eth-go-binary/bls"
+	"github.com/stretchr/testify/require"
+)
+
+// randomKeyGenOutput returns the output of a ceremony of a random validator with the committee
+func randomKeyGenOutput(committee ...types.OperatorID) *dkg.KeyGenOutput {
+	validator, share := &bls.SecretKey{}, &bls.SecretKey{}
+	validator.SetByCSPRNG()
+	share.SetByCSPRNG()
+	output := &dkg.KeyGenOutput{
+		Share:           share,
+		ValidatorPK:     validator.GetPublicKey().Serialize(),
+		OperatorPubKeys: make(map[types.OperatorID]*bls.PublicKey),
+		Threshold:       uint64(len(committee)*2/3 + 1),
+	}
+	for _, operatorID := range committee {
+		output.OperatorPubKeys[operatorID] = share.GetPublicKey()
+	}
+	return output
+}
+
+func TestListShares(t *testing.T) {
+	s := newTestStorage(t)
+	for i := 0; i < 5; i++ {
+		require.NoError(t, s.SaveKeyGenOutput(randomKeyGenOutput(1, 2, 3, 4)))
+	}
+	other := randomKeyGenOutput(1, 5, 6, 7)
+	require.NoError(t, s.SaveKeyGenOutput(other))
+
+	// pages cover every share once, in validator public key order
+	seen := make([]string, 0)
+	filter := ShareFilter{Limit: 2}
+	for {
+		page, err := s.ListShares(filter)
+		require.NoError(t, err)
+		require.LessOrEqual(t, len(page.Shares), 2)
+		for _, share := range page.Shares {
+			seen = append(seen, share.ValidatorPK)
+		}
+		if page.Next == "" {
+			break
+		}
+		filter.After, err = hex.DecodeString(page.Next)
+		require.NoError(t, err)
+	}
+	require.Len(t, seen, 6)
+	require.IsIncreasing(t, seen)
+
+	page, err := s.ListShares(ShareFilter{Committee: []types.OperatorID{5, 1}})
+	require.NoError(t, err)
+	require.Len(t, page.Shares, 1)
+	require.Equal(t, hex.EncodeToString(other.ValidatorPK), page.Shares[0].ValidatorPK)
+	require.Equal(t, []types.OperatorID{1, 5, 6, 7}, page.Shares[0].Committee)
+	require.Equal(t, uint64(3), page.Shares[0].Threshold)
+	require.NotNil(t, page.Shares[0].CreatedAt)
+
+	page, err = s.ListShares(ShareFilter{From: time.Now().Add(time.Hour)})
+	require.NoError(t, err)
+	require.Empty(t, page.Shares)
+	page, err = s.ListShares(ShareFilter{From: time.Now().Add(-time.Hour), To: time.Now().Add(time.Hour)})
+	require.NoError(t, err)
+	require.Len(t, page.Shares, 6)
+}
+
+func TestSetShareOrigin(t *testing.T) {
+	s := newTestStorage(t)
+	_, err := s.EnableShareEncryption(PasswordKEK([]byte("storage password")))
+	require.NoError(t, err)
+	output := randomKeyGenOutput(1, 2, 3, 4)
+	require.NoError(t, s.SaveKeyGenOutput(output))
+
+	requestID := dkg.RequestID{1, 2, 3}
+	require.NoError(t, s.SetShareOrigin(output.ValidatorPK, requestID, CeremonyReshare))
+	page, err := s.ListShares(ShareFilter{})
+	require.NoError(t, err)
+	require.Len(t, page.Shares, 1)
+	require.Equal(t, hex.EncodeToString(requestID[:]), page.Shares[0].RequestID)
+	require.Equal(t, CeremonyReshare, page.Shares[0].Ceremony)
+
+	// the share itself is untouched
+	stored, err := s.GetKeyGenOutput(output.ValidatorPK)
+	require.NoError(t, err)
+	require.True(t, stored.Share.IsEqual(output.Share))
+
+	require.Error(t, s.SetShareOrigin(randomKeyGenOutput(1).ValidatorPK, requestID, CeremonyKeygen))
+}
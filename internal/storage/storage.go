@@ -3,97 +3,134 @@ package storage
 import (
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"sync"
+	"time"
 
 	"github.com/bloxapp/ssv-spec/dkg"
 	"github.com/bloxapp/ssv-spec/types"
-	"github.com/dgraph-io/badger/v3"
 	"github.com/herumi/bls-eth-go-binary/bls"
 )
 
-var (
-	Network = "prater"
-)
+// RegistryURL is the base URL of the operator registry api of the network the node runs on
+var RegistryURL = "https://api.ssv.network/api/v4/hoodi"
 
 type Storage struct {
-	db *badger.DB
+	db Backend
+	// shares encrypts the shares of keygen outputs, nil until share encryption is enabled
+	shares *shareCipher
+	// operatorTTL is how long a cached registry operator is used before it's fetched again
+	operatorTTL time.Duration
+	// onOperatorKeyChange is called when the registry returns another RSA key than the cached one
+	onOperatorKeyChange func(operatorID types.OperatorID)
+	// auditMu serializes appends to the audit log
+	auditMu sync.Mutex
 }
 
-func NewStorage(db *badger.DB) dkg.Storage {
+// NewStorage returns the storage of the node, keeping its records in the backend
+func NewStorage(db Backend) *Storage {
 	return &Storage{
-		db: db,
+		db:          db,
+		operatorTTL: DefaultOperatorCacheTTL,
 	}
 }
 
-func (s *Storage) GetDKGOperator(operatorID types.OperatorID) (bool, *dkg.Operator, error) {
-
-	var (
-		val          []byte
-		requireFetch bool   = false
-		key          string = fmt.Sprintf("operator/%d", operatorID)
-	)
-
-	err := s.db.View(func(txn *badger.Txn) error {
-		item, err := txn.Get([]byte(key))
-		if err != nil {
-			return err
+// Ping checks that the database is open and readable
+func (s *Storage) Ping() error {
+	return s.db.View(func(tx Tx) error {
+		_, err := tx.Get([]byte("ping"))
+		if err == ErrNotFound {
+			return nil
 		}
-		val, err = item.ValueCopy(nil)
 		return err
 	})
-	if err == badger.ErrKeyNotFound {
-		requireFetch = true
-	} else if err != nil {
-		return false, nil, err
-	}
+}
 
-	var operator = new(dkg.Operator)
-	if !requireFetch {
-		if err := json.Unmarshal(val, operator); err != nil {
-			return false, nil, err
-		}
-	} else {
-		operator, err = FetchOperatorByID(operatorID)
-		if err != nil {
-			return false, nil, err
-		}
-		value, err := json.Marshal(operator)
-		if err != nil {
-			return false, nil, fmt.Errorf("failed to marshal keygen output :: %s", err.Error())
-		}
-		if err = s.db.Update(func(txn *badger.Txn) error {
-			return txn.Set([]byte(key), value)
-		}); err != nil {
-			return false, nil, err
+// CountKeyGenOutputs returns the number of keygen outputs (validator shares) held in the database
+func (s *Storage) CountKeyGenOutputs() (int, error) {
+	count := 0
+	err := s.db.View(func(tx Tx) error {
+		return tx.Iterate([]byte(prefixKeyGen), nil, func(key, value []byte) (bool, error) {
+			count++
+			return true, nil
+		})
+	})
+	return count, err
+}
+
+// forEachKeyGenOutput calls fn with the key and the stored keygen output of every validator
+func forEachKeyGenOutput(tx Tx, fn func(key []byte, kgo *KeyGenOutput) error) error {
+	return tx.Iterate([]byte(prefixKeyGen), nil, func(key, value []byte) (bool, error) {
+		kgo := &KeyGenOutput{}
+		if err := json.Unmarshal(value, kgo); err != nil {
+			return false, fmt.Errorf("failed to unmarshal keygen output %x: %v", key, err)
 		}
-	}
-	return true, operator, nil
+		return true, fn(key, kgo)
+	})
+}
+
+// ForEachKeyGenOutput calls fn with every stored keygen output, its share decrypted
+func (s *Storage) ForEachKeyGenOutput(fn func(output *dkg.KeyGenOutput) error) error {
+	return s.db.View(func(tx Tx) error {
+		return forEachKeyGenOutput(tx, func(key []byte, kgo *KeyGenOutput) error {
+			if kgo.EncryptedShare != "" {
+				if s.shares == nil {
+					return errors.New("share is encrypted but share encryption isn't enabled")
+				}
+				if err := kgo.decryptShare(s.shares); err != nil {
+					return err
+				}
+			}
+			output, err := kgo.output()
+			if err != nil {
+				return fmt.Errorf("failed to decode keygen output of validator %s: %v", kgo.ValidatorPK, err)
+			}
+			return fn(output)
+		})
+	})
 }
 
 type KeyGenOutput struct {
-	Share           string
+	// Share is the plaintext share, empty once the share is encrypted into EncryptedShare
+	Share           string `json:",omitempty"`
+	EncryptedShare  string `json:",omitempty"`
 	OperatorPubKeys map[types.OperatorID]string
 	ValidatorPK     string
 	Threshold       uint64
+	// RequestID and Ceremony are set once the node broadcasts its output of the ceremony
+	RequestID string `json:",omitempty"`
+	Ceremony  string `json:",omitempty"`
+	CreatedAt time.Time
 }
 
 func (o *KeyGenOutput) Encode(output *dkg.KeyGenOutput) ([]byte, error) {
-	kgo := &KeyGenOutput{
-		Share:           output.Share.SerializeToHexStr(),
-		OperatorPubKeys: make(map[types.OperatorID]string),
-		ValidatorPK:     hex.EncodeToString(output.ValidatorPK),
-		Threshold:       output.Threshold,
-	}
+	o.set(output)
+	return json.Marshal(o)
+}
+
+func (o *KeyGenOutput) set(output *dkg.KeyGenOutput) {
+	o.Share = output.Share.SerializeToHexStr()
+	o.EncryptedShare = ""
+	o.OperatorPubKeys = make(map[types.OperatorID]string)
+	o.ValidatorPK = hex.EncodeToString(output.ValidatorPK)
+	o.Threshold = output.Threshold
 	for operatorID, pk := range output.OperatorPubKeys {
-		kgo.OperatorPubKeys[operatorID] = pk.SerializeToHexStr()
+		o.OperatorPubKeys[operatorID] = pk.SerializeToHexStr()
 	}
-	return json.Marshal(kgo)
 }
 
 func (o *KeyGenOutput) Decode(output []byte) (*dkg.KeyGenOutput, error) {
 	if err := json.Unmarshal(output, o); err != nil {
 		return nil, err
 	}
+	return o.output()
+}
+
+func (o *KeyGenOutput) output() (*dkg.KeyGenOutput, error) {
+	if o.Share == "" && o.EncryptedShare != "" {
+		return nil, errors.New("share is encrypted")
+	}
 
 	kgo := &dkg.KeyGenOutput{
 		OperatorPubKeys: make(map[types.OperatorID]*bls.PublicKey),
@@ -123,26 +160,28 @@ func (o *KeyGenOutput) Decode(output []byte) (*dkg.KeyGenOutput, error) {
 }
 
 func (s *Storage) SaveKeyGenOutput(output *dkg.KeyGenOutput) error {
-	kgo := &KeyGenOutput{}
-	value, err := kgo.Encode(output)
+	kgo := &KeyGenOutput{CreatedAt: time.Now().UTC()}
+	kgo.set(output)
+	if s.shares != nil {
+		if err := kgo.encryptShare(s.shares); err != nil {
+			return fmt.Errorf("failed to encrypt share :: %s", err.Error())
+		}
+	}
+	value, err := json.Marshal(kgo)
 	if err != nil {
 		return fmt.Errorf("failed to marshal keygen output :: %s", err.Error())
 	}
 
-	return s.db.Update(func(txn *badger.Txn) error {
-		return txn.Set([]byte(output.ValidatorPK), value)
+	return s.db.Update(func(tx Tx) error {
+		return tx.Set(keyGenKey(output.ValidatorPK), value)
 	})
 }
 
 func (s *Storage) GetKeyGenOutput(pk types.ValidatorPK) (*dkg.KeyGenOutput, error) {
 	var val []byte
-	err := s.db.View(func(txn *badger.Txn) error {
-		item, err := txn.Get([]byte(pk))
-		if err != nil {
-			return err
-		}
-
-		val, err = item.ValueCopy(nil)
+	err := s.db.View(func(tx Tx) error {
+		var err error
+		val, err = tx.Get(keyGenKey(pk))
 		return err
 	})
 	if err != nil {
@@ -150,7 +189,18 @@ func (s *Storage) GetKeyGenOutput(pk types.ValidatorPK) (*dkg.KeyGenOutput, erro
 	}
 
 	kgo := &KeyGenOutput{}
-	result, err := kgo.Decode(val)
+	if err := json.Unmarshal(val, kgo); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal keygen output :: %s", err.Error())
+	}
+	if kgo.EncryptedShare != "" {
+		if s.shares == nil {
+			return nil, errors.New("share is encrypted but share encryption isn't enabled")
+		}
+		if err := kgo.decryptShare(s.shares); err != nil {
+			return nil, err
+		}
+	}
+	result, err := kgo.output()
 	if err != nil {
 		return nil, fmt.Errorf("failed to unmarshal keygen output :: %s", err.Error())
 	}
@@ -2,6 +2,7 @@ package workers
 
 import (
 	"context"
+	"sync"
 
 	"github.com/RockX-SG/frost-dkg-demo/internal/logger"
 )
@@ -11,11 +12,14 @@ type Ctxlog string
 type Job struct {
 	ID string
 	Fn func(*context.Context)
+
+	ctx context.Context
 }
 
 type Runner struct {
 	incomingJobs chan *Job
 	jobs         map[string]context.CancelFunc
+	mu           sync.Mutex
 
 	logger *logger.Logger
 }
@@ -28,19 +32,32 @@ func NewRunner(logger *logger.Logger) *Runner {
 	}
 }
 
+// AddJob registers the job, cancelling the job of the same ID, and queues it for Run to start.
+// The job can be cancelled right away: one cancelled before it starts runs with a done context.
 func (r *Runner) AddJob(j *Job) {
+	ctxlog := context.WithValue(context.Background(), Ctxlog("logger"), r.logger)
+	ctx, cancel := context.WithCancel(ctxlog)
+	j.ctx = ctx
+	r.mu.Lock()
+	if previous, ok := r.jobs[j.ID]; ok {
+		previous()
+	}
+	r.jobs[j.ID] = cancel
+	r.mu.Unlock()
 	r.incomingJobs <- j
 }
 
 func (r *Runner) Run() {
 	for job := range r.incomingJobs {
-		ctxlog := context.WithValue(context.Background(), Ctxlog("logger"), r.logger)
-		ctx, cancel := context.WithCancel(ctxlog)
-		r.jobs[job.ID] = cancel
-		go job.Fn(&ctx)
+		go job.Fn(&job.ctx)
 	}
 }
 
 func (r *Runner) Cancel(id string) {
-	r.jobs[id]()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if cancel, ok := r.jobs[id]; ok {
+		cancel()
+		delete(r.jobs, id)
+	}
 }
@@ -0,0 +1,69 @@
+package workers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/RockX-SG/frost-dkg-demo/internal/logger"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+)
+
+// waitingJob returns a job that runs until it's cancelled, and a channel it sends on once it
+// started and again once it stopped
+func waitingJob(id string) (*Job, chan string) {
+	events := make(chan string, 2)
+	return &Job{
+		ID: id,
+		Fn: func(ctx *context.Context) {
+			events <- "started"
+			<-(*ctx).Done()
+			events <- "stopped"
+		},
+	}, events
+}
+
+func requireEvent(t *testing.T, events chan string, event string) {
+	select {
+	case e := <-events:
+		require.Equal(t, event, e)
+	case <-time.After(5 * time.Second):
+		t.Fatalf("job not %s", event)
+	}
+}
+
+func TestRunnerCancel(t *testing.T) {
+	r := NewRunner(&logger.Logger{Logger: logrus.New()})
+
+	// a job cancelled before Run starts it runs with a done context
+	early, earlyEvents := waitingJob("early")
+	r.AddJob(early)
+	r.Cancel("early")
+	go r.Run()
+	requireEvent(t, earlyEvents, "started")
+	requireEvent(t, earlyEvents, "stopped")
+
+	job, events := waitingJob("job")
+	r.AddJob(job)
+	requireEvent(t, events, "started")
+	r.Cancel("job")
+	requireEvent(t, events, "stopped")
+}
+
+func TestRunnerReplaceJob(t *testing.T) {
+	r := NewRunner(&logger.Logger{Logger: logrus.New()})
+	go r.Run()
+
+	first, firstEvents := waitingJob("job")
+	r.AddJob(first)
+	requireEvent(t, firstEvents, "started")
+
+	// a job replaces the one of its ID
+	second, secondEvents := waitingJob("job")
+	r.AddJob(second)
+	requireEvent(t, firstEvents, "stopped")
+	requireEvent(t, secondEvents, "started")
+	r.Cancel("job")
+	requireEvent(t, secondEvents, "stopped")
+}